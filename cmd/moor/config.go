@@ -0,0 +1,461 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/adrg/xdg"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/walles/moor/v2/internal"
+	"github.com/walles/moor/v2/internal/linemetadata"
+)
+
+// Config file location, relative to the XDG config directories
+const configFileRelativePath = "moor/config.toml"
+
+// One "key = value" line from the config file
+type configSetting struct {
+	key   string
+	value string
+
+	lineNumber int
+}
+
+// Settings from a [extension.xxx] or [filetype.xxx] section
+type configSection struct {
+	// "extension" or "filetype"
+	kind string
+
+	// File extension without the leading dot, or a Chroma lexer name
+	name string
+
+	settings []configSetting
+}
+
+// The config file maps one-to-one to command line options. A setting "wrap =
+// true" is the same as passing "--wrap=true" on the command line.
+//
+// Example:
+//
+//	wrap = true
+//	style = "monokai"
+//
+//	[extension.md]
+//	wrap = true
+//
+//	[filetype.json]
+//	style = "github"
+//
+//	[keys]
+//	"ctrl-f" = "page-down"
+type configFile struct {
+	// Absolute path to the config file
+	path string
+
+	global   []configSetting
+	sections []configSection
+
+	// Key bindings from the [keys] section. Keys are key sequences like "g g",
+	// values are action names. See internal.Keymap.
+	keys []configSetting
+}
+
+// Find and parse the user's config file. Returns nil with no error if there
+// is no config file.
+func loadConfigFile() (*configFile, error) {
+	path, err := xdg.SearchConfigFile(configFileRelativePath)
+	if err != nil {
+		// No config file found, not a problem
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close() //nolint:errcheck
+
+	config, err := parseConfigFile(file, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Parse a subset of TOML: top level keys plus [extension.xxx],
+// [filetype.xxx] and [keys] sections. Values can be strings, integers or
+// booleans.
+func parseConfigFile(input io.Reader, path string) (*configFile, error) {
+	config := configFile{path: path}
+
+	var section *configSection
+	inKeysSection := false
+
+	scanner := bufio.NewScanner(input)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(stripConfigComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("%s line %d: Section header must end with ']': %s", path, lineNumber, line)
+			}
+
+			header := strings.TrimSpace(line[1 : len(line)-1])
+			if header == "keys" {
+				inKeysSection = true
+				section = nil
+				continue
+			}
+
+			kind, name, found := strings.Cut(header, ".")
+			if !found || (kind != "extension" && kind != "filetype") {
+				return nil, fmt.Errorf("%s line %d: Expected [extension.xxx], [filetype.xxx] or [keys], got: %s", path, lineNumber, line)
+			}
+
+			name, err := parseConfigValue(strings.TrimSpace(name))
+			if err != nil {
+				return nil, fmt.Errorf("%s line %d: %w", path, lineNumber, err)
+			}
+
+			config.sections = append(config.sections, configSection{kind: kind, name: strings.TrimPrefix(name, ".")})
+			section = &config.sections[len(config.sections)-1]
+			inKeysSection = false
+			continue
+		}
+
+		rawKey, rawValue, found := cutConfigKeyValue(line)
+		if !found {
+			return nil, fmt.Errorf("%s line %d: Expected key = value, got: %s", path, lineNumber, line)
+		}
+
+		key, err := parseConfigValue(strings.TrimSpace(rawKey))
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, lineNumber, err)
+		}
+		value, err := parseConfigValue(strings.TrimSpace(rawValue))
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, lineNumber, err)
+		}
+
+		setting := configSetting{key: key, value: value, lineNumber: lineNumber}
+		switch {
+		case inKeysSection:
+			config.keys = append(config.keys, setting)
+		case section != nil:
+			section.settings = append(section.settings, setting)
+		default:
+			config.global = append(config.global, setting)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	return &config, nil
+}
+
+// Remove any # comment from the line, unless the # is inside a string
+func stripConfigComment(line string) string {
+	var quote rune
+	escaped := false
+	for i, char := range line {
+		if escaped {
+			escaped = false
+			continue
+		}
+
+		switch {
+		case quote == '"' && char == '\\':
+			escaped = true
+		case quote != 0 && char == quote:
+			quote = 0
+		case quote == 0 && (char == '"' || char == '\''):
+			quote = char
+		case quote == 0 && char == '#':
+			return line[:i]
+		}
+	}
+
+	return line
+}
+
+// Split the line at the first = outside of quotes, so that quoted keys like
+// "=" can be used in the [keys] section
+func cutConfigKeyValue(line string) (string, string, bool) {
+	var quote rune
+	escaped := false
+	for i, char := range line {
+		if escaped {
+			escaped = false
+			continue
+		}
+
+		switch {
+		case quote == '"' && char == '\\':
+			escaped = true
+		case quote != 0 && char == quote:
+			quote = 0
+		case quote == 0 && (char == '"' || char == '\''):
+			quote = char
+		case quote == 0 && char == '=':
+			return line[:i], line[i+1:], true
+		}
+	}
+
+	return line, "", false
+}
+
+// Unquote a TOML value (or key). Bare words, numbers and booleans are returned
+// as-is.
+func parseConfigValue(value string) (string, error) {
+	if value == "" {
+		return "", fmt.Errorf("Empty value")
+	}
+
+	if strings.HasPrefix(value, "'") {
+		// Literal string, no escapes
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("Unterminated string: %s", value)
+		}
+		return value[1 : len(value)-1], nil
+	}
+
+	if !strings.HasPrefix(value, "\"") {
+		return value, nil
+	}
+
+	// TOML's \e isn't something strconv knows about
+	unquoted, err := strconv.Unquote(strings.ReplaceAll(value, `\e`, `\x1b`))
+	if err != nil {
+		return "", fmt.Errorf("Invalid string %s: %w", value, err)
+	}
+
+	return unquoted, nil
+}
+
+// Does this section apply to the given input file name?
+func (section configSection) appliesTo(fileName string) bool {
+	if section.kind == "extension" {
+		return strings.EqualFold(filepath.Ext(fileName), "."+section.name)
+	}
+
+	lexer := lexers.Match(fileName)
+	if lexer == nil {
+		return false
+	}
+
+	config := lexer.Config()
+	if strings.EqualFold(config.Name, section.name) {
+		return true
+	}
+	for _, alias := range config.Aliases {
+		if strings.EqualFold(alias, section.name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Description for the --help output, like "[extension.md]"
+func (section configSection) String() string {
+	return "[" + section.kind + "." + section.name + "]"
+}
+
+func (setting configSetting) asFlag() string {
+	return "--" + setting.key + "=" + setting.value
+}
+
+// Verify that all settings correspond to command line options
+func (config *configFile) validate(flagSet *flag.FlagSet) error {
+	check := func(setting configSetting) error {
+		if flagSet.Lookup(setting.key) == nil {
+			return fmt.Errorf("%s line %d: Unknown option: %s", config.path, setting.lineNumber, setting.key)
+		}
+		return nil
+	}
+
+	for _, setting := range config.global {
+		if err := check(setting); err != nil {
+			return err
+		}
+	}
+	for _, section := range config.sections {
+		for _, setting := range section.settings {
+			if err := check(setting); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Apply the [keys] section to a keymap
+func (config *configFile) bindKeys(keymap *internal.Keymap) error {
+	for _, binding := range config.keys {
+		err := keymap.Bind(binding.key, binding.value)
+		if err != nil {
+			return fmt.Errorf("%s line %d: %w", config.path, binding.lineNumber, err)
+		}
+	}
+
+	return nil
+}
+
+// A source of command line options, like the config file or the MOOR
+// environment variable.
+type optionLayer struct {
+	// Where do these options come from? For the --help output.
+	origin string
+
+	args []string
+}
+
+// The global config file settings as a command line options layer
+func (config *configFile) globalLayer() optionLayer {
+	layer := optionLayer{origin: "config file " + config.path}
+	for _, setting := range config.global {
+		layer.args = append(layer.args, setting.asFlag())
+	}
+	return layer
+}
+
+// Options from all sections that apply to any of the input files, in config
+// file order.
+func (config *configFile) sectionLayers(fileNames []string) []optionLayer {
+	layers := []optionLayer{}
+	for _, section := range config.sections {
+		applies := false
+		for _, fileName := range fileNames {
+			if fileName != "-" && section.appliesTo(fileName) {
+				applies = true
+				break
+			}
+		}
+		if !applies {
+			continue
+		}
+
+		layer := optionLayer{origin: "config file " + config.path + " " + section.String()}
+		for _, setting := range section.settings {
+			layer.args = append(layer.args, setting.asFlag())
+		}
+		layers = append(layers, layer)
+	}
+
+	return layers
+}
+
+// Where did an option get its value from, and what was that value?
+type optionOrigin struct {
+	origin string
+	value  string
+}
+
+// For each option set by any layer, figure out which layer set it last. Later
+// layers override earlier ones.
+func optionOrigins(flagSet *flag.FlagSet, layers []optionLayer) map[string]optionOrigin {
+	origins := map[string]optionOrigin{}
+	for _, layer := range layers {
+		for i := 0; i < len(layer.args); i++ {
+			arg := layer.args[i]
+			if strings.HasPrefix(arg, "+") {
+				// Target line number, see getTargetLine()
+				continue
+			}
+			if arg == "--" || arg == "-" || !strings.HasPrefix(arg, "-") {
+				// End of options, just like in flagSet.Parse()
+				return origins
+			}
+
+			name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+			option := flagSet.Lookup(name)
+			if option == nil {
+				continue
+			}
+
+			if !hasValue {
+				boolOption, isBool := option.Value.(interface{ IsBoolFlag() bool })
+				if isBool && boolOption.IsBoolFlag() {
+					value = "true"
+				} else if i+1 < len(layer.args) {
+					i++
+					value = layer.args[i]
+				}
+			}
+
+			origins[name] = optionOrigin{origin: layer.origin, value: value}
+		}
+	}
+
+	return origins
+}
+
+// Split a string into words like a shell would, honoring single and double
+// quotes. This makes MOOR="--scroll-left-hint='ESC[7m ' --wrap" work.
+func splitShellWords(s string) ([]string, error) {
+	words := []string{}
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	for _, char := range s {
+		switch {
+		case quote != 0 && char == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(char)
+		case char == '"' || char == '\'':
+			quote = char
+			inWord = true
+		case char == ' ' || char == '\t' || char == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(char)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("Unterminated %c quote in: %s", quote, s)
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// Parse all layers into the flag set, in order, so that later layers override
+// earlier ones. Returns the target line from any "+1234" argument.
+func parseLayers(flagSet *flag.FlagSet, layers []optionLayer) (*linemetadata.Index, error) {
+	flags := []string{}
+	for _, layer := range layers {
+		flags = append(flags, layer.args...)
+	}
+
+	targetLine, remainingArgs := getTargetLine(flags)
+	return targetLine, flagSet.Parse(remainingArgs)
+}
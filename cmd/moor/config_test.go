@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/walles/moor/v2/internal"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseConfigFile(t *testing.T) {
+	config, err := parseConfigFile(strings.NewReader(`
+# Comment
+wrap = true
+style = "monokai" # Trailing comment
+scroll-left-hint = "\e[7m<"
+tab-size = 4
+
+[extension.md]
+statusbar = 'bold#'
+
+[filetype.json]
+reformat = true
+`), "/config.toml")
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, config.globalLayer().args, []string{
+		"--wrap=true",
+		"--style=monokai",
+		"--scroll-left-hint=\x1b[7m<",
+		"--tab-size=4",
+	})
+
+	assert.Equal(t, len(config.sections), 2)
+	assert.Equal(t, config.sections[0].String(), "[extension.md]")
+	assert.Equal(t, config.sections[0].settings[0].asFlag(), "--statusbar=bold#")
+	assert.Equal(t, config.sections[1].String(), "[filetype.json]")
+}
+
+func TestParseConfigFileErrors(t *testing.T) {
+	_, err := parseConfigFile(strings.NewReader("wrap"), "/config.toml")
+	assert.Error(t, err, "/config.toml line 1: Expected key = value, got: wrap")
+
+	_, err = parseConfigFile(strings.NewReader("\n[section]"), "/config.toml")
+	assert.Error(t, err, "/config.toml line 2: Expected [extension.xxx], [filetype.xxx] or [keys], got: [section]")
+
+	_, err = parseConfigFile(strings.NewReader("style = 'monokai"), "/config.toml")
+	assert.Error(t, err, "/config.toml line 1: Unterminated string: 'monokai")
+}
+
+func TestConfigValidate(t *testing.T) {
+	flagSet := flag.NewFlagSet("", flag.ContinueOnError)
+	flagSet.Bool("wrap", false, "")
+
+	config, err := parseConfigFile(strings.NewReader("wrap = true\n[extension.md]\nwarp = true"), "/config.toml")
+	assert.NilError(t, err)
+
+	assert.Error(t, config.validate(flagSet), "/config.toml line 3: Unknown option: warp")
+}
+
+func TestConfigSectionLayers(t *testing.T) {
+	config, err := parseConfigFile(strings.NewReader(`
+[extension.md]
+wrap = true
+
+[filetype.json]
+style = "github"
+
+[extension.txt]
+wrap = false
+`), "/config.toml")
+	assert.NilError(t, err)
+
+	layers := config.sectionLayers([]string{"README.md", "data.json"})
+	assert.Equal(t, len(layers), 2)
+	assert.Equal(t, layers[0].origin, "config file /config.toml [extension.md]")
+	assert.DeepEqual(t, layers[0].args, []string{"--wrap=true"})
+	assert.Equal(t, layers[1].origin, "config file /config.toml [filetype.json]")
+	assert.DeepEqual(t, layers[1].args, []string{"--style=github"})
+
+	assert.Equal(t, len(config.sectionLayers([]string{"-"})), 0)
+}
+
+func TestOptionOrigins(t *testing.T) {
+	flagSet := flag.NewFlagSet("", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	flagSet.Bool("wrap", false, "")
+	flagSet.String("style", "", "")
+	flagSet.Int("shift", 16, "")
+
+	layers := []optionLayer{
+		{origin: "config file", args: []string{"--wrap=true", "--style=monokai", "--shift=4"}},
+		{origin: "environment", args: []string{"-style", "github"}},
+		{origin: "command line", args: []string{"--wrap", "file.txt", "--shift=8"}},
+	}
+
+	targetLine, err := parseLayers(flagSet, layers)
+	assert.NilError(t, err)
+	assert.Assert(t, targetLine == nil)
+	assert.Equal(t, flagSet.Lookup("style").Value.String(), "github")
+
+	origins := optionOrigins(flagSet, layers)
+	assert.Equal(t, origins["wrap"], optionOrigin{origin: "command line", value: "true"})
+	assert.Equal(t, origins["style"], optionOrigin{origin: "environment", value: "github"})
+
+	// Flag parsing stops at the first file name, and so should we
+	assert.Equal(t, origins["shift"], optionOrigin{origin: "config file", value: "4"})
+}
+
+func TestSplitShellWords(t *testing.T) {
+	words, err := splitShellWords(`--wrap  --scroll-left-hint='ESC[7m ' "--style=a b"`)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, words, []string{"--wrap", "--scroll-left-hint=ESC[7m ", "--style=a b"})
+
+	words, err = splitShellWords("  ")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, words, []string{})
+
+	_, err = splitShellWords(`--style='monokai`)
+	assert.Error(t, err, "Unterminated ' quote in: --style='monokai")
+}
+
+func TestConfigBindKeys(t *testing.T) {
+	config, err := parseConfigFile(strings.NewReader(`
+[keys]
+"g g" = "goto-end"
+ctrl-f = "page-down"
+`), "/config.toml")
+	assert.NilError(t, err)
+	assert.NilError(t, config.bindKeys(internal.NewKeymap()))
+
+	// The default toggle-statusbar key, quoted since it's also the separator
+	config, err = parseConfigFile(strings.NewReader(`
+[keys]
+"=" = quit
+`), "/config.toml")
+	assert.NilError(t, err)
+	assert.Equal(t, config.keys[0].key, "=")
+	assert.Equal(t, config.keys[0].value, "quit")
+	assert.NilError(t, config.bindKeys(internal.NewKeymap()))
+
+	config, err = parseConfigFile(strings.NewReader("[keys]\nx = 'fly'"), "/config.toml")
+	assert.NilError(t, err)
+	assert.Error(t, config.bindKeys(internal.NewKeymap()), "/config.toml line 2: Unknown action: fly")
+}
@@ -210,6 +210,49 @@ func parseTabAmount(tabAmount string) (uint, error) {
 	return uint(value), nil
 }
 
+func parseMaxLines(maxLines string) (int, error) {
+	value, err := strconv.ParseUint(maxLines, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(value), nil
+}
+
+func parseFilterContext(filterContext string) ([2]int, error) {
+	before, after, err := internal.ParseFilterContext(filterContext)
+	if err != nil {
+		return [2]int{}, err
+	}
+
+	return [2]int{before, after}, nil
+}
+
+// Like less' --header: "N" lines, "N,M" lines and columns, or ",M" columns
+func parseHeader(header string) ([2]int, error) {
+	linesString, columnsString, _ := strings.Cut(header, ",")
+
+	lines := 0
+	if strings.TrimSpace(linesString) != "" {
+		var err error
+		lines, err = strconv.Atoi(strings.TrimSpace(linesString))
+		if err != nil || lines < 0 {
+			return [2]int{}, fmt.Errorf("not a line count: %q", linesString)
+		}
+	}
+
+	columns := 0
+	if strings.TrimSpace(columnsString) != "" {
+		var err error
+		columns, err = strconv.Atoi(strings.TrimSpace(columnsString))
+		if err != nil || columns < 0 {
+			return [2]int{}, fmt.Errorf("not a column count: %q", columnsString)
+		}
+	}
+
+	return [2]int{lines, columns}, nil
+}
+
 func parseMouseMode(mouseMode string) (twin.MouseMode, error) {
 	switch mouseMode {
 	case "auto":
@@ -413,10 +456,12 @@ func pagerFromArgs(
 
 	noLineNumbers := flagSet.Bool("no-linenumbers", noLineNumbersDefault(), "Hide line numbers on startup, press left arrow key to show")
 	noStatusBar := flagSet.Bool("no-statusbar", false, "Hide the status bar, toggle with '='")
-	reFormat := flagSet.Bool("reformat", false, "Reformat some input files (JSON)")
+	reFormat := flagSet.Bool("reformat", false, "Reformat some input files (JSON, XML, HTML, YAML, TOML, CSV)")
 	flagSet.Bool("no-reformat", true, "No effect, kept for compatibility. See --reformat")
 	quitIfOneScreen := flagSet.Bool("quit-if-one-screen", false, "Don't page if contents fits on one screen. Affected by --no-clear-on-exit-margin.")
 	noClearOnExit := flagSet.Bool("no-clear-on-exit", false, "Retain screen contents when exiting moor")
+	noLessOpen := flagSet.Bool("no-lessopen", false, "Don't preprocess input files using $LESSOPEN")
+	noRememberPosition := flagSet.Bool("no-remember-position", false, "Don't save or restore the last position and marks per file")
 	noClearOnExitMargin := flagSet.Int("no-clear-on-exit-margin", 1,
 		"Number of lines to leave for your shell prompt, defaults to 1")
 	statusBarStyle := flagSetFunc(flagSet, "statusbar", internal.STATUSBAR_STYLE_INVERSE,
@@ -431,6 +476,14 @@ func pagerFromArgs(
 		"Shown when view can scroll right. One character with optional ANSI highlighting.", parseScrollHint)
 	shift := flagSetFunc(flagSet, "shift", 16, "Horizontal scroll `amount` >=1, defaults to 16", parseShiftAmount)
 	tabSize := flagSetFunc(flagSet, "tab-size", 8, "Number of spaces per tab stop, defaults to 8", parseTabAmount)
+	maxLines := flagSetFunc(flagSet, "max-lines", 0,
+		"Keep only the last `N` lines of input, dropping older ones. 0 means no limit.", parseMaxLines)
+	filterContext := flagSetFunc(flagSet, "filter-context", [2]int{},
+		"Lines to show around filter matches, `N` or BEFORE,AFTER", parseFilterContext)
+	header := flagSetFunc(flagSet, "header", [2]int{},
+		"Keep `N` lines at the top in view when scrolling, or N,M to also keep M columns to the left", parseHeader)
+	opener := flagSet.String("opener", "",
+		"Command for opening selected links, the link is added as the last argument. Defaults to xdg-open or open.")
 	mouseMode := flagSetFunc(
 		flagSet,
 		"mousemode",
@@ -439,18 +492,50 @@ func pagerFromArgs(
 		parseMouseMode,
 	)
 
-	// Combine flags from environment and from command line
-	flags := args[1:]
-	moorEnv := strings.TrimSpace(os.Getenv(moorEnvVarName()))
-	if len(moorEnv) > 0 {
-		// FIXME: It would be nice if we could debug log that we're doing this,
-		// but logging is not yet set up and depends on command line parameters.
-		flags = append(strings.Fields(moorEnv), flags...)
+	// Combine flags from config file, environment and command line. Later
+	// layers override earlier ones.
+	//
+	// FIXME: It would be nice if we could debug log what we're doing here,
+	// but logging is not yet set up and depends on command line parameters.
+	config, err := loadConfigFile()
+	if err == nil && config != nil {
+		err = config.validate(flagSet)
+	}
+	keymap := internal.NewKeymap()
+	if lesskey, lesskeyErr := internal.LoadLesskey(); lesskeyErr == nil && lesskey != nil {
+		// Problems are listed by --help, see renderLesskey()
+		lesskey.ApplyTo(keymap)
+	}
+	if err == nil && config != nil {
+		err = config.bindKeys(keymap)
 	}
 
-	targetLine, remainingArgs := getTargetLine(flags)
+	layers := []optionLayer{}
+	if err == nil && config != nil {
+		layers = append(layers, config.globalLayer())
+	}
 
-	err = flagSet.Parse(remainingArgs)
+	envLayer := optionLayer{origin: moorEnvVarName() + " environment variable"}
+	if err == nil {
+		envLayer.args, err = splitShellWords(os.Getenv(moorEnvVarName()))
+	}
+	commandLineLayer := optionLayer{origin: "command line", args: args[1:]}
+
+	var targetLine *linemetadata.Index
+	if err == nil {
+		layers = append(layers, envLayer, commandLineLayer)
+		targetLine, err = parseLayers(flagSet, layers)
+	}
+
+	if err == nil && config != nil {
+		// Now that we know the input file names, apply any per-file-type
+		// config sections and parse again.
+		sectionLayers := config.sectionLayers(flagSet.Args())
+		if len(sectionLayers) > 0 {
+			layers = append(append(layers[:1], sectionLayers...), envLayer, commandLineLayer)
+			targetLine, err = parseLayers(flagSet, layers)
+		}
+	}
 
 	if err == nil {
 		if *noClearOnExitMargin < 0 {
@@ -460,7 +545,7 @@ func pagerFromArgs(
 
 	if err != nil {
 		if err == flag.ErrHelp {
-			printUsage(flagSet, *terminalColorsCount)
+			printUsage(flagSet, *terminalColorsCount, config, optionOrigins(flagSet, layers))
 			return nil, nil, chroma.Style{}, nil, false, nil
 		}
 
@@ -511,6 +596,13 @@ func pagerFromArgs(
 			continue
 		}
 
+		if !*noLessOpen && os.Getenv("LESSOPEN") != "" {
+			// $LESSOPEN may show things we can't open ourselves, like
+			// directory listings. If it doesn't, opening the file below
+			// will fail before the screen is set up.
+			continue
+		}
+
 		// Need to check before newScreen() below, otherwise the screen
 		// will be cleared before we print the "No such file" error.
 		err := reader.TryOpen(inputFilename)
@@ -554,7 +646,12 @@ func pagerFromArgs(
 
 	var readerImpls []*reader.ReaderImpl
 	shouldFormat := *reFormat
-	readerOptions := reader.ReaderOptions{Lexer: *lexer, ShouldFormat: shouldFormat}
+	readerOptions := reader.ReaderOptions{
+		Lexer:        *lexer,
+		ShouldFormat: shouldFormat,
+		MaxLines:     *maxLines,
+		LessOpen:     !*noLessOpen,
+	}
 
 	stdinName := ""
 	if os.Getenv("PAGER_LABEL") != "" {
@@ -635,7 +732,18 @@ func pagerFromArgs(
 	pager.ScrollRightHint = *scrollRightHint
 	pager.SideScrollAmount = int(*shift)
 	pager.TabSize = int(*tabSize)
+	pager.FilterContextBefore = filterContext[0]
+	pager.FilterContextAfter = filterContext[1]
+	pager.HeaderLines = header[0]
+	pager.HeaderColumns = header[1]
 	pager.WithSearchHitLineBackground = !*noSearchLineHighlight
+	pager.Keymap = keymap
+	pager.LinkOpener = *opener
+	pager.ShouldFormat = shouldFormat
+	pager.LessOpen = !*noLessOpen
+	if !*noRememberPosition {
+		pager.PositionHistory = internal.BootPositionHistory("")
+	}
 
 	pager.TargetLine = targetLine
 	if *follow && pager.TargetLine == nil {
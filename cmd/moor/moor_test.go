@@ -18,6 +18,26 @@ func TestParseScrollHint(t *testing.T) {
 	})
 }
 
+func TestParseHeader(t *testing.T) {
+	header, err := parseHeader("2")
+	assert.NilError(t, err)
+	assert.Equal(t, header, [2]int{2, 0})
+
+	header, err = parseHeader("1,8")
+	assert.NilError(t, err)
+	assert.Equal(t, header, [2]int{1, 8})
+
+	header, err = parseHeader(",8")
+	assert.NilError(t, err)
+	assert.Equal(t, header, [2]int{0, 8})
+
+	_, err = parseHeader("-1")
+	assert.ErrorContains(t, err, "not a line count")
+
+	_, err = parseHeader("1,x")
+	assert.ErrorContains(t, err, "not a column count")
+}
+
 func TestPageOneInputFile(t *testing.T) {
 	pager, screen, _, formatter, _, err := pagerFromArgs(
 		[]string{"", "moor_test.go"},
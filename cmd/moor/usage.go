@@ -10,6 +10,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/adrg/xdg"
 	log "github.com/sirupsen/logrus"
 	"github.com/walles/moor/v2/internal"
 	"github.com/walles/moor/v2/twin"
@@ -44,6 +45,31 @@ func renderLessTermcapEnvVar(envVarName string, description string, colors twin.
 	)
 }
 
+// Describe what we imported from the user's lesskey file, if any
+func renderLesskey(colors twin.ColorCount) string {
+	lesskey, err := internal.LoadLesskey()
+	if err != nil {
+		return fmt.Sprintf("  Reading lesskey file failed: %v\n", err)
+	}
+	if lesskey == nil {
+		return ""
+	}
+
+	result := fmt.Sprintf("  Key bindings imported from %s: %d\n", lesskey.Path, lesskey.BindingsCount())
+	if len(lesskey.Unsupported) == 0 {
+		return result
+	}
+
+	bold := twin.StyleDefault.WithAttr(twin.AttrBold).RenderUpdateFrom(twin.StyleDefault, colors)
+	notBold := twin.StyleDefault.RenderUpdateFrom(twin.StyleDefault.WithAttr(twin.AttrBold), colors)
+	result += "  " + bold + "Not imported" + notBold + ":\n"
+	for _, problem := range lesskey.Unsupported {
+		result += "    " + problem + "\n"
+	}
+
+	return result
+}
+
 func renderPagerEnvVar(name string, colors twin.ColorCount) string {
 	bold := twin.StyleDefault.WithAttr(twin.AttrBold).RenderUpdateFrom(twin.StyleDefault, colors)
 	notBold := twin.StyleDefault.RenderUpdateFrom(twin.StyleDefault.WithAttr(twin.AttrBold), colors)
@@ -116,7 +142,10 @@ func printCommandline(output io.Writer) {
 
 	fmt.Fprintln(output, "Commandline: moor", strings.Join(os.Args[1:], " "))                 //nolint:errcheck
 	fmt.Fprintf(output, "Environment: %s=\"%v\"\n", envVarDescription, os.Getenv(envVarName)) //nolint:errcheck
-	fmt.Fprintln(output)                                                                      //nolint:errcheck
+	if configPath, err := xdg.SearchConfigFile(configFileRelativePath); err == nil {
+		fmt.Fprintln(output, "Config file:", configPath) //nolint:errcheck
+	}
+	fmt.Fprintln(output) //nolint:errcheck
 }
 
 func heading(text string, colors twin.ColorCount) string {
@@ -126,7 +155,7 @@ func heading(text string, colors twin.ColorCount) string {
 	return prefix + text + suffix
 }
 
-func printUsage(flagSet *flag.FlagSet, colors twin.ColorCount) {
+func printUsage(flagSet *flag.FlagSet, colors twin.ColorCount, config *configFile, origins map[string]optionOrigin) {
 	// This controls where PrintDefaults() prints, see below
 	flagSet.SetOutput(os.Stdout)
 
@@ -167,10 +196,24 @@ func printUsage(flagSet *flag.FlagSet, colors twin.ColorCount) {
 		fmt.Printf("  Current setting: %s=\"%s\"\n", envVarName, envVarValue)
 	}
 
+	fmt.Println()
+	if config == nil {
+		fmt.Println("  Options can also be set in a config file, but none was found. To create one:")
+		fmt.Println("    " + filepath.Join(xdg.ConfigHome, configFileRelativePath))
+	} else {
+		fmt.Println("  Options are also read from this config file:")
+		fmt.Println("    " + config.path)
+		for _, section := range config.sections {
+			fmt.Println("  Section " + section.String() + " applies when paging matching files.")
+		}
+	}
+	fmt.Println("  Precedence is config file < " + envVarName + " environment variable < command line.")
+
 	envSection := ""
 	envSection += renderLessTermcapEnvVar("LESS_TERMCAP_md", "man page bold style", colors)
 	envSection += renderLessTermcapEnvVar("LESS_TERMCAP_us", "man page underline style", colors)
 	envSection += renderLessTermcapEnvVar("LESS_TERMCAP_so", "search hits and footer style", colors)
+	envSection += renderLesskey(colors)
 
 	envSection += renderPagerEnvVar("PAGER", colors)
 	envVars := os.Environ()
@@ -216,6 +259,29 @@ func printUsage(flagSet *flag.FlagSet, colors twin.ColorCount) {
 
 	fmt.Println("  +1234")
 	fmt.Println("    \tImmediately scroll to line 1234")
+
+	printOptionOrigins(origins, colors)
+}
+
+// List options that have been set, and where their values came from
+func printOptionOrigins(origins map[string]optionOrigin, colors twin.ColorCount) {
+	if len(origins) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(origins))
+	for name := range origins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println()
+	fmt.Println(heading("Current settings", colors))
+	for _, name := range names {
+		origin := origins[name]
+		fmt.Printf("  --%s=%s\n", name, origin.value)
+		fmt.Printf("    \tFrom %s\n", origin.origin)
+	}
 }
 
 // If $PAGER isn't pointing to us, print a help text on how to set it.
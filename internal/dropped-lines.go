@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/reader"
+)
+
+// With --max-lines, the reader drops its oldest lines as new ones come in. Move
+// the scroll position and any bookmarks pointing at dropped lines to the first
+// line still there.
+func (p *Pager) moveOffDroppedLines() {
+	dropped := p.droppedLineCount()
+	if dropped == 0 {
+		return
+	}
+
+	// Dropped lines keep their line numbers, so this is where the retained
+	// lines start in the possibly filtered view
+	firstKept := p.filteringReader.indexOf(linemetadata.NumberFromZeroBased(dropped))
+	if firstKept == nil {
+		// Nothing after the dropped lines passes the filter
+		return
+	}
+
+	if isDropped(p.scrollPosition, *firstKept) {
+		p.scrollPosition = NewScrollPositionFromIndex(*firstKept, "Pager scroll position")
+	}
+
+	for mark, position := range p.bookmarks {
+		if isDropped(position, *firstKept) {
+			p.bookmarks[mark] = NewScrollPositionFromIndex(*firstKept, "Bookmark")
+		}
+	}
+}
+
+// How many of the first lines of the current input have been dropped
+func (p *Pager) droppedLineCount() int {
+	if p.isShowingHelp {
+		return 0
+	}
+
+	backingReader, ok := p.filteringReader.BackingReader.(*reader.ReaderImpl)
+	if !ok {
+		return 0
+	}
+
+	return backingReader.DroppedLineCount()
+}
+
+func isDropped(position scrollPosition, firstKept linemetadata.Index) bool {
+	lineIndex := position.internalDontTouch.lineIndex
+	return lineIndex != nil && lineIndex.IsBefore(firstKept)
+}
@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/styles"
+	"gotest.tools/v3/assert"
+
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/reader"
+	"github.com/walles/moor/v2/twin"
+)
+
+func TestMoveOffDroppedLines(t *testing.T) {
+	lines := []string{}
+	for i := range 100 {
+		lines = append(lines, fmt.Sprint("Line ", i+1))
+	}
+
+	r, err := reader.NewFromStream(
+		"TestMoveOffDroppedLines",
+		strings.NewReader(strings.Join(lines, "\n")+"\n"),
+		formatters.TTY16m,
+		reader.ReaderOptions{Style: styles.Get("native"), MaxLines: 10})
+	assert.NilError(t, err)
+	assert.NilError(t, r.Wait())
+
+	pager := NewPager(r)
+	pager.screen = twin.NewFakeScreen(20, 5)
+	pager.scrollPosition = NewScrollPositionFromIndex(linemetadata.IndexFromZeroBased(5), "test")
+	pager.bookmarks = map[rune]scrollPosition{}
+	pager.bookmarks['a'] = NewScrollPositionFromIndex(linemetadata.IndexFromZeroBased(50), "test")
+	pager.bookmarks['b'] = NewScrollPositionFromIndex(linemetadata.IndexFromZeroBased(95), "test")
+
+	pager.moveOffDroppedLines()
+
+	// Dropped targets move to the first line still there
+	firstKept := linemetadata.IndexFromZeroBased(90)
+	assert.Equal(t, *pager.scrollPosition.internalDontTouch.lineIndex, firstKept)
+	assert.Equal(t, *pager.bookmarks['a'].internalDontTouch.lineIndex, firstKept)
+
+	// Other ones stay
+	assert.Equal(t, *pager.bookmarks['b'].internalDontTouch.lineIndex, linemetadata.IndexFromZeroBased(95))
+
+	assert.Equal(t, pager.lineIndex().Index(), 90)
+	assert.Equal(t, *pager.lineNumberOf(pager.lineIndex()), linemetadata.NumberFromOneBased(91))
+}
+
+func TestFilterDroppedLines(t *testing.T) {
+	source, sink, err := os.Pipe()
+	assert.NilError(t, err)
+	defer func() { _ = sink.Close() }()
+
+	writeLines := func(first int, last int) {
+		for i := first; i <= last; i++ {
+			_, err := fmt.Fprintln(sink, "Line", i)
+			assert.NilError(t, err)
+		}
+	}
+
+	// Creating the reader waits for the first bytes
+	writeLines(1, 10)
+	r, err := reader.NewFromStream(
+		"TestFilterDroppedLines",
+		source,
+		formatters.TTY16m,
+		reader.ReaderOptions{Style: styles.Get("native"), MaxLines: 10})
+	assert.NilError(t, err)
+
+	waitForLineCount := func(lineCount int) {
+		for r.GetLineCount() < lineCount {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	pager := NewPager(r)
+	pager.screen = twin.NewFakeScreen(20, 5)
+	pager.filter = newFilter("5")
+	pager.search.For("Line")
+
+	waitForLineCount(10)
+	assert.Equal(t, pager.filteringReader.GetLineCount(), 1)
+	assert.Equal(t, pager.searchHitsStatus(), "hit 1/1")
+
+	// Line 5 is dropped now, but its index stays in use
+	writeLines(11, 20)
+	waitForLineCount(20)
+	assert.Equal(t, pager.filteringReader.GetLineCount(), 2)
+	assert.Equal(t, pager.filteringReader.droppedLineCount(), 1)
+	assert.Equal(t, len(pager.filteringReader.scan.acceptedLines), 1)
+	assert.Assert(t, pager.filteringReader.GetLine(linemetadata.IndexFromZeroBased(0)) == nil)
+	assert.Equal(t, pager.filteringReader.GetLine(linemetadata.IndexFromZeroBased(1)).Plain(), "Line 15")
+
+	// Asking for dropped lines gets us the first ones we still have
+	lines := pager.filteringReader.GetLines(linemetadata.Index{}, 5).Lines
+	assert.Equal(t, len(lines), 1)
+	assert.Equal(t, lines[0].Plain(), "Line 15")
+
+	// The search hit on the dropped line is gone as well
+	pager.moveOffDroppedLines()
+	assert.Equal(t, pager.lineIndex().Index(), 1)
+	assert.Equal(t, pager.searchHitsStatus(), "hit 1/1")
+	assert.Equal(t, len(pager.searchIndex.hits), 1)
+}
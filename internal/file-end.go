@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"github.com/alecthomas/chroma/v2"
+	log "github.com/sirupsen/logrus"
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/reader"
+)
+
+// Rather than waiting for all of a large file to be read before showing its
+// end, show the end right away. The full reader takes over when it's done, see
+// maybeStopShowingFileEnd().
+func (p *Pager) showFileEnd() {
+	if p.isShowingHelp || p.fileEnd != nil || p.ShouldFormat {
+		return
+	}
+
+	if p.TargetLine != nil && *p.TargetLine != linemetadata.IndexMax() {
+		// On our way to some line, the end won't help
+		return
+	}
+
+	p.readerLock.Lock()
+	r := p.readers[p.currentReader]
+	p.readerLock.Unlock()
+
+	if r.FileName == nil || r.ReadingDone.Load() || r.IsPreprocessed() {
+		return
+	}
+
+	var formatter chroma.Formatter
+	if p.chromaFormatter != nil {
+		formatter = *p.chromaFormatter
+	}
+
+	fileEnd, err := reader.NewFromFileEnd(*r.FileName, formatter, reader.ReaderOptions{Style: p.chromaStyle})
+	if err != nil {
+		log.Debug("Not showing the file end early: ", err)
+		return
+	}
+
+	p.readerLock.Lock()
+	p.fileEnd = fileEnd
+	p.filteringReader.SetBackingReader(fileEnd)
+	p.readerLock.Unlock()
+
+	// Follow the end of the file end as its lines come in. This also makes
+	// the full reader read all of the file, so that it can take over.
+	reallyHigh := linemetadata.IndexMax()
+	p.setTargetLine(&reallyHigh)
+
+	select {
+	case p.readerSwitched <- struct{}{}:
+	default:
+	}
+}
+
+// If the full reader has read everything, switch to it from the file end
+func (p *Pager) maybeStopShowingFileEnd() {
+	if p.fileEnd == nil || p.isShowingHelp {
+		return
+	}
+
+	p.readerLock.Lock()
+	r := p.readers[p.currentReader]
+	p.readerLock.Unlock()
+
+	if !r.ReadingDone.Load() {
+		return
+	}
+
+	if _, exact := p.fileEnd.LinesBefore(); !exact {
+		// Wait for the line numbers, so that we can stay where we are
+		return
+	}
+
+	p.stopShowingFileEnd()
+
+	if p.TargetLine != nil && *p.TargetLine == linemetadata.IndexMax() {
+		// Keep following
+		p.scrollToEnd()
+	}
+}
+
+// Switch from the file end to the full reader. Positions are moved to the same
+// line numbers in the full reader.
+func (p *Pager) stopShowingFileEnd() {
+	if p.fileEnd == nil {
+		return
+	}
+
+	// Line numbers are the same in both readers, but indices aren't
+	scrollNumber := p.lineNumberOf(p.lineIndex())
+	deltaScreenLines := p.deltaScreenLines()
+	markNumbers := map[rune]*linemetadata.Number{}
+	for mark, position := range p.bookmarks {
+		markNumbers[mark] = p.lineNumberOf(position.internalDontTouch.lineIndex)
+	}
+
+	p.readerLock.Lock()
+	p.fileEnd.Stop()
+	p.fileEnd = nil
+	p.filteringReader.SetBackingReader(p.readers[p.currentReader])
+	p.readerLock.Unlock()
+
+	p.scrollPosition = p.scrollPositionOf(scrollNumber, deltaScreenLines)
+	for mark, number := range markNumbers {
+		p.bookmarks[mark] = p.scrollPositionOf(number, 0)
+	}
+
+	// Back to normal reading
+	p.setTargetLine(p.TargetLine)
+
+	select {
+	case p.readerSwitched <- struct{}{}:
+	default:
+	}
+}
+
+// Where a line number is in the possibly filtered view
+func (p *Pager) scrollPositionOf(number *linemetadata.Number, deltaScreenLines int) scrollPosition {
+	position := newScrollPosition("Pager scroll position")
+	if number == nil {
+		return position
+	}
+
+	index := p.filteringReader.indexOf(*number)
+	if index == nil {
+		// Not there, possibly because we're still filtering
+		return position
+	}
+
+	position.internalDontTouch.lineIndex = index
+	position.internalDontTouch.deltaScreenLines = deltaScreenLines
+	return position
+}
@@ -1,19 +1,140 @@
 package internal
 
 import (
+	"path/filepath"
+	"slices"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/styles"
 	log "github.com/sirupsen/logrus"
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/reader"
+	"github.com/walles/moor/v2/internal/search"
 )
 
+// Where we were before opening another file inside the pager
+type backStackEntry struct {
+	readerIndex         int
+	scrollPosition      scrollPosition
+	leftColumnZeroBased int
+}
+
+// What we're looking at in one file, restored when switching back to it
+type viewState struct {
+	scrollPosition      scrollPosition
+	leftColumnZeroBased int
+	filter              Filter
+	search              search.Search
+	bookmarks           map[rune]scrollPosition
+	tableView           tableView
+	fileEnd             *reader.ReaderImpl
+
+	// The scroll position and marks as input line numbers, for the position
+	// history
+	lineNumber linemetadata.Number
+	marks      map[rune]linemetadata.Number
+}
+
 func (p *Pager) previousFile() {
+	p.switchToFile(p.currentReaderIndex() - 1)
+}
+
+func (p *Pager) nextFile() {
+	p.switchToFile(p.currentReaderIndex() + 1)
+}
+
+func (p *Pager) firstFile() {
+	p.switchToFile(0)
+}
+
+// Switch back to the file we were looking at before this one, like vim's ":b#"
+func (p *Pager) lastFile() {
+	p.readerLock.Lock()
+	index := slices.Index(p.readers, p.lastReader)
+	p.readerLock.Unlock()
+
+	if p.lastReader == nil || index < 0 {
+		p.mode = &PagerModeInfo{Pager: p, Text: "No other file to switch to"}
+		return
+	}
+
+	p.switchToFile(index)
+}
+
+func (p *Pager) currentReaderIndex() int {
 	p.readerLock.Lock()
 	defer p.readerLock.Unlock()
 
-	newIndex := p.currentReader - 1
-	if newIndex < 0 {
-		newIndex = 0
+	return p.currentReader
+}
+
+// Switch to another reader, indices outside of the list are clipped
+func (p *Pager) switchToFile(index int) {
+	if p.isShowingHelp {
+		// Otherwise we'd save the help screen position as the file's
+		p.Quit()
+	}
+
+	p.readerLock.Lock()
+	defer p.readerLock.Unlock()
+
+	index = max(0, min(index, len(p.readers)-1))
+	if index == p.currentReader {
+		return
+	}
+
+	p.saveViewStateLocked()
+	p.lastReader = p.readers[p.currentReader]
+	p.currentReader = index
+	log.Tracef("Switched to file index %d", p.currentReader)
+	p.restoreViewStateLocked()
+}
+
+// Must be called with readerLock held
+func (p *Pager) saveViewStateLocked() {
+	lineNumber, marks := p.positionLineNumbers()
+	p.viewStates[p.readers[p.currentReader]] = viewState{
+		scrollPosition:      p.scrollPosition,
+		leftColumnZeroBased: p.leftColumnZeroBased,
+		filter:              p.filter.clone(),
+		search:              p.search,
+		bookmarks:           p.bookmarks,
+		tableView:           p.tableView,
+		fileEnd:             p.fileEnd,
+		lineNumber:          lineNumber,
+		marks:               marks,
+	}
+}
+
+// Show the current reader the way we last saw it, or from the top if it's new
+// to us. Must be called with readerLock held.
+func (p *Pager) restoreViewStateLocked() {
+	r := p.readers[p.currentReader]
+	state, found := p.viewStates[r]
+	if !found {
+		state = viewState{
+			scrollPosition: newScrollPosition("Pager scroll position"),
+
+			// Context lines are more of a preference than part of the filter
+			filter: Filter{contextBefore: p.filter.contextBefore, contextAfter: p.filter.contextAfter},
+
+			bookmarks: make(map[rune]scrollPosition),
+		}
+	}
+
+	p.scrollPosition = state.scrollPosition
+	p.leftColumnZeroBased = state.leftColumnZeroBased
+	p.filter = state.filter
+	p.search = state.search
+	p.bookmarks = state.bookmarks
+	p.tableView = state.tableView
+	p.fileEnd = state.fileEnd
+
+	if p.fileEnd != nil {
+		p.filteringReader.SetBackingReader(p.fileEnd)
+	} else {
+		p.filteringReader.SetBackingReader(r)
 	}
-	p.currentReader = newIndex
-	log.Tracef("Switched to previous file, index %d", p.currentReader)
 
 	select {
 	case p.readerSwitched <- struct{}{}:
@@ -21,32 +142,156 @@ func (p *Pager) previousFile() {
 	}
 }
 
-func (p *Pager) nextFile() {
+// Remove a file from the list of files, like less' ":d"
+func (p *Pager) dropFile(index int) {
+	if p.isShowingHelp {
+		p.Quit()
+	}
+
 	p.readerLock.Lock()
 	defer p.readerLock.Unlock()
 
-	newIndex := p.currentReader + 1
-	if newIndex >= len(p.readers) {
-		newIndex = len(p.readers) - 1
+	if len(p.readers) < 2 {
+		p.mode = &PagerModeInfo{Pager: p, Text: "Can't drop the only file"}
+		return
 	}
-	p.currentReader = newIndex
-	log.Tracef("Switched to next file, index %d", p.currentReader)
 
-	select {
-	case p.readerSwitched <- struct{}{}:
-	default:
+	dropped := p.readers[index]
+	droppedFileEnd := p.viewStates[dropped].fileEnd
+	if index == p.currentReader {
+		droppedFileEnd = p.fileEnd
+	}
+	if droppedFileEnd != nil {
+		droppedFileEnd.Stop()
+	}
+	dropped.Stop()
+
+	p.readers = slices.Delete(p.readers, index, index+1)
+	delete(p.viewStates, dropped)
+	if p.lastReader == dropped {
+		p.lastReader = nil
+	}
+	log.Debugf("Dropped file %d", index)
+
+	// Forget about the dropped file, and keep the other indices valid
+	backStack := []backStackEntry{}
+	for _, entry := range p.backStack {
+		if entry.readerIndex == index {
+			continue
+		}
+		if entry.readerIndex > index {
+			entry.readerIndex--
+		}
+		backStack = append(backStack, entry)
+	}
+	p.backStack = backStack
+
+	if index > p.currentReader {
+		return
 	}
+	if index < p.currentReader {
+		// Still showing the same file
+		p.currentReader--
+		return
+	}
+
+	p.currentReader = min(index, len(p.readers)-1)
+	p.restoreViewStateLocked()
 }
 
-func (p *Pager) firstFile() {
+// Returns the index of an already open reader for this file, or -1
+func (p *Pager) findFile(name string) int {
+	wanted, err := filepath.Abs(name)
+	if err != nil {
+		return -1
+	}
+
 	p.readerLock.Lock()
 	defer p.readerLock.Unlock()
 
-	p.currentReader = 0
-	log.Tracef("Switched to first file, index %d", p.currentReader)
+	for i, r := range p.readers {
+		if r.FileName == nil {
+			continue
+		}
 
-	select {
-	case p.readerSwitched <- struct{}{}:
-	default:
+		candidate, err := filepath.Abs(*r.FileName)
+		if err == nil && candidate == wanted {
+			return i
+		}
 	}
+
+	return -1
+}
+
+// Open a file in a new reader and switch to it. If targetLine is set, we
+// scroll there as soon as it has been read.
+func (p *Pager) openFile(name string, targetLine *linemetadata.Index) error {
+	var formatter chroma.Formatter
+	if p.chromaFormatter != nil {
+		formatter = *p.chromaFormatter
+	}
+
+	style := p.chromaStyle
+	if style == nil {
+		// Without a style, the new reader would wait forever for one
+		style = styles.Fallback
+	}
+
+	newReader, err := reader.NewFromFilename(name, formatter, reader.ReaderOptions{
+		Style:        style,
+		ShouldFormat: p.ShouldFormat,
+		LessOpen:     p.LessOpen,
+	})
+	if err != nil {
+		return err
+	}
+
+	if p.isShowingHelp {
+		p.Quit()
+	}
+
+	p.readerLock.Lock()
+	p.saveViewStateLocked()
+	p.lastReader = p.readers[p.currentReader]
+	p.readers = append(p.readers, newReader)
+	p.currentReader = len(p.readers) - 1
+	p.restoreViewStateLocked()
+	p.readerLock.Unlock()
+	log.Debugf("Opened %s as file %d", name, p.currentReader)
+
+	// Go to where we were last time, unless we have been told otherwise
+	p.TargetLine = targetLine
+	p.restorePosition()
+	p.setTargetLine(p.TargetLine)
+
+	return nil
+}
+
+// Remember where we are, so that goBack() can return here
+func (p *Pager) pushBackStack() {
+	p.backStack = append(p.backStack, backStackEntry{
+		readerIndex:         p.currentReaderIndex(),
+		scrollPosition:      p.scrollPosition,
+		leftColumnZeroBased: p.leftColumnZeroBased,
+	})
+}
+
+// Return to where we were before following the most recent file reference
+func (p *Pager) goBack() {
+	if len(p.backStack) == 0 {
+		p.mode = &PagerModeInfo{Pager: p, Text: "Nothing to go back to, open a file reference with 'l' first"}
+		return
+	}
+
+	entry := p.backStack[len(p.backStack)-1]
+	p.backStack = p.backStack[:len(p.backStack)-1]
+
+	p.switchToFile(entry.readerIndex)
+	log.Debugf("Went back to file %d", entry.readerIndex)
+
+	// Where we were when we followed the reference, not where we last were in
+	// this file
+	p.scrollPosition = entry.scrollPosition
+	p.leftColumnZeroBased = entry.leftColumnZeroBased
+	p.setTargetLine(nil)
 }
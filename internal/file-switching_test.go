@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/reader"
+	"github.com/walles/moor/v2/internal/search"
+	"github.com/walles/moor/v2/twin"
+	"gotest.tools/v3/assert"
+)
+
+func TestViewStatePerFile(t *testing.T) {
+	lines := strings.Repeat("apa\nbepa\n", 20)
+	first := reader.NewFromTextForTesting("first", lines)
+	second := reader.NewFromTextForTesting("second", lines)
+	pager := NewPager(first, second)
+	pager.screen = twin.NewFakeScreen(40, 5)
+	pager.bookmarks = make(map[rune]scrollPosition)
+	assert.NilError(t, first.Wait())
+	assert.NilError(t, second.Wait())
+
+	// Look at the first file in some special way
+	pager.scrollPosition = NewScrollPositionFromIndex(linemetadata.IndexFromZeroBased(7), "test")
+	pager.leftColumnZeroBased = 2
+	pager.search = search.For("bepa")
+	pager.filter = newFilter("apa")
+	pager.bookmarks['a'] = pager.scrollPosition
+
+	// The second file should start out fresh
+	pager.nextFile()
+	assert.Equal(t, pager.currentReader, 1)
+	assert.Equal(t, pager.filteringReader.BackingReader, reader.Reader(second))
+	assert.Equal(t, pager.lineIndex().Index(), 0)
+	assert.Equal(t, pager.leftColumnZeroBased, 0)
+	assert.Assert(t, pager.search.Inactive())
+	assert.Assert(t, !pager.filter.Active())
+	assert.Equal(t, len(pager.bookmarks), 0)
+	pager.search = search.For("cepa")
+
+	// Switching back should restore everything
+	pager.lastFile()
+	assert.Equal(t, pager.currentReader, 0)
+	assert.Equal(t, pager.filteringReader.BackingReader, reader.Reader(first))
+	assert.Equal(t, *pager.scrollPosition.internalDontTouch.lineIndex, linemetadata.IndexFromZeroBased(7))
+	assert.Equal(t, pager.leftColumnZeroBased, 2)
+	assert.Equal(t, pager.search.String(), "bepa")
+	assert.Equal(t, pager.filter.String(), newFilter("apa").String())
+	assert.Equal(t, len(pager.bookmarks), 1)
+
+	// And the last file toggle goes both ways
+	pager.lastFile()
+	assert.Equal(t, pager.currentReader, 1)
+	assert.Equal(t, pager.search.String(), "cepa")
+}
+
+func TestLastFileAfterDrop(t *testing.T) {
+	first := reader.NewFromTextForTesting("first", "apa")
+	second := reader.NewFromTextForTesting("second", "bepa")
+	pager := NewPager(first, second)
+	pager.screen = twin.NewFakeScreen(40, 5)
+
+	pager.lastFile()
+	_, ok := pager.mode.(*PagerModeInfo)
+	assert.Assert(t, ok, "No last file yet")
+
+	pager.nextFile()
+	pager.dropFile(0)
+	assert.Equal(t, pager.currentReader, 0)
+	assert.Equal(t, pager.readers[0], second)
+
+	pager.mode = PagerModeViewing{pager: pager}
+	pager.lastFile()
+	_, ok = pager.mode.(*PagerModeInfo)
+	assert.Assert(t, ok, "The last file was dropped")
+}
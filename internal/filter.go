@@ -0,0 +1,235 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/walles/moor/v2/internal/reader"
+	"github.com/walles/moor/v2/internal/search"
+)
+
+// Prefix a filter pattern with this to show only lines that do *not* match,
+// just like in less.
+const filterNegationPrefix = "!"
+
+// Filter decides which lines to show while filtering. Lines are shown only if
+// all patterns accept them.
+type Filter struct {
+	// Oldest first. In filtering mode, the last pattern is the one being
+	// edited.
+	patterns []filterPattern
+
+	// Like grep's -B and -A, show this many lines around each match
+	contextBefore int
+	contextAfter  int
+
+	// Hide log lines less severe than this. Lines without a level, like stack
+	// traces, get the level of the line before them.
+	logFormat   reader.LogFormat
+	minLogLevel reader.LogLevel
+}
+
+// The minimum log levels cycled through by cycleLogLevel()
+var logLevelSteps = []reader.LogLevel{
+	reader.LogLevelUnknown,
+	reader.LogLevelDebug,
+	reader.LogLevelInfo,
+	reader.LogLevelWarn,
+	reader.LogLevelError,
+}
+
+type filterPattern struct {
+	search search.Search
+
+	// Accept lines that don't match
+	negated bool
+}
+
+func parseFilterPattern(text string) filterPattern {
+	withoutPrefix, negated := strings.CutPrefix(text, filterNegationPrefix)
+	return filterPattern{
+		search:  search.For(withoutPrefix),
+		negated: negated,
+	}
+}
+
+func (pattern filterPattern) String() string {
+	if pattern.negated {
+		return filterNegationPrefix + pattern.search.String()
+	}
+	return pattern.search.String()
+}
+
+// Inactive patterns accept all lines
+func (pattern filterPattern) accepts(line string) bool {
+	if pattern.search.Inactive() {
+		return true
+	}
+
+	return pattern.search.Matches(line) != pattern.negated
+}
+
+// Create a filter from pattern texts, see parseFilterPattern()
+func newFilter(patterns ...string) Filter {
+	filter := Filter{}
+	for _, pattern := range patterns {
+		filter.patterns = append(filter.patterns, parseFilterPattern(pattern))
+	}
+	return filter
+}
+
+// True if any pattern or the log level will hide lines
+func (f Filter) Active() bool {
+	if f.filtersLogLevel() {
+		return true
+	}
+	return slices.ContainsFunc(f.patterns, func(pattern filterPattern) bool {
+		return pattern.search.Active()
+	})
+}
+
+func (f Filter) filtersLogLevel() bool {
+	return f.minLogLevel != reader.LogLevelUnknown
+}
+
+// Step to the next minimum log level, and back to showing all levels after the
+// most severe one
+func (f *Filter) cycleLogLevel(format reader.LogFormat) {
+	f.logFormat = format
+	next := (slices.Index(logLevelSteps, f.minLogLevel) + 1) % len(logLevelSteps)
+	f.minLogLevel = logLevelSteps[next]
+}
+
+// Stop filtering by log level, the next input may not be a log
+func (f *Filter) clearLogLevel() {
+	f.logFormat = reader.LogFormatNone
+	f.minLogLevel = reader.LogLevelUnknown
+}
+
+func (f Filter) Accepts(line string) bool {
+	for _, pattern := range f.patterns {
+		if !pattern.accepts(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// Two filters are equal if they show the same lines
+func (f Filter) Equals(other Filter) bool {
+	if f.contextBefore != other.contextBefore || f.contextAfter != other.contextAfter {
+		return false
+	}
+	if f.logFormat != other.logFormat || f.minLogLevel != other.minLogLevel {
+		return false
+	}
+	return slices.Equal(f.activePatternStrings(), other.activePatternStrings())
+}
+
+func (f Filter) hasContext() bool {
+	return f.contextBefore > 0 || f.contextAfter > 0
+}
+
+// Like grep options: "-C3", "-B1 -A2", or empty if there is no context
+func (f Filter) contextString() string {
+	if f.contextBefore == f.contextAfter && f.hasContext() {
+		return fmt.Sprintf("-C%d", f.contextBefore)
+	}
+
+	options := []string{}
+	if f.contextBefore > 0 {
+		options = append(options, fmt.Sprintf("-B%d", f.contextBefore))
+	}
+	if f.contextAfter > 0 {
+		options = append(options, fmt.Sprintf("-A%d", f.contextAfter))
+	}
+	return strings.Join(options, " ")
+}
+
+// ParseFilterContext parses "3" into 3 lines before and after, or "1,2" into 1
+// line before and 2 after.
+func ParseFilterContext(s string) (before int, after int, err error) {
+	beforeString, afterString, hasComma := strings.Cut(strings.TrimSpace(s), ",")
+	if !hasComma {
+		afterString = beforeString
+	}
+
+	before, err = strconv.Atoi(strings.TrimSpace(beforeString))
+	if err != nil || before < 0 {
+		return 0, 0, fmt.Errorf("not a line count: %q", beforeString)
+	}
+	after, err = strconv.Atoi(strings.TrimSpace(afterString))
+	if err != nil || after < 0 {
+		return 0, 0, fmt.Errorf("not a line count: %q", afterString)
+	}
+
+	return before, after, nil
+}
+
+func (f Filter) activePatternStrings() []string {
+	strs := []string{}
+	for _, pattern := range f.patterns {
+		if pattern.search.Active() {
+			strs = append(strs, pattern.String())
+		}
+	}
+	return strs
+}
+
+// Like "[ERROR] [!healthcheck] level>=WARN", empty if nothing is filtered
+func (f Filter) String() string {
+	parts := []string{}
+	if strs := f.activePatternStrings(); len(strs) > 0 {
+		parts = append(parts, "["+strings.Join(strs, "] [")+"]")
+	}
+	if f.filtersLogLevel() {
+		parts = append(parts, "level>="+f.minLogLevel.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// Get a copy that won't change when this one is edited
+func (f Filter) clone() Filter {
+	clone := f
+	clone.patterns = slices.Clone(f.patterns)
+	return clone
+}
+
+// Add an empty pattern to the end, to be edited with setLast()
+func (f *Filter) push() {
+	f.patterns = append(f.patterns, filterPattern{})
+}
+
+// Replace the last pattern, or add one if there are none
+func (f *Filter) setLast(text string) {
+	if len(f.patterns) == 0 {
+		f.push()
+	}
+	f.patterns[len(f.patterns)-1] = parseFilterPattern(text)
+}
+
+// Remove the last pattern, if any
+func (f *Filter) pop() {
+	if len(f.patterns) == 0 {
+		return
+	}
+	f.patterns = f.patterns[:len(f.patterns)-1]
+}
+
+// Remove the pattern before the last one, if any
+func (f *Filter) dropPrevious() {
+	if len(f.patterns) < 2 {
+		return
+	}
+	f.patterns = slices.Delete(f.patterns, len(f.patterns)-2, len(f.patterns)-1)
+}
+
+// The last pattern, or an inactive one if there are none
+func (f Filter) last() filterPattern {
+	if len(f.patterns) == 0 {
+		return filterPattern{}
+	}
+	return f.patterns[len(f.patterns)-1]
+}
@@ -0,0 +1,450 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/reader"
+	"github.com/walles/moor/v2/twin"
+	"gotest.tools/v3/assert"
+)
+
+func TestFilterAccepts(t *testing.T) {
+	filter := newFilter("error", "!healthcheck")
+	assert.Assert(t, filter.Active())
+	assert.Equal(t, filter.String(), "[error] [!healthcheck]")
+
+	assert.Assert(t, filter.Accepts("ERROR: disk full"))
+	assert.Assert(t, !filter.Accepts("ERROR: healthcheck failed"))
+	assert.Assert(t, !filter.Accepts("INFO: all good"))
+
+	// Inactive patterns accept everything
+	assert.Assert(t, !newFilter("", "!").Active())
+	assert.Assert(t, newFilter("", "!").Accepts("anything"))
+}
+
+func TestFilterEquals(t *testing.T) {
+	assert.Assert(t, newFilter("a", "", "!b").Equals(newFilter("a", "!b")))
+	assert.Assert(t, !newFilter("a", "b").Equals(newFilter("a", "!b")))
+	assert.Assert(t, Filter{}.Equals(newFilter("")))
+}
+
+func TestFilterEditing(t *testing.T) {
+	filter := newFilter("a", "b")
+	cached := filter.clone()
+
+	filter.push()
+	filter.setLast("!c")
+	assert.Equal(t, filter.String(), "[a] [b] [!c]")
+
+	filter.dropPrevious()
+	assert.Equal(t, filter.String(), "[a] [!c]")
+
+	filter.pop()
+	assert.Equal(t, filter.String(), "[a]")
+
+	// Editing must not affect clones
+	assert.Equal(t, cached.String(), "[a] [b]")
+}
+
+func TestFilteringReaderStack(t *testing.T) {
+	backing := reader.NewFromTextForTesting("TestFilteringReaderStack",
+		"ERROR one\nINFO two\nERROR healthcheck\nERROR four")
+	assert.NilError(t, backing.Wait())
+
+	filter := newFilter("ERROR", "!health")
+	filteringReader := FilteringReader{
+		BackingReader: backing,
+		Filter:        &filter,
+	}
+
+	lines := filteringReader.GetLines(linemetadata.Index{}, 10)
+	assert.Equal(t, len(lines.Lines), 2)
+	assert.Equal(t, lines.Lines[0].Line.Plain(lines.Lines[0].Index), "ERROR one")
+	assert.Equal(t, lines.Lines[1].Line.Plain(lines.Lines[1].Index), "ERROR four")
+	assert.Equal(t, lines.Lines[1].Number, linemetadata.NumberFromOneBased(4))
+	assert.Equal(t, lines.StatusText, "Filtered [ERROR] [!health]: 2/4 lines, 2 hidden  100%")
+
+	// Changing the filter should invalidate the cache
+	filter.pop()
+	assert.Equal(t, filteringReader.GetLineCount(), 3)
+}
+
+func TestFilterModeStacking(t *testing.T) {
+	reader := reader.NewFromTextForTesting("TestFilterModeStacking", "apa\nbepa\ncepa")
+	pager := NewPager(reader)
+	pager.screen = twin.NewFakeScreen(40, 5)
+	assert.NilError(t, reader.Wait())
+
+	typeFilter := func(text string) {
+		pager.mode = PagerModeViewing{pager: pager}
+		pager.mode.onRune('&')
+		for _, char := range text {
+			pager.mode.onRune(char)
+		}
+		pager.mode.onKey(twin.KeyEnter)
+	}
+
+	typeFilter("pa")
+	typeFilter("!b")
+	assert.Equal(t, pager.filter.String(), "[pa] [!b]")
+	assert.Equal(t, pager.filteringReader.GetLineCount(), 2)
+
+	// ESC drops only the filter being typed
+	pager.mode.onRune('&')
+	pager.mode.onRune('x')
+	pager.mode.onKey(twin.KeyEscape)
+	assert.Equal(t, pager.filter.String(), "[pa] [!b]")
+
+	// Backspace in an empty filter removes the previous one
+	pager.mode.onRune('&')
+	pager.mode.onKey(twin.KeyBackspace)
+	pager.mode.onKey(twin.KeyEscape)
+	assert.Equal(t, pager.filter.String(), "[pa]")
+	assert.Assert(t, pager.isViewing())
+
+	// Submitting an empty filter adds nothing
+	typeFilter("")
+	assert.Equal(t, pager.filter.String(), "[pa]")
+	assert.Assert(t, pager.isViewing())
+}
+
+func TestParseFilterContext(t *testing.T) {
+	before, after, err := ParseFilterContext("3")
+	assert.NilError(t, err)
+	assert.Equal(t, before, 3)
+	assert.Equal(t, after, 3)
+
+	before, after, err = ParseFilterContext(" 1, 2 ")
+	assert.NilError(t, err)
+	assert.Equal(t, before, 1)
+	assert.Equal(t, after, 2)
+
+	_, _, err = ParseFilterContext("-1")
+	assert.Error(t, err, `not a line count: "-1"`)
+
+	assert.Equal(t, Filter{contextBefore: 2, contextAfter: 2}.contextString(), "-C2")
+	assert.Equal(t, Filter{contextAfter: 2}.contextString(), "-A2")
+	assert.Equal(t, Filter{contextBefore: 1, contextAfter: 2}.contextString(), "-B1 -A2")
+}
+
+func TestFilteringReaderContext(t *testing.T) {
+	backing := reader.NewFromTextForTesting("TestFilteringReaderContext",
+		"1\n2 match\n3\n4\n5\n6\n7 match\n8 match\n9")
+	assert.NilError(t, backing.Wait())
+
+	filter := newFilter("match")
+	filter.contextBefore = 1
+	filter.contextAfter = 1
+	filteringReader := FilteringReader{
+		BackingReader: backing,
+		Filter:        &filter,
+	}
+
+	type expectedLine struct {
+		number     int
+		isContext  bool
+		followsGap bool
+	}
+	expected := []expectedLine{
+		{1, true, false},
+		{2, false, false},
+		{3, true, false},
+		{6, true, true},
+		{7, false, false},
+		{8, false, false},
+		{9, true, false},
+	}
+
+	lines := filteringReader.GetLines(linemetadata.Index{}, 100)
+	assert.Equal(t, len(lines.Lines), len(expected))
+	for i, line := range lines.Lines {
+		assert.Equal(t, line.Index, linemetadata.IndexFromZeroBased(i))
+		assert.Equal(t, line.Number, linemetadata.NumberFromOneBased(expected[i].number))
+		assert.Equal(t, line.IsContext, expected[i].isContext, "line %d", expected[i].number)
+		assert.Equal(t, line.FollowsGap, expected[i].followsGap, "line %d", expected[i].number)
+	}
+	assert.Equal(t, lines.StatusText, "Filtered [match] -C1: 7/9 lines, 2 hidden  100%")
+}
+
+func TestRenderFilterContext(t *testing.T) {
+	reader := reader.NewFromTextForTesting("TestRenderFilterContext", "a\nmatch\nb\nc\nmatch")
+	pager := NewPager(reader)
+	pager.screen = twin.NewFakeScreen(20, 10)
+	pager.ShowLineNumbers = false
+	pager.showLineNumbers = false
+	assert.NilError(t, reader.Wait())
+
+	pager.filter = newFilter("match")
+	pager.filter.contextBefore = 1
+
+	rendered := pager.renderLines()
+	lines := []string{}
+	for _, line := range rendered.lines {
+		lines = append(lines, renderedToString(line.cells))
+	}
+	assert.DeepEqual(t, lines, []string{"a", "match", "--", "c", "match"})
+
+	// Context lines should be dimmed, matches not
+	assert.Assert(t, rendered.lines[0].cells[0].Style.Equal(plainTextStyle.WithAttr(twin.AttrDim)))
+	assert.Assert(t, !rendered.lines[1].cells[0].Style.Equal(plainTextStyle.WithAttr(twin.AttrDim)))
+}
+
+func TestChangeFilterKeepsPosition(t *testing.T) {
+	reader := reader.NewFromTextForTesting("TestChangeFilterKeepsPosition", "apa\nbepa\ncepa\ndepa\nepa\nfepa\ngepa")
+	pager := NewPager(reader)
+	pager.screen = twin.NewFakeScreen(20, 3)
+	assert.NilError(t, reader.Wait())
+
+	// Show "depa", the second line of the filtered view, at the top
+	pager.filter = newFilter("!bepa")
+	pager.scrollPosition = NewScrollPositionFromIndex(linemetadata.IndexFromZeroBased(2), "test")
+	assert.Equal(t, *pager.lineNumberOf(pager.lineIndex()), linemetadata.NumberFromOneBased(4))
+
+	// Removing the filter should keep "depa" at the top
+	pager.changeFilter(func(filter *Filter) {
+		filter.patterns = nil
+	})
+	assert.Equal(t, pager.lineIndex().Index(), 3)
+
+	// Hiding "depa" should move us to the line after it
+	pager.changeFilter(func(filter *Filter) {
+		filter.setLast("!depa")
+	})
+	assert.Equal(t, *pager.lineNumberOf(pager.lineIndex()), linemetadata.NumberFromOneBased(5))
+}
+
+// Wait for background filtering to finish
+func waitForFiltering(t *testing.T, filteringReader *FilteringReader) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		// Restarts filtering if lines were added while it was running
+		filteringReader.GetLineCount()
+
+		if filteringReader.filteringPercent() == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Background filtering never finished")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFilteringInBackground(t *testing.T) {
+	defer func(threshold int) { filterInBackgroundThreshold = threshold }(filterInBackgroundThreshold)
+	filterInBackgroundThreshold = 10
+
+	lines := []string{}
+	for i := range 3*filterChunkSize + 17 {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	backing := reader.NewFromTextForTesting("TestFilteringInBackground", strings.Join(lines, "\n"))
+	assert.NilError(t, backing.Wait())
+
+	filter := newFilter("7$")
+	filteringReader := NewFilteringReader(backing, &filter)
+
+	// This should start filtering in the background...
+	filteringReader.GetLineCount()
+
+	// ... and tell us about it
+	select {
+	case <-filteringReader.Progress:
+	case <-time.After(10 * time.Second):
+		t.Fatal("No progress notification")
+	}
+	waitForFiltering(t, &filteringReader)
+	assert.Equal(t, filteringReader.GetLineCount(), len(lines)/10)
+
+	for i, line := range filteringReader.GetLines(linemetadata.Index{}, math.MaxInt).Lines {
+		assert.Equal(t, line.Index, linemetadata.IndexFromZeroBased(i))
+		assert.Equal(t, line.Number, linemetadata.NumberFromZeroBased(i*10+7))
+	}
+}
+
+func TestFilteringStatusInBackground(t *testing.T) {
+	backing := reader.NewFromTextForTesting("TestFilteringStatusInBackground", "apa\nbepa\ncepa\ndepa")
+	assert.NilError(t, backing.Wait())
+
+	filter := newFilter("apa")
+	filteringReader := NewFilteringReader(backing, &filter)
+
+	// Pretend we're halfway through
+	filteringReader.scan = newFilterScan(filter.clone(), backing)
+	filteringReader.scan.inBackground = true
+	filteringReader.scan.appendAccepted(filteringReader.scan.filterLines(
+		backing.GetLines(linemetadata.Index{}, 2).Lines), 2)
+
+	assert.Equal(t, filteringReader.GetLines(linemetadata.Index{}, 10).StatusText,
+		"Filtered [apa]: 1 line so far, filtering 50%...")
+}
+
+func TestFilteringCancelledByNewFilter(t *testing.T) {
+	defer func(threshold int) { filterInBackgroundThreshold = threshold }(filterInBackgroundThreshold)
+	filterInBackgroundThreshold = 10
+
+	backing := reader.NewFromTextForTesting("TestFilteringCancelledByNewFilter",
+		strings.Repeat("apa\nbepa\n", 2*filterChunkSize))
+	assert.NilError(t, backing.Wait())
+
+	filter := newFilter("apa")
+	filteringReader := NewFilteringReader(backing, &filter)
+	filteringReader.GetLineCount()
+	oldScan := filteringReader.scan
+
+	// Changing the filter should cancel the old scan and start a new one
+	filter.setLast("bepa")
+	filteringReader.GetLineCount()
+	assert.Assert(t, oldScan.cancelled.Load())
+	assert.Assert(t, filteringReader.scan != oldScan)
+
+	waitForFiltering(t, &filteringReader)
+	assert.Equal(t, filteringReader.GetLineCount(), 2*filterChunkSize)
+}
+
+func TestSwitchBackingReaderWhileFiltering(t *testing.T) {
+	defer func(threshold int) { filterInBackgroundThreshold = threshold }(filterInBackgroundThreshold)
+	filterInBackgroundThreshold = 10
+
+	oldBacking := reader.NewFromTextForTesting("old", strings.Repeat("apa\nbepa\n", 5*filterChunkSize))
+	assert.NilError(t, oldBacking.Wait())
+	newBacking := reader.NewFromTextForTesting("new", strings.Repeat("apa\n", 3*filterChunkSize))
+	assert.NilError(t, newBacking.Wait())
+
+	filter := newFilter("apa")
+	filteringReader := NewFilteringReader(oldBacking, &filter)
+
+	// Switching while a scan is running should cancel it, and the new scan
+	// should only see the new reader. Run with -race to check that cancelled
+	// scans don't touch the backing reader field.
+	for i := range 20 {
+		filteringReader.SetBackingReader(oldBacking)
+		filteringReader.GetLineCount()
+		oldScan := filteringReader.scan
+		time.Sleep(time.Duration(i*50) * time.Microsecond)
+
+		filteringReader.SetBackingReader(newBacking)
+		assert.Assert(t, oldScan.cancelled.Load())
+	}
+
+	waitForFiltering(t, &filteringReader)
+	assert.Equal(t, filteringReader.GetLineCount(), 3*filterChunkSize)
+}
+
+// Shows only the first lineCount lines of the backing reader, for simulating
+// lines being added
+type growingReader struct {
+	backing   *reader.ReaderImpl
+	lineCount int
+}
+
+func (r *growingReader) GetLineCount() int {
+	return r.lineCount
+}
+
+func (r *growingReader) GetLine(index linemetadata.Index) *reader.NumberedLine {
+	if index.Index() >= r.lineCount {
+		return nil
+	}
+	return r.backing.GetLine(index)
+}
+
+func (r *growingReader) GetLines(firstLine linemetadata.Index, wantedLineCount int) reader.InputLines {
+	return r.backing.GetLines(firstLine, min(wantedLineCount, r.lineCount-firstLine.Index()))
+}
+
+func (r *growingReader) GetLinesPreallocated(firstLine linemetadata.Index, resultLines *[]reader.NumberedLine) (string, string) {
+	panic("Unexpected call to growingReader.GetLinesPreallocated()")
+}
+
+func (r *growingReader) ShouldShowLineCount() bool {
+	return true
+}
+
+func TestFilteringIncrementally(t *testing.T) {
+	backing := reader.NewFromTextForTesting("TestFilteringIncrementally", "apa\nbepa\ncepa\nnope\ndepa")
+	assert.NilError(t, backing.Wait())
+	growing := &growingReader{backing: backing, lineCount: 2}
+
+	filter := newFilter("pa")
+	filteringReader := NewFilteringReader(growing, &filter)
+	assert.Equal(t, filteringReader.GetLineCount(), 2)
+	scan := filteringReader.scan
+	assert.Equal(t, scan.scannedCount, 2)
+
+	// Appending lines should reuse the existing scan
+	growing.lineCount = 5
+	assert.Equal(t, filteringReader.GetLineCount(), 4)
+	assert.Assert(t, filteringReader.scan == scan)
+	assert.Equal(t, scan.scannedCount, 5)
+}
+
+func TestFilterByLogLevel(t *testing.T) {
+	reader := reader.NewFromTextForTesting("TestFilterByLogLevel", strings.Join([]string{
+		"level=debug msg=one",
+		"level=info msg=two",
+		"level=warn msg=three",
+		"  at somewhere.go:42",
+		"level=error msg=four",
+		"level=info msg=five",
+	}, "\n"))
+	pager := NewPager(reader)
+	pager.screen = twin.NewFakeScreen(60, 10)
+	assert.NilError(t, reader.Wait())
+	pager.mode = PagerModeViewing{pager: pager}
+
+	plainLines := func() []string {
+		lines := []string{}
+		for _, line := range pager.Reader().GetLines(linemetadata.Index{}, 100).Lines {
+			lines = append(lines, line.Plain())
+		}
+		return lines
+	}
+
+	pager.mode.onRune('L')
+	assert.Equal(t, pager.filter.String(), "level>=DEBUG")
+	assert.Equal(t, len(plainLines()), 6)
+
+	pager.mode.onRune('L')
+	pager.mode.onRune('L')
+	assert.Equal(t, pager.filter.String(), "level>=WARN")
+
+	// The stack trace line belongs to the WARN line before it
+	assert.DeepEqual(t, plainLines(), []string{
+		"level=warn msg=three",
+		"  at somewhere.go:42",
+		"level=error msg=four",
+	})
+
+	// Patterns and log levels combine
+	pager.filter.push()
+	pager.filter.setLast("four")
+	assert.Equal(t, pager.filter.String(), "[four] level>=WARN")
+	assert.DeepEqual(t, plainLines(), []string{"level=error msg=four"})
+	pager.filter.pop()
+
+	pager.mode.onRune('L')
+	pager.mode.onRune('L')
+	assert.Assert(t, !pager.filter.Active())
+	assert.Equal(t, len(plainLines()), 6)
+}
+
+func TestFilterByLogLevelNotALog(t *testing.T) {
+	reader := reader.NewFromTextForTesting("TestFilterByLogLevelNotALog", "apa\nbepa")
+	pager := NewPager(reader)
+	pager.screen = twin.NewFakeScreen(60, 10)
+	assert.NilError(t, reader.Wait())
+	pager.mode = PagerModeViewing{pager: pager}
+
+	pager.mode.onRune('L')
+	_, isInfo := pager.mode.(*PagerModeInfo)
+	assert.Assert(t, isInfo)
+	assert.Assert(t, !pager.filter.Active())
+}
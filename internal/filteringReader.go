@@ -3,13 +3,16 @@ package internal
 import (
 	"fmt"
 	"math"
+	"runtime/debug"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/walles/moor/v2/internal/linemetadata"
 	"github.com/walles/moor/v2/internal/reader"
-	"github.com/walles/moor/v2/internal/search"
+	"github.com/walles/moor/v2/internal/util"
 )
 
 // Filters lines based on the search query from the pager.
@@ -17,98 +20,316 @@ import (
 type FilteringReader struct {
 	BackingReader reader.Reader
 
-	// This is a reference so that we can track changes to the original pattern,
-	// including if it is set to nil.
-	Filter *search.Search
+	// This is a reference so that we can track changes to the original
+	// filter, including if it is set to nil.
+	Filter *Filter
 
-	// Protects filteredLinesCache, unfilteredLineCountWhenCaching, and
-	// filterPatternWhenCaching.
+	// Receives a value when background filtering has made progress. Use
+	// NewFilteringReader() to get this initialized.
+	Progress chan bool
+
+	// Protects scan
 	lock sync.Mutex
 
 	// nil means no filtering has happened yet
-	filteredLinesCache *[]reader.NumberedLine
+	scan *filterScan
+}
+
+// Up to this many new lines are filtered right away, more than that and we
+// filter in the background. Tests may lower this to exercise the background
+// code path.
+var filterInBackgroundThreshold = 50_000
+
+// Background filtering works in chunks of this many lines
+const filterChunkSize = 10_000
+
+// Filtering state for one Filter. Lines added to the backing reader are
+// filtered incrementally. When the filter changes, the scan is cancelled and a
+// new one is started.
+type filterScan struct {
+	filter Filter
+
+	// The reader being filtered. Our own copy, so that the background
+	// goroutine isn't affected by SetBackingReader().
+	backingReader reader.Reader
+
+	// The lines accepted so far, including context lines
+	acceptedLines []reader.NumberedLine
 
-	// This is what the reader's line count was when we filtered. If the
-	// reader's current line count doesn't match, then our cache needs to be
-	// rebuilt.
-	unfilteredLineCountWhenCaching int
+	// Index of the first accepted line. Accepted lines that have been dropped
+	// from the backing reader are forgotten, see --max-lines.
+	firstIndex int
 
-	// This is the pattern that was used when we cached the lines. If it
-	// doesn't match the current pattern, then our cache needs to be rebuilt.
-	filterWhenCaching search.Search
+	// How many backing reader lines have been filtered so far
+	scannedCount int
+
+	// Base line index of the last accepted line, -1 if none
+	lastAdded int
+
+	// How many more lines should be accepted as context after the last match
+	contextAfterLeft int
+
+	// Level of the last log line with one, for lines without a level
+	lastLogLevel reader.LogLevel
+
+	// Set while a background goroutine is filtering
+	inBackground bool
+
+	cancelled atomic.Bool
 }
 
+func NewFilteringReader(backingReader reader.Reader, filter *Filter) FilteringReader {
+	return FilteringReader{
+		BackingReader: backingReader,
+		Filter:        filter,
+		Progress:      make(chan bool, 1),
+	}
+}
+
+func newFilterScan(filter Filter, backingReader reader.Reader) *filterScan {
+	return &filterScan{
+		filter:        filter,
+		backingReader: backingReader,
+		lastAdded:     -1,
+	}
+}
+
+// Filter a chunk of consecutive backing reader lines following the previously
+// filtered ones. Returns the accepted lines, with Index fields to be filled in
+// by the caller.
+//
+// Only one goroutine at a time may call this method on any given scan.
+func (scan *filterScan) filterLines(lines []reader.NumberedLine) []reader.NumberedLine {
+	accepted := []reader.NumberedLine{}
+	addLine := func(line reader.NumberedLine, isContext bool) {
+		baseIndex := line.Index.Index()
+		line.IsContext = isContext
+		line.FollowsGap = scan.filter.hasContext() && scan.lastAdded >= 0 && baseIndex != scan.lastAdded+1
+		accepted = append(accepted, line)
+		scan.lastAdded = baseIndex
+	}
+
+	for _, line := range lines {
+		plain := line.Line.Plain(line.Index)
+		if scan.filter.filtersLogLevel() {
+			if level := scan.filter.logFormat.Level(plain); level != reader.LogLevelUnknown {
+				scan.lastLogLevel = level
+			}
+		}
+
+		if !scan.filter.Accepts(plain) || scan.lastLogLevel < scan.filter.minLogLevel {
+			if scan.contextAfterLeft > 0 {
+				addLine(line, true)
+				scan.contextAfterLeft--
+			}
+			continue
+		}
+
+		baseIndex := line.Index.Index()
+		for before := max(scan.lastAdded+1, baseIndex-scan.filter.contextBefore); before < baseIndex; before++ {
+			contextLine := scan.backingReader.GetLine(linemetadata.IndexFromZeroBased(before))
+			if contextLine != nil {
+				addLine(*contextLine, true)
+			}
+		}
+		addLine(line, false)
+		scan.contextAfterLeft = scan.filter.contextAfter
+	}
+
+	return accepted
+}
+
+// How many backing reader lines we have gotten past by getting these lines,
+// which are the ones we asked for starting at firstLine. Lines dropped because
+// of --max-lines are skipped without being returned.
+func scannedCountOf(lines []reader.NumberedLine, firstLine int) int {
+	if len(lines) == 0 {
+		return 0
+	}
+
+	return lines[len(lines)-1].Index.Index() + 1 - firstLine
+}
+
+// Please hold the lock when calling this method.
+func (scan *filterScan) appendAccepted(accepted []reader.NumberedLine, scannedCount int) {
+	for _, line := range accepted {
+		line.Index = linemetadata.IndexFromZeroBased(scan.firstIndex + len(scan.acceptedLines))
+		scan.acceptedLines = append(scan.acceptedLines, line)
+	}
+	scan.scannedCount += scannedCount
+}
+
+// Forget accepted lines that have been dropped from the backing reader. Their
+// indices stay in use, like for dropped lines in the backing reader.
+//
 // Please hold the lock when calling this method.
-func (f *FilteringReader) rebuildCache() {
+func (scan *filterScan) forgetDroppedLines() {
+	backingReader, ok := scan.backingReader.(*reader.ReaderImpl)
+	if !ok {
+		return
+	}
+
+	dropped := backingReader.DroppedLineCount()
+	count, _ := slices.BinarySearchFunc(scan.acceptedLines, dropped, func(line reader.NumberedLine, target int) int {
+		return line.Number.AsZeroBased() - target
+	})
+	if count == 0 {
+		return
+	}
+
+	scan.acceptedLines = scan.acceptedLines[count:]
+	scan.firstIndex += count
+}
+
+// Please hold the lock when calling this method.
+func (f *FilteringReader) cancelScan() {
+	if f.scan != nil {
+		f.scan.cancelled.Store(true)
+	}
+	f.scan = nil
+}
+
+// Make sure the scan is for the current filter, and that it covers all
+// backing reader lines, or is working on it in the background.
+//
+// Please hold the lock when calling this method.
+func (f *FilteringReader) updateScan() {
+	baseCount := f.BackingReader.GetLineCount()
+
+	if f.scan == nil || !f.Filter.Equals(f.scan.filter) || baseCount < f.scan.scannedCount {
+		f.cancelScan()
+		f.scan = newFilterScan(f.Filter.clone(), f.BackingReader)
+	}
+
+	scan := f.scan
+	scan.forgetDroppedLines()
+	if scan.inBackground || scan.scannedCount >= baseCount {
+		// Nothing for us to do right now
+		return
+	}
+
+	if baseCount-scan.scannedCount > filterInBackgroundThreshold {
+		scan.inBackground = true
+		go f.filterInBackground(scan)
+		return
+	}
+
 	t0 := time.Now()
+	newLines := scan.backingReader.GetLines(linemetadata.IndexFromZeroBased(scan.scannedCount), baseCount-scan.scannedCount)
+	scan.appendAccepted(scan.filterLines(newLines.Lines), scannedCountOf(newLines.Lines, scan.scannedCount))
 
-	cache := make([]reader.NumberedLine, 0)
-	filter := *f.Filter
+	log.Debugf("Filtered %d new lines in %s, %d/%d lines accepted",
+		len(newLines.Lines), time.Since(t0), len(scan.acceptedLines), scan.scannedCount)
+}
 
-	// Mark cache base conditions
-	f.unfilteredLineCountWhenCaching = f.BackingReader.GetLineCount()
-	f.filterWhenCaching = filter
+// Filter all lines not yet filtered, in chunks so that the UI can show
+// progress. Stops when the scan is cancelled.
+func (f *FilteringReader) filterInBackground(scan *filterScan) {
+	defer func() {
+		PanicHandler("FilteringReader.filterInBackground()", recover(), debug.Stack())
+	}()
 
-	// Repopulate the cache
-	allBaseLines := f.BackingReader.GetLines(linemetadata.Index{}, math.MaxInt)
-	resultIndex := 0
-	for _, line := range allBaseLines.Lines {
-		if filter.Active() && !filter.Matches(line.Line.Plain(line.Index)) {
-			// We have a pattern but it doesn't match
-			continue
+	t0 := time.Now()
+	lastNotification := time.Now()
+	for !scan.cancelled.Load() {
+		f.lock.Lock()
+		firstLine := scan.scannedCount
+		f.lock.Unlock()
+
+		// Don't ask for lines past the end, GetLines() would give us earlier
+		// lines instead
+		chunkSize := min(filterChunkSize, scan.backingReader.GetLineCount()-firstLine)
+		if chunkSize <= 0 {
+			// Caught up
+			break
+		}
+
+		chunk := scan.backingReader.GetLines(linemetadata.IndexFromZeroBased(firstLine), chunkSize)
+
+		accepted := scan.filterLines(chunk.Lines)
+
+		f.lock.Lock()
+		if !scan.cancelled.Load() {
+			scan.appendAccepted(accepted, scannedCountOf(chunk.Lines, firstLine))
 		}
+		f.lock.Unlock()
 
-		cache = append(cache, reader.NumberedLine{
-			Line:   line.Line,
-			Index:  linemetadata.IndexFromZeroBased(resultIndex),
-			Number: line.Number,
-		})
-		resultIndex++
+		if time.Since(lastNotification) > 100*time.Millisecond {
+			notify(f.Progress)
+			lastNotification = time.Now()
+		}
 	}
 
-	f.filteredLinesCache = &cache
+	f.lock.Lock()
+	scan.inBackground = false
+	acceptedCount := len(scan.acceptedLines)
+	scannedCount := scan.scannedCount
+	f.lock.Unlock()
+
+	if scan.cancelled.Load() {
+		log.Debugf("Background filtering cancelled after %s", time.Since(t0))
+		return
+	}
 
-	log.Debugf("Filtered out %d/%d lines in %s",
-		len(allBaseLines.Lines)-len(cache), len(allBaseLines.Lines), time.Since(t0))
+	log.Debugf("Background filtering done in %s, %d/%d lines accepted",
+		time.Since(t0), acceptedCount, scannedCount)
+
+	// Lines added while we were working will be picked up by the next
+	// updateScan() call, which this notification will trigger
+	notify(f.Progress)
 }
 
-func (f *FilteringReader) getAllLines() []reader.NumberedLine {
+// Lines accepted so far, and the index of the first one. While filtering in
+// the background, more lines may be added later. Lines before the first one
+// have been dropped, see --max-lines.
+func (f *FilteringReader) getAllLines() ([]reader.NumberedLine, int) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
-	if f.filteredLinesCache == nil {
-		f.rebuildCache()
-		return *f.filteredLinesCache
-	}
+	f.updateScan()
+	return f.scan.acceptedLines, f.scan.firstIndex
+}
 
-	if f.unfilteredLineCountWhenCaching != f.BackingReader.GetLineCount() {
-		f.rebuildCache()
-		return *f.filteredLinesCache
+// How many of the first lines are gone because they were dropped from the
+// backing reader, see --max-lines
+func (f *FilteringReader) droppedLineCount() int {
+	if f.shouldPassThrough() {
+		backingReader, ok := f.BackingReader.(*reader.ReaderImpl)
+		if !ok {
+			return 0
+		}
+		return backingReader.DroppedLineCount()
 	}
 
-	var currentFilterPattern search.Search
-	if (*f).Filter.Active() {
-		currentFilterPattern = *f.Filter
-	}
-	var cacheFilterPattern search.Search
-	if f.filterWhenCaching.Active() {
-		cacheFilterPattern = f.filterWhenCaching
+	_, firstIndex := f.getAllLines()
+	return firstIndex
+}
+
+// Returns nil if we are done filtering, otherwise how far we have come, 0-100.
+func (f *FilteringReader) filteringPercent() *int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.scan == nil || !f.scan.inBackground {
+		return nil
 	}
-	if !currentFilterPattern.Equals(cacheFilterPattern) {
-		f.rebuildCache()
-		return *f.filteredLinesCache
+
+	baseCount := f.BackingReader.GetLineCount()
+	if baseCount == 0 {
+		return nil
 	}
 
-	return *f.filteredLinesCache
+	percent := 100 * f.scan.scannedCount / baseCount
+	return &percent
 }
 
 func (f *FilteringReader) shouldPassThrough() bool {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
-	if f.Filter == nil || f.Filter.Inactive() {
-		// Cache is not needed
-		f.filteredLinesCache = nil
+	if f.Filter == nil || !f.Filter.Active() {
+		// Scan is not needed
+		f.cancelScan()
 
 		// No filtering, so pass through all
 		return true
@@ -122,7 +343,8 @@ func (f *FilteringReader) GetLineCount() int {
 		return f.BackingReader.GetLineCount()
 	}
 
-	return len(f.getAllLines())
+	allLines, firstIndex := f.getAllLines()
+	return firstIndex + len(allLines)
 }
 
 func (f *FilteringReader) ShouldShowLineCount() bool {
@@ -134,11 +356,12 @@ func (f *FilteringReader) GetLine(index linemetadata.Index) *reader.NumberedLine
 		return f.BackingReader.GetLine(index)
 	}
 
-	allLines := f.getAllLines()
-	if index.Index() < 0 || index.Index() >= len(allLines) {
+	allLines, firstIndex := f.getAllLines()
+	i := index.Index() - firstIndex
+	if i < 0 || i >= len(allLines) {
 		return nil
 	}
-	return &allLines[index.Index()]
+	return &allLines[i]
 }
 
 func (f *FilteringReader) GetLines(firstLine linemetadata.Index, wantedLineCount int) reader.InputLines {
@@ -146,7 +369,7 @@ func (f *FilteringReader) GetLines(firstLine linemetadata.Index, wantedLineCount
 		return f.BackingReader.GetLines(firstLine, wantedLineCount)
 	}
 
-	acceptedLines := f.getAllLines()
+	acceptedLines, firstIndex := f.getAllLines()
 
 	if len(acceptedLines) == 0 || wantedLineCount == 0 {
 		return reader.InputLines{
@@ -154,10 +377,16 @@ func (f *FilteringReader) GetLines(firstLine linemetadata.Index, wantedLineCount
 		}
 	}
 
+	// Asking for dropped lines gets us the first ones we still have
+	wantedLineCount = min(wantedLineCount, len(acceptedLines))
+	if firstLine.Index() < firstIndex {
+		firstLine = linemetadata.IndexFromZeroBased(firstIndex)
+	}
+
 	lastLine := firstLine.NonWrappingAdd(wantedLineCount - 1)
 
 	// Prevent reading past the end of the available lines
-	maxLineIndex := *linemetadata.IndexFromLength(len(acceptedLines))
+	maxLineIndex := *linemetadata.IndexFromLength(firstIndex + len(acceptedLines))
 	if lastLine.IsAfter(maxLineIndex) {
 		lastLine = maxLineIndex
 
@@ -169,7 +398,7 @@ func (f *FilteringReader) GetLines(firstLine linemetadata.Index, wantedLineCount
 	}
 
 	return reader.InputLines{
-		Lines:      acceptedLines[firstLine.Index() : firstLine.Index()+wantedLineCount],
+		Lines:      acceptedLines[firstLine.Index()-firstIndex : firstLine.Index()-firstIndex+wantedLineCount],
 		StatusText: f.createStatus(&lastLine),
 	}
 }
@@ -184,25 +413,74 @@ func (f *FilteringReader) GetLinesPreallocated(firstLine linemetadata.Index, res
 	return lines.FilenameText, lines.StatusText
 }
 
+// Index of the first visible line with this line number or later. Returns nil
+// if there is no such line.
+func (f *FilteringReader) indexOf(number linemetadata.Number) *linemetadata.Index {
+	if f.shouldPassThrough() {
+		if number.AsZeroBased() >= f.BackingReader.GetLineCount() {
+			return nil
+		}
+		index := linemetadata.IndexFromZeroBased(number.AsZeroBased())
+		return &index
+	}
+
+	allLines, _ := f.getAllLines()
+	i, _ := slices.BinarySearchFunc(allLines, number, func(line reader.NumberedLine, target linemetadata.Number) int {
+		return line.Number.AsZeroBased() - target.AsZeroBased()
+	})
+	if i >= len(allLines) {
+		return nil
+	}
+	return &allLines[i].Index
+}
+
 // In the general case, this will return a text like this:
-// "Filtered: 1234/5678 lines  22%"
+// "Filtered [ERROR] [!healthcheck] -C2: 1234/5678 lines, 4444 hidden  22%"
+//
+// While filtering in the background, it will instead say something like:
+// "Filtered [ERROR]: 1234 lines so far, filtering 22%..."
 func (f *FilteringReader) createStatus(lastLine *linemetadata.Index) string {
+	prefix := "Filtered"
+	if f.Filter != nil && f.Filter.Active() {
+		prefix += " " + f.Filter.String()
+		if context := f.Filter.contextString(); context != "" {
+			prefix += " " + context
+		}
+	}
+
 	baseCount := f.BackingReader.GetLineCount()
 	if baseCount == 0 {
-		return "Filtered: No input lines"
+		return prefix + ": No input lines"
+	}
+
+	if percent := f.filteringPercent(); percent != nil {
+		// Line counts are moving targets until we're done
+		acceptedCount := f.GetLineCount()
+		lineString := "lines"
+		if acceptedCount == 1 {
+			lineString = "line"
+		}
+		return fmt.Sprintf("%s: %s %s so far, filtering %d%%...",
+			prefix, util.FormatInt(acceptedCount), lineString, *percent)
+	}
+
+	acceptedCount := 0
+	if lastLine != nil {
+		acceptedCount = f.GetLineCount()
 	}
 
 	baseCountString := "/" + linemetadata.IndexFromLength(baseCount).Format()
+	hiddenString := ", " + util.FormatInt(baseCount-acceptedCount) + " hidden"
 	if !f.BackingReader.ShouldShowLineCount() {
 		baseCountString = ""
+		hiddenString = ""
 	}
 
 	if lastLine == nil {
 		// 100% because we're showing all 0 lines
-		return "Filtered: 0" + baseCountString + " lines  100%"
+		return prefix + ": 0" + baseCountString + " lines" + hiddenString + "  100%"
 	}
 
-	acceptedCount := f.GetLineCount()
 	acceptedCountString := linemetadata.IndexFromLength(acceptedCount).Format()
 
 	percent := int(math.Floor(100 * float64(lastLine.Index()+1) / float64(acceptedCount)))
@@ -212,8 +490,8 @@ func (f *FilteringReader) createStatus(lastLine *linemetadata.Index) string {
 		lineString += "s"
 	}
 
-	return fmt.Sprintf("Filtered: %s%s %s  %d%%",
-		acceptedCountString, baseCountString, lineString, percent)
+	return fmt.Sprintf("%s: %s%s %s%s  %d%%",
+		prefix, acceptedCountString, baseCountString, lineString, hiddenString, percent)
 }
 
 // SetBackingReader switches the underlying reader while holding the lock and
@@ -229,7 +507,5 @@ func (f *FilteringReader) SetBackingReader(r reader.Reader) {
 	f.BackingReader = r
 
 	// Invalidate caches so they will be rebuilt lazily on next access.
-	f.filteredLinesCache = nil
-	f.unfilteredLineCountWhenCaching = -1
-	f.filterWhenCaching = search.Search{}
+	f.cancelScan()
 }
@@ -29,6 +29,10 @@ type InputBox struct {
 	// onTextChanged is an optional callback which is triggered when the text
 	// of the InputBox changes.
 	onTextChanged InputBoxOnTextChanged
+
+	// complete is an optional callback for the TAB key. It gets the current
+	// text and returns the completed text.
+	complete func(text string) string
 }
 
 // draw renders the input box at the bottom line of the screen, showing a
@@ -140,6 +144,10 @@ func (b *InputBox) handleRune(char rune) bool {
 		b.deleteToStart()
 		return true
 	}
+	if char == '\t' && b.complete != nil {
+		b.setText(b.complete(b.text))
+		return true
+	}
 
 	// If configured to accept numbers only, drop any non-digit rune.
 	if b.accept == INPUTBOX_ACCEPT_POSITIVE_NUMBERS {
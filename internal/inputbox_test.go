@@ -89,3 +89,20 @@ func TestUnicodeRunes(t *testing.T) {
 	// We expect prompt + two runes
 	assert.Equal(t, "U: 你午", row)
 }
+
+func TestTabCompletion(t *testing.T) {
+	b := &InputBox{accept: INPUTBOX_ACCEPT_ALL}
+
+	// Without a completer, TAB is just another character
+	assert.Assert(t, b.handleRune('\t'))
+	assert.Equal(t, "\t", b.text)
+
+	b = &InputBox{
+		accept:   INPUTBOX_ACCEPT_ALL,
+		complete: func(text string) string { return text + "pa" },
+	}
+	b.handleRune('a')
+	assert.Assert(t, b.handleRune('\t'))
+	assert.Equal(t, "apa", b.text)
+	assert.Equal(t, 3, b.cursorPos)
+}
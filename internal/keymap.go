@@ -0,0 +1,440 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/walles/moor/v2/twin"
+)
+
+// One key press, either a rune or a special key like the arrow keys
+type keyStroke struct {
+	isRune  bool
+	char    rune
+	keyCode twin.KeyCode
+}
+
+type keyBinding struct {
+	mode   keymapMode
+	keys   []keyStroke
+	action string
+}
+
+// Modes with their own key bindings. Bindings for an action apply in the mode
+// the action is for, see modeActions().
+type keymapMode string
+
+const (
+	keymapModeViewing        keymapMode = "viewing"
+	keymapModeSearchOverview keymapMode = "search overview"
+	keymapModeFileList       keymapMode = "file list"
+	keymapModeLinks          keymapMode = "links"
+)
+
+// Keymap maps key sequences to named pager actions. Create with NewKeymap().
+//
+// Key sequences are written as space separated key names, like "g g" or
+// "ctrl-p". Single characters are their own names. Special keys are named
+// "esc", "enter", "space", "backspace", "delete", "up", "down", "left",
+// "right", "alt-up", "alt-down", "alt-left", "alt-right", "home", "end",
+// "pgup" and "pgdn".
+//
+// If a key sequence is also the beginning of a longer one, the shorter one
+// will be executed right away, and the longer one will take over if the
+// sequence continues. This is how "g" opens the go-to-line prompt while "g g"
+// goes to the top.
+//
+// The search overview, the file list and link selection have their own
+// actions, bound to single keys.
+type Keymap struct {
+	// In help text order
+	bindings []keyBinding
+}
+
+// Key bindings from before we had a keymap. Keys are listed in the order we
+// want them presented in the help text.
+var defaultKeyBindings = []struct {
+	keys   string
+	action string
+}{
+	{"esc", "quit"},
+	{"q", "quit"},
+	{"w", "toggle-wrap"},
+	{"=", "toggle-statusbar"},
+	{"v", "edit"},
+	{"ctrl-t", "cycle-tab-size"},
+	{"J", "cycle-json-view"},
+	{"T", "toggle-table-view"},
+	{"|", "toggle-frozen-column"},
+	{"l", "select-link"},
+	{"h", "help"},
+
+	{"up", "scroll-up"},
+	{"k", "scroll-up"},
+	{"y", "scroll-up"},
+	// Ref: https://github.com/walles/moor/issues/107#issuecomment-1328354080
+	{"ctrl-p", "scroll-up"},
+	{"down", "scroll-down"},
+	{"enter", "scroll-down"},
+	{"j", "scroll-down"},
+	{"e", "scroll-down"},
+	// Ref: https://github.com/walles/moor/issues/107#issuecomment-1328354080
+	{"ctrl-n", "scroll-down"},
+	{"left", "scroll-left"},
+	{"right", "scroll-right"},
+	{"alt-left", "scroll-left-one"},
+	{"alt-right", "scroll-right-one"},
+	{"ctrl-a", "scroll-leftmost"},
+	{"pgup", "page-up"},
+	{"b", "page-up"},
+	{"pgdn", "page-down"},
+	{"f", "page-down"},
+	{"space", "page-down"},
+	// Ref: https://github.com/walles/moor/issues/90
+	{"u", "half-page-up"},
+	{"ctrl-u", "half-page-up"},
+	{"d", "half-page-down"},
+	{"ctrl-d", "half-page-down"},
+	{"home", "goto-start"},
+	{"<", "goto-start"},
+	{"g g", "goto-start"},
+	{"end", "goto-end"},
+	{">", "goto-end"},
+	{"G", "goto-end"},
+	{"g", "goto-line"},
+	{"m", "set-mark"},
+	{"'", "jump-to-mark"},
+
+	{":", "switch-file"},
+	{"#", "last-file"},
+	{"backspace", "go-back"},
+
+	{"&", "filter"},
+	{"c", "filter-context"},
+	{"L", "cycle-log-level"},
+
+	{"/", "search-forward"},
+	{"?", "search-backward"},
+	{"n", "search-next"},
+	{"p", "search-previous"},
+	{"N", "search-previous"},
+	{"o", "search-overview"},
+	{"H", "pin-highlight"},
+
+	{"enter", "overview-jump"},
+	{"esc", "overview-back"},
+	{"q", "overview-back"},
+	{"up", "overview-up"},
+	{"k", "overview-up"},
+	{"down", "overview-down"},
+	{"j", "overview-down"},
+	{"pgup", "overview-page-up"},
+	{"pgdn", "overview-page-down"},
+	{"home", "overview-first"},
+	{"end", "overview-last"},
+
+	{"enter", "file-list-switch"},
+	{"esc", "file-list-back"},
+	{"q", "file-list-back"},
+	{"up", "file-list-up"},
+	{"k", "file-list-up"},
+	{"down", "file-list-down"},
+	{"j", "file-list-down"},
+	{"home", "file-list-first"},
+	{"end", "file-list-last"},
+	{"d", "file-list-drop"},
+
+	{"enter", "link-open"},
+	{"esc", "link-back"},
+	{"q", "link-back"},
+	{"tab", "link-next"},
+	{"down", "link-next"},
+	{"right", "link-next"},
+	{"n", "link-next"},
+	{"j", "link-next"},
+	{"up", "link-previous"},
+	{"left", "link-previous"},
+	{"p", "link-previous"},
+	{"N", "link-previous"},
+	{"k", "link-previous"},
+}
+
+var namedKeys = map[string]keyStroke{
+	"esc":       {keyCode: twin.KeyEscape},
+	"enter":     {keyCode: twin.KeyEnter},
+	"backspace": {keyCode: twin.KeyBackspace},
+	"delete":    {keyCode: twin.KeyDelete},
+	"up":        {keyCode: twin.KeyUp},
+	"down":      {keyCode: twin.KeyDown},
+	"right":     {keyCode: twin.KeyRight},
+	"left":      {keyCode: twin.KeyLeft},
+	"alt-up":    {keyCode: twin.KeyAltUp},
+	"alt-down":  {keyCode: twin.KeyAltDown},
+	"alt-right": {keyCode: twin.KeyAltRight},
+	"alt-left":  {keyCode: twin.KeyAltLeft},
+	"home":      {keyCode: twin.KeyHome},
+	"end":       {keyCode: twin.KeyEnd},
+	"pgup":      {keyCode: twin.KeyPgUp},
+	"pgdn":      {keyCode: twin.KeyPgDown},
+	"space":     {isRune: true, char: ' '},
+	"tab":       {isRune: true, char: '\t'},
+}
+
+// Alternative spellings accepted when parsing
+var namedKeyAliases = map[string]string{
+	"escape":   "esc",
+	"return":   "enter",
+	"pageup":   "pgup",
+	"pagedown": "pgdn",
+	"pgdown":   "pgdn",
+	"del":      "delete",
+}
+
+// How special keys are shown in the help text
+var keyCodeDisplayNames = map[twin.KeyCode]string{
+	twin.KeyEscape:    "ESC",
+	twin.KeyEnter:     "RETURN",
+	twin.KeyBackspace: "BACKSPACE",
+	twin.KeyDelete:    "DELETE",
+	twin.KeyUp:        "Up",
+	twin.KeyDown:      "Down",
+	twin.KeyRight:     "Right",
+	twin.KeyLeft:      "Left",
+	twin.KeyAltUp:     "Alt-Up",
+	twin.KeyAltDown:   "Alt-Down",
+	twin.KeyAltRight:  "Alt-Right",
+	twin.KeyAltLeft:   "Alt-Left",
+	twin.KeyHome:      "Home",
+	twin.KeyEnd:       "End",
+	twin.KeyPgUp:      "PageUp",
+	twin.KeyPgDown:    "PageDown",
+}
+
+// NewKeymap creates a keymap with the default key bindings
+func NewKeymap() *Keymap {
+	keymap := Keymap{}
+	for _, binding := range defaultKeyBindings {
+		err := keymap.Bind(binding.keys, binding.action)
+		if err != nil {
+			panic(fmt.Errorf("Broken default key binding: %w", err))
+		}
+	}
+
+	return &keymap
+}
+
+// Bind a key sequence to an action, replacing any existing binding for that
+// sequence in the action's mode. Bind to "none" to remove a default binding
+// from all modes.
+func (k *Keymap) Bind(keys string, action string) error {
+	strokes, err := parseKeySequence(keys)
+	if err != nil {
+		return err
+	}
+
+	if action != "none" && findPagerAction(action) == nil {
+		return fmt.Errorf("Unknown action: %s", action)
+	}
+
+	if action != "none" && pagerActionModes()[action] != keymapModeViewing && len(strokes) > 1 {
+		return fmt.Errorf("Only single keys can be bound to %s", action)
+	}
+
+	k.bind(strokes, action)
+	return nil
+}
+
+// Like Bind(), but with already validated parameters
+func (k *Keymap) bind(strokes []keyStroke, action string) {
+	mode := pagerActionModes()[action]
+	k.bindings = slices.DeleteFunc(k.bindings, func(binding keyBinding) bool {
+		return slices.Equal(binding.keys, strokes) && (action == "none" || binding.mode == mode)
+	})
+
+	if action == "none" {
+		return
+	}
+
+	k.bindings = append(k.bindings, keyBinding{mode: mode, keys: strokes, action: action})
+}
+
+// Returns the action bound to this exact sequence in the given mode, or "" if
+// there is none. If the sequence is the beginning of some longer sequence,
+// isPrefix will be true.
+func (k *Keymap) lookup(mode keymapMode, keys []keyStroke) (action string, isPrefix bool) {
+	for _, binding := range k.bindings {
+		if binding.mode != mode {
+			continue
+		}
+
+		if slices.Equal(binding.keys, keys) {
+			action = binding.action
+			continue
+		}
+
+		if len(binding.keys) > len(keys) && slices.Equal(binding.keys[:len(keys)], keys) {
+			isPrefix = true
+		}
+	}
+
+	return
+}
+
+// Human readable descriptions of all key sequences bound to the given action,
+// in binding order. Like "'g'" or "'CTRL-p'".
+func (k *Keymap) keysFor(action string) []string {
+	keys := []string{}
+	for _, binding := range k.bindings {
+		if binding.action == action {
+			keys = append(keys, "'"+describeKeySequence(binding.keys)+"'")
+		}
+	}
+
+	return keys
+}
+
+func parseKeySequence(keys string) ([]keyStroke, error) {
+	strokes := []keyStroke{}
+	for _, name := range strings.Fields(keys) {
+		stroke, err := parseKeyName(name)
+		if err != nil {
+			return nil, err
+		}
+		strokes = append(strokes, stroke)
+	}
+
+	if len(strokes) == 0 {
+		return nil, fmt.Errorf("Empty key sequence")
+	}
+
+	return strokes, nil
+}
+
+func parseKeyName(name string) (keyStroke, error) {
+	runes := []rune(name)
+	if len(runes) == 1 {
+		return keyStroke{isRune: true, char: runes[0]}, nil
+	}
+
+	lowercase := strings.ToLower(name)
+	if alias, found := namedKeyAliases[lowercase]; found {
+		lowercase = alias
+	}
+	if stroke, found := namedKeys[lowercase]; found {
+		return stroke, nil
+	}
+
+	if letter, found := strings.CutPrefix(lowercase, "ctrl-"); found {
+		if len(letter) == 1 && letter[0] >= 'a' && letter[0] <= 'z' {
+			// CTRL-a is 0x01, CTRL-b is 0x02 and so on
+			return keyStroke{isRune: true, char: rune(letter[0]-'a') + 1}, nil
+		}
+	}
+
+	return keyStroke{}, fmt.Errorf("Unknown key name: %s", name)
+}
+
+func describeKeyStroke(stroke keyStroke) string {
+	if !stroke.isRune {
+		name, found := keyCodeDisplayNames[stroke.keyCode]
+		if !found {
+			return fmt.Sprintf("key %d", stroke.keyCode)
+		}
+		return name
+	}
+
+	switch {
+	case stroke.char == ' ':
+		return "SPACE"
+	case stroke.char == '\t':
+		return "TAB"
+	case stroke.char >= 1 && stroke.char <= 26:
+		return "CTRL-" + string('a'+stroke.char-1)
+	case !unicode.IsPrint(stroke.char):
+		return fmt.Sprintf("0x%02x", stroke.char)
+	}
+
+	return string(stroke.char)
+}
+
+// Sequences of plain characters are shown as "gg", others are space separated
+func describeKeySequence(keys []keyStroke) string {
+	allPlain := true
+	descriptions := []string{}
+	for _, stroke := range keys {
+		description := describeKeyStroke(stroke)
+		if len([]rune(description)) > 1 {
+			allPlain = false
+		}
+		descriptions = append(descriptions, description)
+	}
+
+	if allPlain {
+		return strings.Join(descriptions, "")
+	}
+	return strings.Join(descriptions, " ")
+}
+
+// Handle a key press in viewing mode
+func (p *Pager) handleKeyStroke(stroke keyStroke) {
+	keys := []keyStroke{stroke}
+	action, isPrefix := p.Keymap.lookup(keymapModeViewing, keys)
+	if isPrefix {
+		p.pendingKeys = keys
+	}
+
+	if action == "" {
+		if !isPrefix {
+			log.Debugf("Unhandled key press %s", describeKeyStroke(stroke))
+		}
+		return
+	}
+
+	findPagerAction(action).run(p)
+}
+
+// Handle a key press in one of the modes with their own key bindings
+func (p *Pager) handleModeKeyStroke(mode keymapMode, stroke keyStroke) {
+	action, _ := p.Keymap.lookup(mode, []keyStroke{stroke})
+	if action == "" {
+		log.Debugf("Unhandled %s key press %s", mode, describeKeyStroke(stroke))
+		return
+	}
+
+	findPagerAction(action).run(p)
+}
+
+// If the user is in the middle of typing a multi key sequence, try to continue
+// it with this key stroke.
+//
+// Returns true if the stroke was consumed by the sequence, false if it should
+// be handled by the current mode.
+func (p *Pager) continueKeySequence(stroke keyStroke) bool {
+	if len(p.pendingKeys) == 0 {
+		return false
+	}
+
+	keys := append(slices.Clone(p.pendingKeys), stroke)
+	p.pendingKeys = nil
+
+	action, isPrefix := p.Keymap.lookup(keymapModeViewing, keys)
+	if action == "" && !isPrefix {
+		return false
+	}
+
+	if isPrefix {
+		p.pendingKeys = keys
+	}
+
+	if action != "" {
+		// Whatever mode the start of the sequence took us to, the full
+		// sequence wins
+		p.mode = PagerModeViewing{pager: p}
+		findPagerAction(action).run(p)
+	}
+
+	return true
+}
@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/walles/moor/v2/internal/reader"
+	"github.com/walles/moor/v2/twin"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseKeySequence(t *testing.T) {
+	keys, err := parseKeySequence("g g")
+	assert.NilError(t, err)
+	assert.Assert(t, slices.Equal(keys, []keyStroke{{isRune: true, char: 'g'}, {isRune: true, char: 'g'}}))
+
+	keys, err = parseKeySequence("CTRL-p PageDown space")
+	assert.NilError(t, err)
+	assert.Assert(t, slices.Equal(keys, []keyStroke{
+		{isRune: true, char: '\x10'},
+		{keyCode: twin.KeyPgDown},
+		{isRune: true, char: ' '},
+	}))
+
+	_, err = parseKeySequence("ctrl-1")
+	assert.Error(t, err, "Unknown key name: ctrl-1")
+
+	_, err = parseKeySequence(" ")
+	assert.Error(t, err, "Empty key sequence")
+}
+
+func TestDescribeKeySequence(t *testing.T) {
+	for keys, expected := range map[string]string{
+		"g g":    "gg",
+		"ctrl-p": "CTRL-p",
+		"escape": "ESC",
+		"space":  "SPACE",
+		"g esc":  "g ESC",
+		"pgup":   "PageUp",
+	} {
+		parsed, err := parseKeySequence(keys)
+		assert.NilError(t, err)
+		assert.Equal(t, describeKeySequence(parsed), expected)
+	}
+}
+
+func TestKeymapBind(t *testing.T) {
+	keymap := NewKeymap()
+	assert.DeepEqual(t, keymap.keysFor("quit"), []string{"'ESC'", "'q'"})
+
+	assert.NilError(t, keymap.Bind("q", "none"))
+	assert.DeepEqual(t, keymap.keysFor("quit"), []string{"'ESC'"})
+
+	assert.NilError(t, keymap.Bind("x", "quit"))
+	assert.DeepEqual(t, keymap.keysFor("quit"), []string{"'ESC'", "'x'"})
+
+	// Rebinding should replace the old binding
+	assert.NilError(t, keymap.Bind("x", "help"))
+	assert.DeepEqual(t, keymap.keysFor("quit"), []string{"'ESC'"})
+
+	assert.Error(t, keymap.Bind("x", "fly"), "Unknown action: fly")
+}
+
+func TestKeymapLookup(t *testing.T) {
+	keymap := NewKeymap()
+
+	action, isPrefix := keymap.lookup(keymapModeViewing, []keyStroke{{isRune: true, char: 'g'}})
+	assert.Equal(t, action, "goto-line")
+	assert.Assert(t, isPrefix)
+
+	action, isPrefix = keymap.lookup(keymapModeViewing, []keyStroke{{isRune: true, char: 'g'}, {isRune: true, char: 'g'}})
+	assert.Equal(t, action, "goto-start")
+	assert.Assert(t, !isPrefix)
+
+	action, isPrefix = keymap.lookup(keymapModeViewing, []keyStroke{{isRune: true, char: '%'}})
+	assert.Equal(t, action, "")
+	assert.Assert(t, !isPrefix)
+}
+
+// Pressing 'g' should open the go-to-line prompt, and pressing 'g' again
+// should go to the top.
+func TestGoToTopWithGG(t *testing.T) {
+	reader := reader.NewFromTextForTesting("", "1\n2\n3\n4\n5\n6\n7\n8\n9")
+	pager := NewPager(reader)
+	pager.screen = twin.NewFakeScreen(10, 3)
+	assert.NilError(t, reader.Wait())
+
+	pager.scrollToEnd()
+	assert.Assert(t, pager.scrollPosition.lineIndex(pager).Index() > 0)
+
+	pager.mode.onRune('g')
+	_, isGotoLine := pager.mode.(*PagerModeGotoLine)
+	assert.Assert(t, isGotoLine)
+
+	assert.Assert(t, pager.continueKeySequence(keyStroke{isRune: true, char: 'g'}))
+	assert.Assert(t, pager.isViewing())
+	assert.Equal(t, pager.scrollPosition.lineIndex(pager).Index(), 0)
+}
+
+// A custom multi key binding with no action for the first key
+func TestCustomKeySequence(t *testing.T) {
+	reader := reader.NewFromTextForTesting("", "1\n2\n3\n4\n5\n6\n7\n8\n9")
+	pager := NewPager(reader)
+	pager.screen = twin.NewFakeScreen(10, 3)
+	assert.NilError(t, reader.Wait())
+
+	assert.NilError(t, pager.Keymap.Bind("z e", "goto-end"))
+
+	pager.mode.onRune('z')
+	assert.Assert(t, pager.isViewing())
+	assert.Equal(t, pager.scrollPosition.lineIndex(pager).Index(), 0)
+
+	assert.Assert(t, pager.continueKeySequence(keyStroke{isRune: true, char: 'e'}))
+	assert.Assert(t, pager.isScrolledToEnd())
+
+	// Not part of any sequence, should be left to the mode
+	assert.Assert(t, !pager.continueKeySequence(keyStroke{isRune: true, char: 'e'}))
+}
+
+func TestHelpTextFollowsKeymap(t *testing.T) {
+	keymap := NewKeymap()
+	assert.Assert(t, strings.Contains(generateHelpText(keymap), "* 'w' to toggle wrapping of long lines\n"))
+
+	assert.NilError(t, keymap.Bind("w", "none"))
+	assert.NilError(t, keymap.Bind("ctrl-w", "toggle-wrap"))
+	assert.Assert(t, strings.Contains(generateHelpText(keymap), "* 'CTRL-w' to toggle wrapping of long lines\n"))
+}
+
+func TestKeymapModes(t *testing.T) {
+	keymap := NewKeymap()
+	d := []keyStroke{{isRune: true, char: 'd'}}
+
+	action, _ := keymap.lookup(keymapModeViewing, d)
+	assert.Equal(t, action, "half-page-down")
+	action, _ = keymap.lookup(keymapModeFileList, d)
+	assert.Equal(t, action, "file-list-drop")
+
+	// Rebinding in one mode should leave the other modes alone
+	assert.NilError(t, keymap.Bind("x", "file-list-drop"))
+	assert.NilError(t, keymap.Bind("d", "file-list-up"))
+	assert.DeepEqual(t, keymap.keysFor("file-list-drop"), []string{"'x'"})
+	action, _ = keymap.lookup(keymapModeViewing, d)
+	assert.Equal(t, action, "half-page-down")
+
+	// Removing a binding removes it from all modes
+	assert.NilError(t, keymap.Bind("q", "none"))
+	assert.DeepEqual(t, keymap.keysFor("link-back"), []string{"'ESC'"})
+
+	assert.Error(t, keymap.Bind("d d", "link-next"), "Only single keys can be bound to link-next")
+
+	assert.Assert(t, strings.Contains(generateHelpText(keymap), "* 'x' to drop the selected file from the list\n"))
+}
@@ -0,0 +1,275 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/adrg/xdg"
+	"github.com/walles/moor/v2/twin"
+)
+
+// Key bindings imported from a less lesskey source file.
+//
+// Ref: https://man7.org/linux/man-pages/man1/lesskey.1.html
+type Lesskey struct {
+	// Where the bindings were read from
+	Path string
+
+	bindings []lesskeyBinding
+
+	// Human readable descriptions of lines we couldn't honor
+	Unsupported []string
+}
+
+type lesskeyBinding struct {
+	keys   []keyStroke
+	action string
+}
+
+// Map less action names to moor action names. Less actions not in here are
+// reported as unsupported.
+var lessActions = map[string]string{
+	"back-line":          "scroll-up",
+	"back-line-force":    "scroll-up",
+	"forw-line":          "scroll-down",
+	"forw-line-force":    "scroll-down",
+	"back-screen":        "page-up",
+	"back-screen-force":  "page-up",
+	"back-window":        "page-up",
+	"forw-screen":        "page-down",
+	"forw-screen-force":  "page-down",
+	"forw-window":        "page-down",
+	"back-scroll":        "half-page-up",
+	"forw-scroll":        "half-page-down",
+	"left-scroll":        "scroll-left",
+	"right-scroll":       "scroll-right",
+	"no-scroll":          "scroll-leftmost",
+	"goto-line":          "goto-start",
+	"goto-end":           "goto-end",
+	"goto-end-buffered":  "goto-end",
+	"forw-search":        "search-forward",
+	"back-search":        "search-backward",
+	"repeat-search":      "search-next",
+	"repeat-search-all":  "search-next",
+	"reverse-search":     "search-previous",
+	"reverse-search-all": "search-previous",
+	"filter":             "filter",
+	"set-mark":           "set-mark",
+	"goto-mark":          "jump-to-mark",
+	"visual":             "edit",
+	"help":               "help",
+	"quit":               "quit",
+
+	// Unbinds the key in less, and so in moor as well
+	"noaction": "none",
+	"invalid":  "none",
+}
+
+// Less special key escapes, "\ku" and friends
+var lesskeySpecialKeys = map[byte]keyStroke{
+	'u': {keyCode: twin.KeyUp},
+	'd': {keyCode: twin.KeyDown},
+	'r': {keyCode: twin.KeyRight},
+	'l': {keyCode: twin.KeyLeft},
+	'U': {keyCode: twin.KeyPgUp},
+	'D': {keyCode: twin.KeyPgDown},
+	'h': {keyCode: twin.KeyHome},
+	'e': {keyCode: twin.KeyEnd},
+	'x': {keyCode: twin.KeyDelete},
+}
+
+// Find and parse the user's lesskey source file, looking in the same places as
+// less does. Returns nil with no error if there is no lesskey file.
+func LoadLesskey() (*Lesskey, error) {
+	for _, path := range lesskeyPaths() {
+		file, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		lesskey, err := parseLesskey(file, path)
+		closeErr := file.Close()
+		if err != nil {
+			return nil, err
+		}
+		if closeErr != nil {
+			return nil, closeErr
+		}
+
+		return lesskey, nil
+	}
+
+	return nil, nil
+}
+
+// Lesskey source file candidates, in priority order
+func lesskeyPaths() []string {
+	if lesskeyIn := os.Getenv("LESSKEYIN"); lesskeyIn != "" {
+		// Less only looks here if this is set
+		return []string{lesskeyIn}
+	}
+
+	paths := []string{filepath.Join(xdg.ConfigHome, "lesskey")}
+
+	home, err := os.UserHomeDir()
+	if err == nil {
+		paths = append(paths,
+			filepath.Join(home, ".config", "lesskey"),
+			filepath.Join(home, ".lesskey"),
+		)
+	}
+
+	return paths
+}
+
+func parseLesskey(input io.Reader, path string) (*Lesskey, error) {
+	lesskey := Lesskey{Path: path}
+
+	// Less defaults to the #command section
+	section := "#command"
+
+	scanner := bufio.NewScanner(input)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if line == "#command" || line == "#line-edit" || line == "#env" {
+			section = line
+			if section != "#command" {
+				lesskey.unsupported(lineNumber, line+" section ignored")
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			if line == "#stop" && section == "#command" {
+				lesskey.unsupported(lineNumber, "#stop ignored, moor's default bindings still apply")
+			}
+
+			// A comment
+			continue
+		}
+
+		if section != "#command" {
+			continue
+		}
+
+		keys, rest, err := parseLesskeyKeys(line)
+		if err != nil {
+			lesskey.unsupported(lineNumber, err.Error())
+			continue
+		}
+
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			lesskey.unsupported(lineNumber, "no action for "+describeKeySequence(keys))
+			continue
+		}
+		lessAction := fields[0]
+
+		action, found := lessActions[lessAction]
+		if !found {
+			lesskey.unsupported(lineNumber, fmt.Sprintf("'%s' %s: no corresponding moor action", describeKeySequence(keys), lessAction))
+			continue
+		}
+
+		if len(fields) > 1 {
+			lesskey.unsupported(lineNumber, fmt.Sprintf("'%s' %s: extra command characters not supported", describeKeySequence(keys), lessAction))
+			continue
+		}
+
+		lesskey.bindings = append(lesskey.bindings, lesskeyBinding{keys: keys, action: action})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	return &lesskey, nil
+}
+
+func (l *Lesskey) unsupported(lineNumber int, description string) {
+	l.Unsupported = append(l.Unsupported, fmt.Sprintf("line %d: %s", lineNumber, description))
+}
+
+// Parse the key part of a lesskey command line, up to the first whitespace.
+// Returns the keys and whatever is left of the line.
+func parseLesskeyKeys(line string) ([]keyStroke, string, error) {
+	keys := []keyStroke{}
+
+	i := 0
+	for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+		char := line[i]
+		i++
+
+		switch {
+		case char == '^' && i < len(line) && line[i] != ' ' && line[i] != '\t':
+			// Control character, "^F" is CTRL-F
+			control := line[i]
+			i++
+			if control == '[' {
+				keys = append(keys, keyStroke{keyCode: twin.KeyEscape})
+			} else {
+				keys = append(keys, keyStroke{isRune: true, char: rune(control & 0x1f)})
+			}
+
+		case char == '\\' && i < len(line):
+			escaped := line[i]
+			i++
+			switch escaped {
+			case 'e':
+				keys = append(keys, keyStroke{keyCode: twin.KeyEscape})
+			case 'n', 'r':
+				keys = append(keys, keyStroke{keyCode: twin.KeyEnter})
+			case 't':
+				keys = append(keys, keyStroke{isRune: true, char: '\t'})
+			case 'b':
+				keys = append(keys, keyStroke{keyCode: twin.KeyBackspace})
+			case 'k':
+				if i >= len(line) {
+					return nil, "", fmt.Errorf("incomplete \\k key")
+				}
+				special, found := lesskeySpecialKeys[line[i]]
+				if !found {
+					return nil, "", fmt.Errorf("unsupported special key: \\k%c", line[i])
+				}
+				i++
+				keys = append(keys, special)
+			default:
+				// "\\" is a backslash, "\^" is a caret and so on
+				keys = append(keys, keyStroke{isRune: true, char: rune(escaped)})
+			}
+
+		default:
+			r, size := utf8.DecodeRuneInString(line[i-1:])
+			i += size - 1
+			keys = append(keys, keyStroke{isRune: true, char: r})
+		}
+	}
+
+	return keys, line[i:], nil
+}
+
+// Apply the lesskey bindings to a keymap
+func (l *Lesskey) ApplyTo(keymap *Keymap) {
+	for _, binding := range l.bindings {
+		keymap.bind(binding.keys, binding.action)
+	}
+}
+
+// Number of key bindings we could import
+func (l *Lesskey) BindingsCount() int {
+	return len(l.bindings)
+}
@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/walles/moor/v2/twin"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseLesskeyKeys(t *testing.T) {
+	keys, rest, err := parseLesskeyKeys(`^F forw-screen`)
+	assert.NilError(t, err)
+	assert.Assert(t, slices.Equal(keys, []keyStroke{{isRune: true, char: '\x06'}}))
+	assert.Equal(t, rest, " forw-screen")
+
+	keys, _, err = parseLesskeyKeys(`\kd\e\\x forw-line`)
+	assert.NilError(t, err)
+	assert.Assert(t, slices.Equal(keys, []keyStroke{
+		{keyCode: twin.KeyDown},
+		{keyCode: twin.KeyEscape},
+		{isRune: true, char: '\\'},
+		{isRune: true, char: 'x'},
+	}))
+
+	keys, _, err = parseLesskeyKeys(`ö quit`)
+	assert.NilError(t, err)
+	assert.Assert(t, slices.Equal(keys, []keyStroke{{isRune: true, char: 'ö'}}))
+
+	_, _, err = parseLesskeyKeys(`\kz quit`)
+	assert.Error(t, err, "unsupported special key: \\kz")
+}
+
+func TestParseLesskey(t *testing.T) {
+	lesskey, err := parseLesskey(strings.NewReader(`
+#command
+# A comment
+\r	forw-screen
+^B	back-screen
+x	examine
+/	forw-search	foo
+q	noaction
+
+#env
+LESS = -i
+`), "/lesskey")
+	assert.NilError(t, err)
+
+	assert.Equal(t, lesskey.BindingsCount(), 3)
+	assert.DeepEqual(t, lesskey.Unsupported, []string{
+		"line 6: 'x' examine: no corresponding moor action",
+		"line 7: '/' forw-search: extra command characters not supported",
+		"line 10: #env section ignored",
+	})
+
+	keymap := NewKeymap()
+	lesskey.ApplyTo(keymap)
+
+	action, _ := keymap.lookup(keymapModeViewing, []keyStroke{{keyCode: twin.KeyEnter}})
+	assert.Equal(t, action, "page-down")
+	action, _ = keymap.lookup(keymapModeViewing, []keyStroke{{isRune: true, char: '\x02'}})
+	assert.Equal(t, action, "page-up")
+	action, _ = keymap.lookup(keymapModeViewing, []keyStroke{{isRune: true, char: 'q'}})
+	assert.Equal(t, action, "")
+}
@@ -0,0 +1,249 @@
+package internal
+
+import (
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/walles/moor/v2/internal/linemetadata"
+)
+
+// Like "https://example.com/some/path?query"
+var urlRegex = regexp.MustCompile(`\b(?:https?|ftp|file)://[^\s<>"'` + "`" + `]+`)
+
+// Like "internal/pager.go:123" or "/tmp/x.go:12:5", as printed by compilers and
+// by "grep -n". These are links only if the file exists.
+var fileLineRegex = regexp.MustCompile(`(?:~?/)?(?:[\w.+-]+/)*[\w.+-]+:[0-9]+(?::[0-9]+)?`)
+
+// Splits "file.go:12:5" into its parts
+var fileLineTargetRegex = regexp.MustCompile(`^(.+?):([0-9]+)(?::([0-9]+))?$`)
+
+// A link visible on screen
+type screenLink struct {
+	// Index into the rendered header lines followed by the rendered lines
+	row int
+
+	// Cell indices into the row, end is exclusive
+	start int
+	end   int
+
+	// URL or file name, possibly followed by a :line number
+	target string
+}
+
+// Find both OSC 8 hyperlinks and links in plain text, in screen order
+func findLinks(rows []renderedLine) []screenLink {
+	links := []screenLink{}
+	for rowIndex, row := range rows {
+		links = append(links, findLinksInRow(rowIndex, row)...)
+	}
+	return links
+}
+
+func findLinksInRow(rowIndex int, row renderedLine) []screenLink {
+	links := []screenLink{}
+
+	// OSC 8 hyperlinks, cells with the same URL in a row make one link
+	isHyperlink := make([]bool, len(row.cells))
+	for i := 0; i < len(row.cells); i++ {
+		hyperlink := row.cells[i].Style.HyperlinkURL()
+		if hyperlink == nil {
+			continue
+		}
+
+		end := i + 1
+		for end < len(row.cells) {
+			next := row.cells[end].Style.HyperlinkURL()
+			if next == nil || *next != *hyperlink {
+				break
+			}
+			end++
+		}
+
+		links = append(links, screenLink{row: rowIndex, start: i, end: end, target: *hyperlink})
+		for j := i; j < end; j++ {
+			isHyperlink[j] = true
+		}
+		i = end - 1
+	}
+
+	// Links in plain text. There's one rune per cell, so rune indices are cell
+	// indices.
+	runes := make([]rune, len(row.cells))
+	for i, cell := range row.cells {
+		runes[i] = cell.Rune
+	}
+	text := string(runes)
+
+	for _, regex := range []*regexp.Regexp{urlRegex, fileLineRegex} {
+		for _, match := range regex.FindAllStringIndex(text, -1) {
+			target := trimLinkPunctuation(text[match[0]:match[1]])
+			start := utf8.RuneCountInString(text[:match[0]])
+			end := start + utf8.RuneCountInString(target)
+
+			overlapping := false
+			for i := start; i < end; i++ {
+				overlapping = overlapping || isHyperlink[i]
+			}
+			if overlapping {
+				// Already part of an URL or a hyperlink
+				continue
+			}
+
+			if regex == fileLineRegex {
+				if fileName, _ := localFileTarget(target); fileName == "" {
+					// Something else, like "localhost:8080" or "12:30"
+					continue
+				}
+			}
+
+			links = append(links, screenLink{row: rowIndex, start: start, end: end, target: target})
+			for i := start; i < end; i++ {
+				isHyperlink[i] = true
+			}
+		}
+	}
+
+	// Screen order
+	for i := 1; i < len(links); i++ {
+		for j := i; j > 0 && links[j].start < links[j-1].start; j-- {
+			links[j], links[j-1] = links[j-1], links[j]
+		}
+	}
+
+	return links
+}
+
+// URLs in text are often followed by punctuation that isn't part of the URL
+func trimLinkPunctuation(link string) string {
+	for {
+		trimmed := strings.TrimRight(link, ".,;:!?'\"")
+		if strings.HasSuffix(trimmed, ")") && !strings.Contains(trimmed, "(") {
+			// Like "(see https://example.com)"
+			trimmed = strings.TrimSuffix(trimmed, ")")
+		}
+
+		if trimmed == link {
+			return link
+		}
+		link = trimmed
+	}
+}
+
+// If the link target is a local file, return its name and any line number.
+// Returns an empty name for links that should be handled by the opener.
+func localFileTarget(target string) (string, *linemetadata.Index) {
+	if strings.Contains(target, "://") {
+		parsed, err := url.Parse(target)
+		if err != nil || parsed.Scheme != "file" {
+			return "", nil
+		}
+
+		hostname, _ := os.Hostname()
+		if parsed.Host != "" && parsed.Host != "localhost" && parsed.Host != hostname {
+			// Some other machine's file
+			return "", nil
+		}
+
+		target = parsed.Path
+	}
+
+	// Like "~/notes.txt:3", shells would expand that
+	target = expandTilde(target)
+
+	if isRegularFile(target) {
+		return target, nil
+	}
+
+	match := fileLineTargetRegex.FindStringSubmatch(target)
+	if match == nil || !isRegularFile(match[1]) {
+		return "", nil
+	}
+
+	lineNumber, err := strconv.Atoi(match[2])
+	if err != nil || lineNumber < 1 {
+		return match[1], nil
+	}
+
+	index := linemetadata.IndexFromOneBased(lineNumber)
+	return match[1], &index
+}
+
+func isRegularFile(name string) bool {
+	stat, err := os.Stat(name)
+	return err == nil && stat.Mode().IsRegular()
+}
+
+// The command to open URLs with, unless the user configured one
+func defaultLinkOpener() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "open"
+	case "windows":
+		return "rundll32 url.dll,FileProtocolHandler"
+	}
+
+	return "xdg-open"
+}
+
+// Open a link target, either in a new reader if it's a local file, or using
+// the link opener command
+func (p *Pager) openLink(target string) {
+	if os.Getenv("LESSSECURE") == "1" {
+		p.mode = &PagerModeInfo{
+			Pager: p,
+			Text:  "Not opening links since LESSSECURE=1 is set in the environment",
+		}
+		return
+	}
+
+	if fileName, lineIndex := localFileTarget(target); fileName != "" {
+		here := len(p.backStack)
+		p.pushBackStack()
+		err := p.openFile(fileName, lineIndex)
+		if err != nil {
+			p.backStack = p.backStack[:here]
+			p.mode = &PagerModeInfo{Pager: p, Text: "Failed to open " + fileName + ": " + err.Error()}
+			return
+		}
+
+		p.mode = PagerModeViewing{pager: p}
+		return
+	}
+
+	opener := p.LinkOpener
+	if strings.TrimSpace(opener) == "" {
+		opener = defaultLinkOpener()
+	}
+
+	commandWithArgs := append(strings.Fields(opener), target)
+	log.Info("Opening link: ", commandWithArgs)
+	command := exec.Command(commandWithArgs[0], commandWithArgs[1:]...)
+
+	// Don't let the opener write on top of our screen
+	command.Stdout = nil
+	command.Stderr = nil
+
+	err := command.Start()
+	if err != nil {
+		log.Warn("Failed to open link: ", err)
+		p.mode = &PagerModeInfo{Pager: p, Text: "Failed to open link: " + err.Error()}
+		return
+	}
+
+	go func() {
+		// Reap the opener when it's done
+		err := command.Wait()
+		if err != nil {
+			log.Info("Link opener failed: ", err)
+		}
+	}()
+
+	p.mode = &PagerModeInfo{Pager: p, Text: "Opened " + target}
+}
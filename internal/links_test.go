@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/textstyles"
+	"github.com/walles/moor/v2/twin"
+	"gotest.tools/v3/assert"
+)
+
+func rowFromString(text string) renderedLine {
+	return renderedLine{cells: textstyles.StyledRunesFromString(twin.StyleDefault, text, nil, 0).StyledRunes}
+}
+
+func linkTargets(links []screenLink) []string {
+	targets := []string{}
+	for _, link := range links {
+		targets = append(targets, link.target)
+	}
+	return targets
+}
+
+func TestFindLinksInText(t *testing.T) {
+	links := findLinksInRow(0, rowFromString(
+		"See https://example.com/path?q=1. (or http://x.org) at links.go:12:5, not localhost:8080"))
+
+	assert.DeepEqual(t, linkTargets(links), []string{
+		"https://example.com/path?q=1",
+		"http://x.org",
+		"links.go:12:5",
+	})
+
+	// Cell indices
+	assert.Equal(t, links[0].start, 4)
+	assert.Equal(t, links[0].end, 4+len("https://example.com/path?q=1"))
+}
+
+func TestFindLinksHyperlinks(t *testing.T) {
+	url := "https://example.com/"
+	linked := twin.StyleDefault.WithHyperlink(&url)
+
+	row := rowFromString("Go here: x")
+	for i := 4; i < 8; i++ {
+		row.cells[i].Style = linked
+	}
+
+	links := findLinksInRow(3, row)
+	assert.Equal(t, len(links), 1)
+	assert.Equal(t, links[0], screenLink{row: 3, start: 4, end: 8, target: url})
+}
+
+func TestTrimLinkPunctuation(t *testing.T) {
+	assert.Equal(t, trimLinkPunctuation("https://example.com/."), "https://example.com/")
+	assert.Equal(t, trimLinkPunctuation("https://example.com/)."), "https://example.com/")
+	assert.Equal(t, trimLinkPunctuation("https://en.wikipedia.org/wiki/X_(Y)"), "https://en.wikipedia.org/wiki/X_(Y)")
+}
+
+func TestLocalFileTarget(t *testing.T) {
+	fileName, lineIndex := localFileTarget("links.go")
+	assert.Equal(t, fileName, "links.go")
+	assert.Assert(t, lineIndex == nil)
+
+	fileName, lineIndex = localFileTarget("links.go:12:5")
+	assert.Equal(t, fileName, "links.go")
+	assert.Equal(t, *lineIndex, linemetadata.IndexFromOneBased(12))
+
+	fileName, _ = localFileTarget("file:///etc/hosts")
+	assert.Equal(t, fileName, "/etc/hosts")
+
+	fileName, _ = localFileTarget("file://elsewhere.example.com/etc/hosts")
+	assert.Equal(t, fileName, "")
+
+	fileName, _ = localFileTarget("https://example.com/")
+	assert.Equal(t, fileName, "")
+
+	// Directories aren't files
+	fileName, _ = localFileTarget("reader:1")
+	assert.Equal(t, fileName, "")
+}
+
+func TestLocalFileTargetInHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home) // Windows
+	notes := filepath.Join(home, "notes.txt")
+	assert.NilError(t, os.WriteFile(notes, []byte("notes\n"), 0o600))
+
+	fileName, lineIndex := localFileTarget("~/notes.txt:3")
+	assert.Equal(t, fileName, notes)
+	assert.Equal(t, *lineIndex, linemetadata.IndexFromOneBased(3))
+
+	links := findLinks([]renderedLine{rowFromString("See ~/notes.txt:3 for more")})
+	assert.DeepEqual(t, linkTargets(links), []string{"~/notes.txt:3"})
+}
@@ -0,0 +1,346 @@
+package internal
+
+import (
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/walles/moor/v2/internal/linemetadata"
+)
+
+// Help text sections, in presentation order
+const (
+	helpGroupMiscellaneous = "Miscellaneous"
+	helpGroupMovingAround  = "Moving around"
+	helpGroupSwitchFiles   = "Switching files"
+	helpGroupFiltering     = "Filtering"
+	helpGroupSearching     = "Searching"
+
+	helpGroupSearchOverview = "In the search overview"
+	helpGroupFileList       = "In the file list"
+	helpGroupLinks          = "When selecting links"
+)
+
+// Something the user can bind a key sequence to, see Keymap
+type pagerAction struct {
+	name        string
+	helpGroup   string
+	description string
+	run         func(p *Pager)
+}
+
+// All actions available for key bindings, in help text order.
+//
+// This is a function rather than a variable to avoid an initialization cycle
+// through the help action.
+func pagerActions() []pagerAction {
+	return []pagerAction{
+		{"quit", helpGroupMiscellaneous, "quit", (*Pager).Quit},
+		{"toggle-wrap", helpGroupMiscellaneous, "toggle wrapping of long lines", (*Pager).toggleWrap},
+		{"toggle-statusbar", helpGroupMiscellaneous, "toggle showing the status bar at the bottom", func(p *Pager) {
+			p.ShowStatusBar = !p.ShowStatusBar
+		}},
+		{"edit", helpGroupMiscellaneous, "edit the file in your favorite editor", handleEditingRequest},
+		{"cycle-tab-size", helpGroupMiscellaneous, "change the tab size", (*Pager).cycleTabSize},
+		{"cycle-json-view", helpGroupMiscellaneous, "show JSON lines pretty printed, as key=value or only some fields", (*Pager).cycleJSONLinesView},
+		{"toggle-table-view", helpGroupMiscellaneous, "toggle showing CSV and TSV input as a table", (*Pager).toggleTableView},
+		{"toggle-frozen-column", helpGroupMiscellaneous, "keep the first table column visible when scrolling sideways", (*Pager).toggleFrozenColumn},
+		{"select-link", helpGroupMiscellaneous, "select a link on screen to open, 'TAB' steps to the next one", (*Pager).selectLink},
+		{"help", helpGroupMiscellaneous, "show this help", (*Pager).showHelp},
+
+		{"scroll-up", helpGroupMovingAround, "move to the previous line", func(p *Pager) {
+			// Clipping is done in _Redraw()
+			p.scrollPosition = p.scrollPosition.PreviousLine(1)
+			p.handleScrolledUp()
+		}},
+		{"scroll-down", helpGroupMovingAround, "move to the next line", func(p *Pager) {
+			// Clipping is done in _Redraw()
+			p.scrollPosition = p.scrollPosition.NextLine(1)
+			p.handleScrolledDown()
+		}},
+		{"scroll-left", helpGroupMovingAround, "scroll left, can be used to show line numbers", func(p *Pager) {
+			p.moveRight(-p.SideScrollAmount)
+		}},
+		{"scroll-right", helpGroupMovingAround, "scroll right, can be used to hide line numbers", func(p *Pager) {
+			p.moveRight(p.SideScrollAmount)
+		}},
+		{"scroll-left-one", helpGroupMovingAround, "step one column left", func(p *Pager) {
+			p.moveRight(-1)
+		}},
+		{"scroll-right-one", helpGroupMovingAround, "step one column right", func(p *Pager) {
+			p.moveRight(1)
+		}},
+		{"scroll-leftmost", helpGroupMovingAround, "move to the leftmost position", func(p *Pager) {
+			p.leftColumnZeroBased = 0
+			p.tableView.leftColumn = 0
+			if !p.showLineNumbers {
+				// Line numbers not visible, turn them on if the user wants them.
+				p.showLineNumbers = p.ShowLineNumbers
+			}
+		}},
+		{"page-up", helpGroupMovingAround, "move up a page", func(p *Pager) {
+			p.scrollPosition = p.scrollPosition.PreviousLine(p.visibleHeight())
+			p.handleScrolledUp()
+		}},
+		{"page-down", helpGroupMovingAround, "move down a page", func(p *Pager) {
+			p.scrollPosition = p.scrollPosition.NextLine(p.visibleHeight())
+			p.handleScrolledDown()
+		}},
+		{"half-page-up", helpGroupMovingAround, "move up half a page", func(p *Pager) {
+			p.scrollPosition = p.scrollPosition.PreviousLine(p.visibleHeight() / 2)
+			p.handleScrolledUp()
+		}},
+		{"half-page-down", helpGroupMovingAround, "move down half a page", func(p *Pager) {
+			p.scrollPosition = p.scrollPosition.NextLine(p.visibleHeight() / 2)
+			p.handleScrolledDown()
+		}},
+		{"goto-start", helpGroupMovingAround, "go to the start of the document", func(p *Pager) {
+			p.stopShowingFileEnd()
+			p.scrollPosition = newScrollPosition("Pager scroll position")
+			p.handleScrolledUp()
+		}},
+		{"goto-end", helpGroupMovingAround, "go to the end of the document", (*Pager).scrollToEnd},
+		{"goto-line", helpGroupMovingAround, "go to a specific line number", func(p *Pager) {
+			p.mode = NewPagerModeGotoLine(p)
+			p.setTargetLine(nil)
+		}},
+		{"set-mark", helpGroupMovingAround, "set a mark, you will be asked for a letter to label it with", func(p *Pager) {
+			p.mode = PagerModeMark{pager: p}
+			p.setTargetLine(nil)
+		}},
+		{"jump-to-mark", helpGroupMovingAround, "jump to a mark", func(p *Pager) {
+			p.mode = PagerModeJumpToMark{pager: p}
+			p.setTargetLine(nil)
+		}},
+
+		{"switch-file", helpGroupSwitchFiles, "enter file switching mode, then 'e' opens another file and 'l' lists files", func(p *Pager) {
+			if p.isShowingHelp {
+				return
+			}
+
+			p.mode = &PagerModeColonCommand{pager: p}
+			p.setTargetLine(nil)
+		}},
+		{"last-file", helpGroupSwitchFiles, "switch to the file you were looking at before this one", (*Pager).lastFile},
+		{"go-back", helpGroupSwitchFiles, "go back from a file opened with 'l'", (*Pager).goBack},
+
+		{"filter", helpGroupFiltering, "add a filter, then type your filter expression", func(p *Pager) {
+			if p.isShowingHelp {
+				// Filtering the help text is not supported. Feel free to work
+				// on that if you feel that's time well spent.
+				return
+			}
+
+			p.mode = NewPagerModeFilter(p)
+			p.search.Clear()
+		}},
+
+		{"filter-context", helpGroupFiltering, "set how many lines to show around filter matches", func(p *Pager) {
+			p.mode = NewPagerModeFilterContext(p)
+		}},
+		{"cycle-log-level", helpGroupFiltering, "hide log lines below a severity level, cycles DEBUG, INFO, WARN, ERROR and off", func(p *Pager) {
+			if p.isShowingHelp {
+				return
+			}
+			p.cycleLogLevel()
+		}},
+
+		{"search-forward", helpGroupSearching, "start searching, then type what you want to find", func(p *Pager) {
+			p.startSearch(SearchDirectionForward)
+		}},
+		{"search-backward", helpGroupSearching, "search backwards, then type what you want to find", func(p *Pager) {
+			p.startSearch(SearchDirectionBackward)
+		}},
+		{"search-next", helpGroupSearching, "find next", (*Pager).scrollToNextSearchHit},
+		{"search-previous", helpGroupSearching, "find previous", (*Pager).scrollToPreviousSearchHit},
+		{"search-overview", helpGroupSearching, "list all search hits, ENTER jumps to the selected one", func(p *Pager) {
+			if p.search.Inactive() {
+				p.mode = &PagerModeInfo{Pager: p, Text: "Search for something first to list the hits."}
+				return
+			}
+			p.mode = NewPagerModeSearchOverview(p)
+		}},
+		{"pin-highlight", helpGroupSearching, "pin highlight patterns, each in its own color", func(p *Pager) {
+			p.mode = NewPagerModePinHighlight(p)
+		}},
+	}
+}
+
+// The actions for one mode with its own key bindings, see Keymap
+type modeActionTable struct {
+	mode    keymapMode
+	actions []pagerAction
+}
+
+// Actions for all modes with their own key bindings, in help text order
+func modeActions() []modeActionTable {
+	return []modeActionTable{
+		{keymapModeViewing, pagerActions()},
+		{keymapModeSearchOverview, searchOverviewActions()},
+		{keymapModeFileList, fileListActions()},
+		{keymapModeLinks, linksActions()},
+	}
+}
+
+// Wrap an action for a particular mode, so that it can be run like the
+// viewing mode actions
+func inMode[M PagerMode](run func(m M)) func(p *Pager) {
+	return func(p *Pager) {
+		m, ok := p.mode.(M)
+		if !ok {
+			log.Debugf("Not running mode action in %T", p.mode)
+			return
+		}
+
+		run(m)
+	}
+}
+
+// Actions by name, for looking them up on every key press
+var pagerActionsByName = sync.OnceValue(func() map[string]pagerAction {
+	byName := map[string]pagerAction{}
+	for _, mode := range modeActions() {
+		for _, action := range mode.actions {
+			byName[action.name] = action
+		}
+	}
+	return byName
+})
+
+// Which mode each action is for, by action name
+var pagerActionModes = sync.OnceValue(func() map[string]keymapMode {
+	modes := map[string]keymapMode{}
+	for _, mode := range modeActions() {
+		for _, action := range mode.actions {
+			modes[action.name] = mode.mode
+		}
+	}
+	return modes
+})
+
+// Returns nil if there is no action with this name
+func findPagerAction(name string) *pagerAction {
+	action, found := pagerActionsByName()[name]
+	if !found {
+		return nil
+	}
+
+	return &action
+}
+
+// Extra non-key-binding information for the help text sections
+var helpGroupNotes = map[string]string{
+	helpGroupFiltering: `
+Start a filter expression with '!' to hide matching lines instead.
+
+Filters stack up: Every time you filter, the new filter is added to the
+previous ones, and only lines accepted by all filters are shown.
+
+Context lines around matches are shown dimmed, with "--" between groups.
+
+While filtering, arrow keys, PageUp, PageDown, Home and End work as usual.
+Press BACKSPACE in an empty filter to remove the previous filter.
+
+Press RETURN to exit filtering mode, or 'ESC' to drop the filter you are
+typing.`,
+
+	helpGroupSearching: `
+* Type RETURN to stop searching, or ESC to skip back to where the search started
+* Press up / down arrows while searching to access search history
+* Search is case sensitive if it contains any UPPER CASE CHARACTERS
+* Search is interpreted as a regexp if it is a valid one`,
+}
+
+// Generate the help text from the key bindings in the keymap
+func generateHelpText(keymap *Keymap) string {
+	var help strings.Builder
+	help.WriteString("Welcome to Moor, the nice pager!\n")
+
+	for _, group := range []string{
+		helpGroupMiscellaneous,
+		helpGroupMovingAround,
+		helpGroupSwitchFiles,
+		helpGroupFiltering,
+		helpGroupSearching,
+		helpGroupSearchOverview,
+		helpGroupFileList,
+		helpGroupLinks,
+	} {
+		help.WriteString("\n" + group + "\n")
+		help.WriteString(strings.Repeat("-", len(group)) + "\n")
+
+		for _, mode := range modeActions() {
+			for _, action := range mode.actions {
+				if action.helpGroup != group {
+					continue
+				}
+
+				keys := keymap.keysFor(action.name)
+				if len(keys) == 0 {
+					continue
+				}
+
+				help.WriteString("* " + strings.Join(keys, " / ") + " to " + action.description + "\n")
+			}
+		}
+
+		if notes, found := helpGroupNotes[group]; found {
+			help.WriteString(notes + "\n")
+		}
+	}
+
+	help.WriteString(`
+Key bindings can be changed in the [keys] section of the config file.
+
+Reporting bugs
+--------------
+File issues at https://github.com/walles/moor/issues, or post
+questions to johan.walles@gmail.com.
+
+Installing Moor as your default pager
+-------------------------------------
+Put the following line in your ~/.bashrc, ~/.bash_profile or ~/.zshrc:
+  export PAGER=moor
+
+Source Code
+-----------
+Available at https://github.com/walles/moor/.
+`)
+
+	return help.String()
+}
+
+func (p *Pager) showHelp() {
+	if p.isShowingHelp {
+		return
+	}
+
+	p.preHelpState = &_PreHelpState{
+		scrollPosition:      p.scrollPosition,
+		leftColumnZeroBased: p.leftColumnZeroBased,
+		targetLine:          p.TargetLine,
+	}
+	p.scrollPosition = newScrollPosition("Pager scroll position")
+	p.leftColumnZeroBased = 0
+	p.setTargetLine(nil)
+	p.helpReader = newHelpReader(p.Keymap)
+	p.isShowingHelp = true
+}
+
+func (p *Pager) startSearch(direction SearchDirection) {
+	p.mode = NewPagerModeSearch(p, direction, p.scrollPosition)
+	p.search.Clear()
+
+	// Searchers want to scan the whole file, start reading as much as we can
+	reallyHigh := linemetadata.IndexMax()
+	p.setTargetLine(&reallyHigh)
+}
+
+func (p *Pager) toggleWrap() {
+	p.WrapLongLines = !p.WrapLongLines
+	if p.WrapLongLines {
+		p.mode = &PagerModeInfo{Pager: p, Text: "Word wrapping enabled"}
+	} else {
+		p.mode = &PagerModeInfo{Pager: p, Text: "Word wrapping disabled"}
+	}
+}
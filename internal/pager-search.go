@@ -50,6 +50,7 @@ func (p *Pager) scrollToSearchHits() {
 	p.scrollPosition = NewScrollPositionFromIndex(*firstHitIndex, "scrollToSearchHits")
 
 	p.leftColumnZeroBased = 0
+	p.tableView.leftColumn = 0
 	p.showLineNumbers = p.ShowLineNumbers
 	if !p.searchHitIsVisible() {
 		p.scrollRightToSearchHits()
@@ -96,7 +97,7 @@ func (p *Pager) scrollToNextSearchHit() {
 		panic(fmt.Sprint("Unknown search mode when finding next: ", p.mode))
 	}
 
-	firstHitIndex := FindFirstHit(p.Reader(), p.search, firstSearchIndex, nil, SearchDirectionForward)
+	firstHitIndex := p.findHit(firstSearchIndex, SearchDirectionForward)
 	if firstHitIndex == nil {
 		p.mode = PagerModeNotFound{pager: p}
 		return
@@ -107,6 +108,7 @@ func (p *Pager) scrollToNextSearchHit() {
 	p.setTargetLine(nil)
 
 	p.leftColumnZeroBased = 0
+	p.tableView.leftColumn = 0
 	p.showLineNumbers = p.ShowLineNumbers
 	if !p.searchHitIsVisible() {
 		p.scrollRightToSearchHits()
@@ -213,7 +215,7 @@ func (p *Pager) scrollToPreviousSearchHit() {
 		panic(fmt.Sprint("Unknown search mode when finding previous: ", p.mode))
 	}
 
-	hitIndex := FindFirstHit(p.Reader(), p.search, firstSearchIndex, nil, SearchDirectionBackward)
+	hitIndex := p.findHit(firstSearchIndex, SearchDirectionBackward)
 	if hitIndex == nil {
 		p.mode = PagerModeNotFound{pager: p}
 		return
@@ -238,7 +240,8 @@ func (p *Pager) scrollToPreviousSearchHit() {
 // it may be off-screen to the right. If that happens, the user can scroll right
 // manually to see the rest of the hit.
 func (p *Pager) searchHitIsVisible() bool {
-	for _, row := range p.renderLines().lines {
+	rendered := p.renderLines()
+	for _, row := range append(rendered.headerLines, rendered.lines...) {
 		for _, cell := range row.cells {
 			if cell.StartsSearchHit {
 				// Found a search hit on screen!
@@ -302,6 +305,11 @@ func (p *Pager) centerSearchHitsVertically() {
 // If we are alredy too far right when you call this method, it will scroll
 // left.
 func (p *Pager) scrollMaxRight() {
+	if p.tableSeparator() != 0 {
+		p.tableView.scrollColumns(len(p.tableView.columnWidths))
+		return
+	}
+
 	if p.WrapLongLines {
 		// No horizontal scrolling when wrapping
 		return
@@ -345,6 +353,10 @@ func (p *Pager) scrollMaxRight() {
 
 // Scroll right looking for search hits. Return true if we found any.
 func (p *Pager) scrollRightToSearchHits() bool {
+	if p.tableSeparator() != 0 {
+		return p.scrollColumnsToSearchHits(true)
+	}
+
 	if p.WrapLongLines {
 		// No horizontal scrolling when wrapping
 		return false
@@ -443,6 +455,10 @@ func (p *Pager) scrollRightToSearchHits() bool {
 
 // Scroll left looking for search hits. Return true if we found any.
 func (p *Pager) scrollLeftToSearchHits() bool {
+	if p.tableSeparator() != 0 {
+		return p.scrollColumnsToSearchHits(false)
+	}
+
 	if p.WrapLongLines {
 		// No horizontal scrolling when wrapping
 		return false
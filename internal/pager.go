@@ -45,15 +45,28 @@ type eventMaybeDone struct{}
 
 // Pager is the main on-screen pager
 type Pager struct {
-	readers       []*reader.ReaderImpl // Immutable since startup, no locking needed
+	readers       []*reader.ReaderImpl // See openFile() and dropFile()
 	currentReader int                  // Index into the readers slice
-	readerLock    sync.Mutex           // Protects currentReader
+	readerLock    sync.Mutex           // Protects readers and currentReader
 
 	readerSwitched chan struct{}
 
+	// Where to return to from files opened inside the pager, see goBack()
+	backStack []backStackEntry
+
+	// How we last saw the files we aren't looking at right now
+	viewStates map[*reader.ReaderImpl]viewState
+
+	// The file we looked at before the current one, see lastFile()
+	lastReader *reader.ReaderImpl
+
 	// A view of the current reader, possibly filtered
 	filteringReader FilteringReader
 
+	// The end of the current file, shown until all of the file has been read.
+	// See showFileEnd().
+	fileEnd *reader.ReaderImpl
+
 	screen              twin.Screen
 	quit                bool
 	scrollPosition      scrollPosition
@@ -69,13 +82,36 @@ type Pager struct {
 	// This should never be null while paging. Configured in NewPager().
 	searchHistory *SearchHistory
 
-	filter search.Search
+	// All hits for the current search, see updateSearchIndex()
+	searchIndex *searchIndex
+
+	// Receives a value when background search indexing has made progress
+	searchIndexProgress chan bool
+
+	filter Filter
+
+	// Highlighted in addition to the search, see PagerModePinHighlight
+	pinnedHighlights []reader.PinnedHighlight
+
+	// How to show JSON Lines input, see cycleJSONLinesView()
+	jsonLinesView reader.JSONLinesView
+
+	// How to show CSV and TSV input, see toggleTableView()
+	tableView tableView
 
 	// We used to have a "Following" field here. If you want to follow, set
 	// TargetLineNumber to linemetadata.IndexMax() instead, see below.
 
 	isShowingHelp bool
 	preHelpState  *_PreHelpState
+	helpReader    *reader.ReaderImpl
+
+	// Key bindings for viewing mode. Configured in NewPager(), replace to
+	// customize.
+	Keymap *Keymap
+
+	// The beginning of a multi key sequence, see Keymap
+	pendingKeys []keyStroke
 
 	// User preference
 	ShowLineNumbers bool
@@ -101,6 +137,30 @@ type Pager struct {
 
 	TabSize int // Number of spaces per tab, default 8, should be positive
 
+	// Command for opening links that aren't local files, like "xdg-open". The
+	// link is added as the last argument. Empty means a platform default.
+	LinkOpener string
+
+	// Like --reformat, for files opened from inside the pager
+	ShouldFormat bool
+
+	// Run files opened from inside the pager through $LESSOPEN, unless
+	// --no-lessopen was given
+	LessOpen bool
+
+	// For highlighting files opened from inside the pager, set in StartPaging()
+	chromaStyle     *chroma.Style
+	chromaFormatter *chroma.Formatter
+
+	// Like less' --header, keep this many lines at the top and this many
+	// screen columns to the left in view while scrolling
+	HeaderLines   int
+	HeaderColumns int
+
+	// Like grep's -B and -A, show this many lines around filter matches
+	FilterContextBefore int
+	FilterContextAfter  int
+
 	// If non-nil, scroll to this line as soon as possible. Set this value to
 	// IndexMax() to follow the end of the input (tail).
 	//
@@ -131,6 +191,10 @@ type Pager struct {
 	// Ref: https://github.com/walles/moor/issues/175
 	bookmarks map[rune]scrollPosition
 
+	// If set, the last scroll position and the bookmarks are saved here on
+	// exit and restored on startup. Nil disables this.
+	PositionHistory *PositionHistory
+
 	AfterExit func() error
 }
 
@@ -140,73 +204,9 @@ type _PreHelpState struct {
 	targetLine          *linemetadata.Index
 }
 
-var _HelpReader = reader.NewFromTextForTesting("Help", `
-Welcome to Moor, the nice pager!
-
-Miscellaneous
--------------
-* Press 'q' or 'ESC' to quit
-* Press 'w' to toggle wrapping of long lines
-* Press '=' to toggle showing the status bar at the bottom
-* Press 'v' to edit the file in your favorite editor
-* Press CTRL-t to change the tab size
-
-Moving around
--------------
-* Arrow keys
-* Alt key plus left / right arrow steps one column at a time
-* Left / right can be used to hide / show line numbers
-* Home and End for start / end of the document
-* 'g' for going to a specific line number
-* 'm' sets a mark, you will be asked for a letter to label it with
-* ' (single quote) jumps to the mark
-* CTRL-p moves to the previous line
-* CTRL-n moves to the next line
-* PageUp / 'b' and PageDown / 'f'
-* SPACE moves down a page
-* < / 'gg' to go to the start of the document
-* > / 'G' to go to the end of the document
-* Half page 'u'p / 'd'own, or CTRL-u / CTRL-d
-* CTRL-a moves to the leftmost position
-* RETURN moves down one line
-
-Switching files (if you opened multiple files)
-----------------------------------------------
-* Press ':' to enter file switching mode
-
-Filtering
----------
-Type '&' to start filtering, then type your filter expression.
-
-While filtering, arrow keys, PageUp, PageDown, Home and End work as usual.
-
-Press 'ESC' or RETURN to exit filtering mode.
-
-Searching
----------
-* Type / to start searching, then type what you want to find
-* Type ? to search backwards, then type what you want to find
-* Type RETURN to stop searching, or ESC to skip back to where the search started
-* Press up / down arrows while searching to access search history
-* Find next by typing 'n' (for "next")
-* Find previous by typing SHIFT-N or 'p' (for "previous")
-* Search is case sensitive if it contains any UPPER CASE CHARACTERS
-* Search is interpreted as a regexp if it is a valid one
-
-Reporting bugs
---------------
-File issues at https://github.com/walles/moor/issues, or post
-questions to johan.walles@gmail.com.
-
-Installing Moor as your default pager
--------------------------------------
-Put the following line in your ~/.bashrc, ~/.bash_profile or ~/.zshrc:
-  export PAGER=moor
-
-Source Code
------------
-Available at https://github.com/walles/moor/.
-`)
+func newHelpReader(keymap *Keymap) *reader.ReaderImpl {
+	return reader.NewFromTextForTesting("Help", generateHelpText(keymap))
+}
 
 // NewPager creates a new Pager with default settings
 func NewPager(readers ...*reader.ReaderImpl) *Pager {
@@ -225,6 +225,7 @@ func NewPager(readers ...*reader.ReaderImpl) *Pager {
 		readers:                     readers,
 		currentReader:               0,
 		readerSwitched:              make(chan struct{}, 1),
+		viewStates:                  map[*reader.ReaderImpl]viewState{},
 		quit:                        false,
 		ShowLineNumbers:             true, // Constant throghout the lifetime of the pager
 		showLineNumbers:             true, // Will be updated over time
@@ -236,13 +237,15 @@ func NewPager(readers ...*reader.ReaderImpl) *Pager {
 		ScrollRightHint:             textstyles.CellWithMetadata{Rune: '>', Style: twin.StyleDefault.WithAttr(twin.AttrReverse)},
 		scrollPosition:              newScrollPosition(name),
 		WithSearchHitLineBackground: true,
+		Keymap:                      NewKeymap(),
+		searchIndexProgress:         make(chan bool, 1),
 	}
 
 	pager.mode = PagerModeViewing{pager: &pager}
-	pager.filteringReader = FilteringReader{
-		BackingReader: readers[0], // Always start with the first reader
-		Filter:        &pager.filter,
-	}
+	pager.filteringReader = NewFilteringReader(
+		readers[0], // Always start with the first reader
+		&pager.filter,
+	)
 
 	searchHistory := BootSearchHistory("")
 	pager.searchHistory = &searchHistory
@@ -250,9 +253,14 @@ func NewPager(readers ...*reader.ReaderImpl) *Pager {
 	return &pager
 }
 
-// How many lines are visible on screen? Depends on screen height and whether or
-// not the status bar is visible.
+// How many lines are visible on screen? Depends on screen height, whether or
+// not the status bar is visible and on how many header lines we show.
 func (p *Pager) visibleHeight() int {
+	return p.contentsHeight() - p.headerLineCount()
+}
+
+// Screen height minus the status bar, if any
+func (p *Pager) contentsHeight() int {
 	_, height := p.screen.Size()
 
 	// Only the viewing mode can be without status bar
@@ -265,6 +273,60 @@ func (p *Pager) visibleHeight() int {
 	return height
 }
 
+// How many lines at the top of the input stay on screen while scrolling
+func (p *Pager) headerLineCount() int {
+	if p.isShowingHelp {
+		return 0
+	}
+
+	count := p.HeaderLines
+	if p.tableSeparator() != 0 {
+		// The table header row
+		count = max(count, 1)
+	}
+	if count == 0 {
+		return 0
+	}
+
+	if p.filteringReader.BackingReader.GetLineCount() <= count {
+		// Nothing to scroll below the header, show it as normal lines
+		return 0
+	}
+
+	if p.droppedLineCount() > 0 {
+		// The header lines are gone, see --max-lines
+		return 0
+	}
+
+	// Leave room for at least one scrolling line
+	return max(0, min(count, p.contentsHeight()-1))
+}
+
+// How many screen columns at the start of each line stay put when scrolling
+// sideways. Tables freeze whole columns instead, see toggleFrozenColumn().
+func (p *Pager) frozenColumnCount() int {
+	if p.isShowingHelp || p.tableSeparator() != 0 {
+		return 0
+	}
+
+	return p.HeaderColumns
+}
+
+// Lines above this index are shown as header lines or have been dropped, so
+// there's no point in scrolling up to them.
+func (p *Pager) firstScrollableIndex() linemetadata.Index {
+	if p.isShowingHelp {
+		return linemetadata.Index{}
+	}
+
+	if !p.filteringReader.shouldPassThrough() {
+		// When filtering, the header lines are elsewhere if they match at all
+		return linemetadata.IndexFromZeroBased(p.filteringReader.droppedLineCount())
+	}
+
+	return linemetadata.IndexFromZeroBased(max(p.headerLineCount(), p.droppedLineCount()))
+}
+
 // How many cells are needed for this line number? Includes padding.
 //
 // Returns 0 if line numbers are disabled.
@@ -376,6 +438,21 @@ func (p *Pager) moveRight(delta int) {
 		return
 	}
 
+	if p.tableSeparator() != 0 {
+		// Tables scroll one column at a time
+		if p.tableView.leftColumn == 0 && delta < 0 {
+			p.showLineNumbers = true
+			return
+		}
+
+		if delta > 0 {
+			p.tableView.scrollColumns(1)
+		} else if delta < 0 {
+			p.tableView.scrollColumns(-1)
+		}
+		return
+	}
+
 	if p.leftColumnZeroBased == 0 && delta < 0 {
 		p.showLineNumbers = true
 		return
@@ -397,7 +474,7 @@ func (p *Pager) moveRight(delta int) {
 
 func (p *Pager) Reader() reader.Reader {
 	if p.isShowingHelp {
-		return _HelpReader
+		return p.helpReader
 	}
 	return &p.filteringReader
 }
@@ -425,6 +502,12 @@ func (p *Pager) setTargetLine(targetLine *linemetadata.Index) {
 
 	log.Trace("Pager: Setting target line to ", targetLine, "...")
 	p.TargetLine = targetLine
+	if p.fileEnd != nil {
+		// The full reader takes over from the file end when it's done
+		r.SetPauseAfterLines(math.MaxInt)
+		return
+	}
+
 	if targetLine == nil {
 		// No target, just do your thing
 		r.SetPauseAfterLines(reader.DEFAULT_PAUSE_AFTER_LINES)
@@ -455,9 +538,18 @@ func (p *Pager) StartPaging(screen twin.Screen, chromaStyle *chroma.Style, chrom
 		if r.Err != nil {
 			log.Warnf("Reader reported an error: %s", r.Err.Error())
 		}
+
+		// Let go of the input files and any $LESSOPEN preprocessors
+		p.readerLock.Lock()
+		for _, stopMe := range p.readers {
+			stopMe.Stop()
+		}
+		p.readerLock.Unlock()
 	}()
 
 	p.showLineNumbers = p.ShowLineNumbers
+	p.filter.contextBefore = p.FilterContextBefore
+	p.filter.contextAfter = p.FilterContextAfter
 
 	textstyles.UnprintableStyle = p.UnprintableStyle
 	if p.TabSize > 0 {
@@ -469,8 +561,12 @@ func (p *Pager) StartPaging(screen twin.Screen, chromaStyle *chroma.Style, chrom
 	styleUI(screen.TerminalBackground(), chromaStyle, chromaFormatter, p.StatusBarStyle, p.WithTerminalFg, p.WithSearchHitLineBackground)
 
 	p.screen = screen
+	p.chromaStyle = chromaStyle
+	p.chromaFormatter = chromaFormatter
 	p.mode = PagerModeViewing{pager: p}
 	p.bookmarks = make(map[rune]scrollPosition)
+	p.restorePosition()
+	defer p.rememberPosition()
 
 	// Make sure the reader knows how many lines we want
 	p.setTargetLine(p.TargetLine)
@@ -495,20 +591,26 @@ func (p *Pager) StartPaging(screen twin.Screen, chromaStyle *chroma.Style, chrom
 		for {
 			p.readerLock.Lock()
 			r := p.readers[p.currentReader]
+			linesReader := r
+			if p.fileEnd != nil {
+				linesReader = p.fileEnd
+			}
 			p.readerLock.Unlock()
 
 			select {
 			case <-p.readerSwitched:
-				// A different reader is now active
-				p.filter = search.Search{}
-
+				// A different reader is now active, see restoreViewStateLocked()
+				// and showFileEnd()
 				p.readerLock.Lock()
 				r = p.readers[p.currentReader]
-				p.filteringReader.SetBackingReader(r)
+				linesReader = r
+				if p.fileEnd != nil {
+					linesReader = p.fileEnd
+				}
 				p.readerLock.Unlock()
 
 				// Look in the right place for more lines
-				throttledMoreLines = r.MoreLinesAdded
+				throttledMoreLines = linesReader.MoreLinesAdded
 				reenable = nil
 
 				// Reset spinner for new reader so that we show it again if needed
@@ -527,7 +629,7 @@ func (p *Pager) StartPaging(screen twin.Screen, chromaStyle *chroma.Style, chrom
 
 			case <-reenable:
 				// Re-enable channel
-				throttledMoreLines = r.MoreLinesAdded
+				throttledMoreLines = linesReader.MoreLinesAdded
 				reenable = nil
 
 			case <-spinnerTicker.C:
@@ -550,6 +652,14 @@ func (p *Pager) StartPaging(screen twin.Screen, chromaStyle *chroma.Style, chrom
 				screen.Events() <- eventSpinnerUpdate{currentSpinnerFrame}
 				lastSpinnerFrame = currentSpinnerFrame
 
+			case <-p.filteringReader.Progress:
+				// Background filtering has more lines for us
+				screen.Events() <- eventMoreLinesAvailable{}
+
+			case <-p.searchIndexProgress:
+				// Update the hit count
+				screen.Events() <- eventMoreLinesAvailable{}
+
 			case <-r.MaybeDone:
 				screen.Events() <- eventMaybeDone{}
 			}
@@ -599,11 +709,15 @@ func (p *Pager) StartPaging(screen twin.Screen, chromaStyle *chroma.Style, chrom
 		switch event := event.(type) {
 		case twin.EventKeyCode:
 			log.Tracef("Handling key event %d...", event.KeyCode())
-			p.mode.onKey(event.KeyCode())
+			if !p.continueKeySequence(keyStroke{keyCode: event.KeyCode()}) {
+				p.mode.onKey(event.KeyCode())
+			}
 
 		case twin.EventRune:
 			log.Tracef("Handling rune event '%c'/0x%04x...", event.Rune(), event.Rune())
-			p.mode.onRune(event.Rune())
+			if !p.continueKeySequence(keyStroke{isRune: true, char: event.Rune()}) {
+				p.mode.onRune(event.Rune())
+			}
 
 		case twin.EventMouse:
 			log.Tracef("Handling mouse event %d...", event.Buttons())
@@ -631,12 +745,16 @@ func (p *Pager) StartPaging(screen twin.Screen, chromaStyle *chroma.Style, chrom
 			return
 
 		case eventMoreLinesAvailable:
+			p.maybeStopShowingFileEnd()
+			p.moveOffDroppedLines()
+
 			// Without the isViewing() check, following will continue while
 			// searching, and I prefer it to stop so people can see what they
 			// are searching in.
 			if p.isViewing() && p.TargetLine != nil {
 				// The user wants to scroll down to a specific line number
-				if linemetadata.IndexFromLength(p.Reader().GetLineCount()).IsBefore(*p.TargetLine) {
+				lastIndex := linemetadata.IndexFromLength(p.Reader().GetLineCount())
+				if lastIndex == nil || lastIndex.IsBefore(*p.TargetLine) {
 					// Not there yet, keep scrolling
 					p.scrollToEnd()
 				} else {
@@ -647,6 +765,8 @@ func (p *Pager) StartPaging(screen twin.Screen, chromaStyle *chroma.Style, chrom
 			}
 
 		case eventMaybeDone:
+			p.maybeStopShowingFileEnd()
+
 			// Man pages come pre-formatted for the screen width, and line
 			// numbers will mess that up. So we disable line numbers if we
 			// detect a man page by its contents.
@@ -737,7 +857,7 @@ func (p *Pager) fitsOnOneScreen() bool {
 
 	lines := reader.GetLines(linemetadata.Index{}, reader.GetLineCount())
 	for _, line := range lines.Lines {
-		rendered := line.HighlightedTokens(twin.StyleDefault, twin.StyleDefault, search.Search{}, width+1).StyledRunes
+		rendered := line.HighlightedTokens(twin.StyleDefault, twin.StyleDefault, search.Search{}, nil, width+1).StyledRunes
 		if len(rendered) > width {
 			// This line is too long to fit on one screen line, no fit
 			return false
@@ -752,7 +872,7 @@ func (p *Pager) fitsOnOneScreen() bool {
 func (p *Pager) ReprintAfterExit() {
 	// Figure out how many screen lines are used by pager contents
 	renderedScreen := p.renderLines()
-	screenLinesCount := len(renderedScreen.lines)
+	screenLinesCount := len(renderedScreen.headerLines) + len(renderedScreen.lines)
 
 	_, screenHeight := p.screen.Size()
 	screenHeightWithoutFooter := screenHeight - p.DeInitFalseMargin
@@ -533,6 +533,10 @@ func TestPageSamples(t *testing.T) {
 			pager.ShowLineNumbers = false
 			pager.showLineNumbers = false
 
+			// The table view rearranges CSV files, that's tested in
+			// table-view_test.go
+			pager.tableView = tableView{detected: true}
+
 			// Heigh 3 = two lines of contents + one footer
 			screen := twin.NewFakeScreen(10, 3)
 
@@ -14,7 +14,14 @@ func (m *PagerModeColonCommand) drawFooter(_ string, _ string, _ string) {
 	_, height := p.screen.Size()
 
 	pos := 0
-	for _, token := range "Go to [n]ext, [p]revious or first [x] file: " {
+	prompt := "[e]xamine a new file or [l]ist files: "
+	p.readerLock.Lock()
+	if len(p.readers) > 1 {
+		prompt = "Go to [n]ext, [p]revious or first [x] file, [e]xamine a new file, [d]rop this one or [l]ist files: "
+	}
+	p.readerLock.Unlock()
+
+	for _, token := range prompt {
 		pos += p.screen.SetCell(pos, height-1, twin.NewStyledRune(token, twin.StyleDefault))
 	}
 
@@ -62,5 +69,21 @@ func (m *PagerModeColonCommand) onRune(char rune) {
 		return
 	}
 
+	if char == 'e' {
+		p.mode = NewPagerModeOpenFile(p)
+		return
+	}
+
+	if char == 'd' {
+		p.mode = PagerModeViewing{pager: p}
+		p.dropFile(p.currentReaderIndex())
+		return
+	}
+
+	if char == 'l' {
+		p.mode = NewPagerModeFileList(p)
+		return
+	}
+
 	log.Debugf("Unhandled colon command rune %q, ignoring it", char)
 }
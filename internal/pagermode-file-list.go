@@ -0,0 +1,151 @@
+// Lists the open files in a panel at the bottom of the screen
+
+package internal
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/walles/moor/v2/internal/util"
+	"github.com/walles/moor/v2/twin"
+)
+
+type PagerModeFileList struct {
+	pager *Pager
+
+	// Index into the readers
+	selected int
+
+	// The first file shown in the panel
+	firstShown int
+}
+
+func NewPagerModeFileList(p *Pager) *PagerModeFileList {
+	return &PagerModeFileList{
+		pager:    p,
+		selected: p.currentReaderIndex(),
+	}
+}
+
+// One line per file, for files with a name, a line count and a read status
+func (p *Pager) fileListLines() []string {
+	p.readerLock.Lock()
+	defer p.readerLock.Unlock()
+
+	nameWidth := 0
+	names := []string{}
+	for _, r := range p.readers {
+		name := "<stdin>"
+		if r.DisplayName != nil {
+			name = *r.DisplayName
+		}
+		names = append(names, name)
+		nameWidth = max(nameWidth, utf8.RuneCountInString(name))
+	}
+
+	lines := []string{}
+	for i, r := range p.readers {
+		current := " "
+		if i == p.currentReader {
+			current = "*"
+		}
+
+		status := "complete"
+		if err := r.GetError(); err != nil {
+			status = "failed: " + err.Error()
+		} else if !r.ReadingDone.Load() {
+			status = "reading..."
+		}
+
+		lines = append(lines, fmt.Sprintf("%s %d  %-*s  %s lines, %s",
+			current, i+1, nameWidth, names[i], util.FormatInt(r.GetLineCount()), status))
+	}
+
+	return lines
+}
+
+func (m *PagerModeFileList) panelHeight(fileCount int) int {
+	return max(min(fileCount, m.pager.visibleHeight()/2), 1)
+}
+
+func (m *PagerModeFileList) drawFooter(_ string, _ string, _ string) {
+	p := m.pager
+	width, height := p.screen.Size()
+
+	lines := p.fileListLines()
+	panelHeight := m.panelHeight(len(lines))
+	firstRow := height - 1 - panelHeight
+
+	m.selected = max(0, min(m.selected, len(lines)-1))
+
+	// Keep the selected file in view
+	if m.selected < m.firstShown {
+		m.firstShown = m.selected
+	}
+	if m.selected >= m.firstShown+panelHeight {
+		m.firstShown = m.selected - panelHeight + 1
+	}
+
+	for row := range panelHeight {
+		screenRow := firstRow + row
+		style := twin.StyleDefault
+		if m.firstShown+row == m.selected {
+			style = statusbarStyle
+		}
+
+		text := ""
+		if m.firstShown+row < len(lines) {
+			text = lines[m.firstShown+row]
+		}
+
+		column := 0
+		for _, char := range text {
+			column += p.screen.SetCell(column, screenRow, twin.NewStyledRune(char, style))
+		}
+		for column < width {
+			column += p.screen.SetCell(column, screenRow, twin.NewStyledRune(' ', style))
+		}
+	}
+
+	status := fmt.Sprintf("File %d/%d", m.selected+1, len(lines))
+	p.setFooter("", "", status, footerHints(p.Keymap,
+		footerHint{"file-list-switch", "to switch"},
+		footerHint{"file-list-drop", "to drop"},
+		footerHint{"file-list-back", "to go back"}))
+}
+
+// Actions for the file list key bindings, see Keymap
+func fileListActions() []pagerAction {
+	return []pagerAction{
+		{"file-list-switch", helpGroupFileList, "switch to the selected file", inMode(func(m *PagerModeFileList) {
+			m.pager.mode = PagerModeViewing{pager: m.pager}
+			m.pager.switchToFile(m.selected)
+		})},
+		{"file-list-back", helpGroupFileList, "go back to the current file", inMode(func(m *PagerModeFileList) {
+			m.pager.mode = PagerModeViewing{pager: m.pager}
+		})},
+		{"file-list-up", helpGroupFileList, "select the previous file", inMode(func(m *PagerModeFileList) {
+			m.selected--
+		})},
+		{"file-list-down", helpGroupFileList, "select the next file", inMode(func(m *PagerModeFileList) {
+			m.selected++
+		})},
+		{"file-list-first", helpGroupFileList, "select the first file", inMode(func(m *PagerModeFileList) {
+			m.selected = 0
+		})},
+		{"file-list-last", helpGroupFileList, "select the last file", inMode(func(m *PagerModeFileList) {
+			m.selected = len(m.pager.fileListLines()) - 1
+		})},
+		{"file-list-drop", helpGroupFileList, "drop the selected file from the list", inMode(func(m *PagerModeFileList) {
+			m.pager.dropFile(m.selected)
+		})},
+	}
+}
+
+func (m *PagerModeFileList) onKey(key twin.KeyCode) {
+	m.pager.handleModeKeyStroke(keymapModeFileList, keyStroke{keyCode: key})
+}
+
+func (m *PagerModeFileList) onRune(char rune) {
+	m.pager.handleModeKeyStroke(keymapModeFileList, keyStroke{isRune: true, char: char})
+}
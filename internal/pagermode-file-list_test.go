@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/walles/moor/v2/internal/reader"
+	"github.com/walles/moor/v2/twin"
+	"gotest.tools/v3/assert"
+)
+
+func TestExamineListAndDropFiles(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "apa.txt")
+	assert.NilError(t, os.WriteFile(fileName, []byte("apa\nbepa\n"), 0o600))
+
+	reader := reader.NewFromTextForTesting("TestExamineListAndDropFiles", "first")
+	pager := NewPager(reader)
+	pager.screen = twin.NewFakeScreen(60, 10)
+	assert.NilError(t, reader.Wait())
+
+	// ":e" opens a new file
+	pager.mode = PagerModeViewing{pager: pager}
+	pager.mode.onRune(':')
+	pager.mode.onRune('e')
+	for _, char := range fileName {
+		pager.mode.onRune(char)
+	}
+	pager.mode.onKey(twin.KeyEnter)
+	assert.Equal(t, len(pager.readers), 2)
+	assert.Equal(t, pager.currentReader, 1)
+	assert.NilError(t, pager.readers[1].Wait())
+
+	// Opening it again switches to it rather than opening it twice
+	pager.firstFile()
+	pager.examineFile(fileName)
+	assert.Equal(t, len(pager.readers), 2)
+	assert.Equal(t, pager.currentReader, 1)
+
+	lines := pager.fileListLines()
+	assert.Equal(t, len(lines), 2)
+	assert.Assert(t, strings.HasPrefix(lines[1], "* 2  apa.txt"), lines[1])
+	assert.Assert(t, strings.HasSuffix(lines[1], "2 lines, complete"), lines[1])
+
+	// Drop the first file from the list, we should still be showing apa.txt
+	pager.mode.onRune(':')
+	pager.mode.onRune('l')
+	mode, ok := pager.mode.(*PagerModeFileList)
+	assert.Assert(t, ok)
+	mode.onKey(twin.KeyUp)
+	mode.onRune('d')
+	assert.Equal(t, len(pager.readers), 1)
+	assert.Equal(t, pager.currentReader, 0)
+	assert.Equal(t, *pager.readers[0].DisplayName, "apa.txt")
+
+	// The last file can't be dropped
+	mode.onRune('d')
+	assert.Equal(t, len(pager.readers), 1)
+}
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/walles/moor/v2/twin"
+)
+
+// Set how many lines to show before and after each filter match
+type PagerModeFilterContext struct {
+	pager    *Pager
+	inputBox *InputBox
+}
+
+func NewPagerModeFilterContext(p *Pager) *PagerModeFilterContext {
+	m := &PagerModeFilterContext{
+		pager: p,
+		inputBox: &InputBox{
+			accept: INPUTBOX_ACCEPT_ALL,
+		},
+	}
+
+	current := fmt.Sprint(p.filter.contextBefore)
+	if p.filter.contextBefore != p.filter.contextAfter {
+		current = fmt.Sprintf("%d,%d", p.filter.contextBefore, p.filter.contextAfter)
+	}
+	m.inputBox.setText(current)
+
+	return m
+}
+
+func (m *PagerModeFilterContext) drawFooter(_ string, _ string, _ string) {
+	m.inputBox.draw(m.pager.screen, "'ENTER' submits, 'ESC' cancels", "Filter context lines, like 3 or 1,2 for before,after: ")
+}
+
+func (m *PagerModeFilterContext) onKey(key twin.KeyCode) {
+	p := m.pager
+
+	if m.inputBox.handleKey(key) {
+		return
+	}
+
+	switch key {
+	case twin.KeyEnter:
+		before, after, err := ParseFilterContext(m.inputBox.text)
+		if err != nil {
+			p.mode = &PagerModeInfo{Pager: p, Text: "Filter context: " + err.Error()}
+			return
+		}
+
+		p.mode = PagerModeViewing{pager: p}
+		p.changeFilter(func(filter *Filter) {
+			filter.contextBefore = before
+			filter.contextAfter = after
+		})
+
+	case twin.KeyEscape:
+		p.mode = PagerModeViewing{pager: p}
+
+	default:
+		log.Debugf("Unhandled filter context key event %v", key)
+	}
+}
+
+func (m *PagerModeFilterContext) onRune(char rune) {
+	m.inputBox.handleRune(char)
+}
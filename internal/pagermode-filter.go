@@ -2,10 +2,16 @@ package internal
 
 import (
 	log "github.com/sirupsen/logrus"
-	"github.com/walles/moor/v2/internal/search"
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/reader"
 	"github.com/walles/moor/v2/twin"
 )
 
+// Detect the log format from this many lines at the start of the input
+const logFormatSampleLines = 100
+
+// Edits the last pattern of the pager's filter. Any previous patterns stay in
+// effect while editing, see Filter.
 type PagerModeFilter struct {
 	pager    *Pager
 	inputBox *InputBox
@@ -21,31 +27,102 @@ func NewPagerModeFilter(p *Pager) *PagerModeFilter {
 			m.updateFilterPattern(text)
 		},
 	}
+
+	p.filter.push()
+
 	return m
 }
 
 func (m PagerModeFilter) drawFooter(_ string, _ string, _ string) {
-	m.inputBox.draw(m.pager.screen, "Type to filter, 'ENTER' submits, 'ESC' cancels", "Filter: ")
+	prompt := "Filter: "
+	previous := m.pager.filter.clone()
+	previous.pop()
+	if previous.Active() {
+		prompt = "Filter " + previous.String() + " and: "
+	}
+
+	m.inputBox.draw(m.pager.screen, "Type to filter, '!' negates, 'ENTER' submits, 'ESC' cancels", prompt)
 }
 
 func (m *PagerModeFilter) updateFilterPattern(text string) {
-	m.pager.filter.For(text)
-	m.pager.search.For(text)
+	m.pager.filter.setLast(text)
+
+	pattern := m.pager.filter.last()
+	if pattern.negated {
+		// Hits would be filtered out, nothing to highlight
+		m.pager.search.Clear()
+	} else {
+		m.pager.search = pattern.search
+	}
+}
+
+// Drop the pattern being edited, and leave filtering mode
+func (m *PagerModeFilter) cancel() {
+	m.pager.mode = PagerModeViewing{pager: m.pager}
+	m.pager.changeFilter((*Filter).pop)
+	m.pager.search.Clear()
+}
+
+// Change the filter while keeping the top line on screen. If that line gets
+// hidden, scroll to the next visible one.
+func (p *Pager) changeFilter(change func(filter *Filter)) {
+	topLineNumber := p.lineNumberOf(p.lineIndex())
+
+	change(&p.filter)
+
+	if topLineNumber == nil {
+		return
+	}
+	newIndex := p.filteringReader.indexOf(*topLineNumber)
+	if newIndex == nil {
+		// Nothing visible after the old top line, let the scroll position
+		// sort itself out
+		return
+	}
+	p.scrollPosition = NewScrollPositionFromIndex(*newIndex, "changeFilter")
+}
+
+// Hide log lines below a minimum severity, stepping up one level per call
+func (p *Pager) cycleLogLevel() {
+	format := p.filter.logFormat
+	if format == reader.LogFormatNone {
+		sample := []string{}
+		for _, line := range p.filteringReader.BackingReader.GetLines(linemetadata.Index{}, logFormatSampleLines).Lines {
+			sample = append(sample, line.Plain())
+		}
+		format = reader.DetectLogFormat(sample)
+	}
+	if format == reader.LogFormatNone {
+		p.mode = &PagerModeInfo{Pager: p, Text: "Log format not recognized, can't filter by level"}
+		return
+	}
+
+	p.changeFilter(func(filter *Filter) {
+		filter.cycleLogLevel(format)
+	})
 }
 
 func (m *PagerModeFilter) onKey(key twin.KeyCode) {
+	if key == twin.KeyBackspace && m.inputBox.text == "" {
+		m.pager.changeFilter((*Filter).dropPrevious)
+		return
+	}
+
 	if m.inputBox.handleKey(key) {
 		return
 	}
 
 	switch key {
 	case twin.KeyEnter:
+		if m.inputBox.text == "" {
+			// Nothing to add
+			m.cancel()
+			return
+		}
 		m.pager.mode = PagerModeViewing{pager: m.pager}
 
 	case twin.KeyEscape:
-		m.pager.mode = PagerModeViewing{pager: m.pager}
-		m.pager.filter = search.Search{}
-		m.pager.search.Clear()
+		m.cancel()
 
 	case twin.KeyUp, twin.KeyDown, twin.KeyPgUp, twin.KeyPgDown:
 		viewing := PagerModeViewing{pager: m.pager}
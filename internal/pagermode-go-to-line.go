@@ -38,6 +38,9 @@ func (m *PagerModeGotoLine) updateLineNumber(text string) {
 		log.Debugf("Got non-positive goto line number: %d", newLineNumber)
 		return
 	}
+	// Line numbers are indices in the full reader only
+	m.pager.stopShowingFileEnd()
+
 	targetIndex := linemetadata.IndexFromOneBased(newLineNumber)
 	m.pager.scrollPosition = NewScrollPositionFromIndex(
 		targetIndex,
@@ -74,12 +77,5 @@ func (m *PagerModeGotoLine) onRune(char rune) {
 		return
 	}
 
-	if char == 'g' {
-		p.scrollPosition = newScrollPosition("Pager scroll position")
-		p.handleScrolledUp()
-		p.mode = PagerModeViewing{pager: p}
-		return
-	}
-
 	m.inputBox.handleRune(char)
 }
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"strings"
+	"unicode"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/walles/moor/v2/internal/reader"
+	"github.com/walles/moor/v2/twin"
+)
+
+// Pick which fields to show from JSON Lines input, like "ts level msg"
+type PagerModeJSONFields struct {
+	pager    *Pager
+	inputBox *InputBox
+}
+
+func NewPagerModeJSONFields(p *Pager) *PagerModeJSONFields {
+	m := &PagerModeJSONFields{
+		pager: p,
+		inputBox: &InputBox{
+			accept: INPUTBOX_ACCEPT_ALL,
+		},
+	}
+
+	m.inputBox.setText(strings.Join(p.jsonLinesView.Fields, " "))
+
+	return m
+}
+
+// Step to the next way of showing JSON Lines input. Choosing fields to show
+// prompts for which ones.
+func (p *Pager) cycleJSONLinesView() {
+	switch p.jsonLinesView.Mode {
+	case reader.JSONLinesRaw:
+		p.setJSONLinesMode(reader.JSONLinesPretty)
+	case reader.JSONLinesPretty:
+		p.setJSONLinesMode(reader.JSONLinesCompact)
+	case reader.JSONLinesCompact:
+		p.mode = NewPagerModeJSONFields(p)
+	default:
+		p.setJSONLinesMode(reader.JSONLinesRaw)
+	}
+}
+
+func (p *Pager) setJSONLinesMode(mode reader.JSONLinesMode) {
+	p.jsonLinesView.Mode = mode
+	p.mode = &PagerModeInfo{Pager: p, Text: "JSON lines shown " + mode.String()}
+}
+
+// Fields can be separated by commas or whitespace, and can be written jq style
+// with a leading dot: ".ts,.level,.msg"
+func parseJSONFields(s string) []string {
+	fields := []string{}
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		field = strings.TrimPrefix(field, ".")
+		if field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func (m *PagerModeJSONFields) drawFooter(_ string, _ string, _ string) {
+	m.inputBox.draw(m.pager.screen, "'ENTER' submits, 'ESC' cancels", "JSON fields to show, like ts level msg: ")
+}
+
+func (m *PagerModeJSONFields) onKey(key twin.KeyCode) {
+	p := m.pager
+
+	if m.inputBox.handleKey(key) {
+		return
+	}
+
+	switch key {
+	case twin.KeyEnter:
+		fields := parseJSONFields(m.inputBox.text)
+		if len(fields) == 0 {
+			// Nothing to show, back to the beginning of the cycle
+			p.setJSONLinesMode(reader.JSONLinesRaw)
+			return
+		}
+
+		p.jsonLinesView.Fields = fields
+		p.setJSONLinesMode(reader.JSONLinesFields)
+
+	case twin.KeyEscape:
+		p.setJSONLinesMode(reader.JSONLinesRaw)
+
+	default:
+		log.Debugf("Unhandled JSON fields key event %v", key)
+	}
+}
+
+func (m *PagerModeJSONFields) onRune(char rune) {
+	m.inputBox.handleRune(char)
+}
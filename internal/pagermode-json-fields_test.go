@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/walles/moor/v2/internal/reader"
+	"github.com/walles/moor/v2/twin"
+	"gotest.tools/v3/assert"
+)
+
+func TestCycleJSONLinesView(t *testing.T) {
+	jsonReader := reader.NewFromTextForTesting(t.Name(), `{"level":"info","msg":"hello"}`+"\nnot JSON")
+	pager := NewPager(jsonReader)
+	screen := twin.NewFakeScreen(40, 10)
+	pager.screen = screen
+	pager.showLineNumbers = false
+	assert.NilError(t, jsonReader.Wait())
+	pager.mode = PagerModeViewing{pager: pager}
+
+	rows := func() []string {
+		pager.redraw("")
+		result := []string{}
+		for row := range 5 {
+			result = append(result, rowToString(screen.GetRow(row)))
+		}
+		return result
+	}
+
+	assert.DeepEqual(t, rows(), []string{`{"level":"info","msg":"hello"}`, "not JSON", "---", "", ""})
+
+	// Pretty printing turns one line into several
+	pager.mode.onRune('J')
+	pager.mode = PagerModeViewing{pager: pager}
+	assert.DeepEqual(t, rows(), []string{"{", `  "level": "info",`, `  "msg": "hello"`, "}", "not JSON"})
+
+	pager.mode.onRune('J')
+	pager.mode = PagerModeViewing{pager: pager}
+	assert.DeepEqual(t, rows(), []string{"level=info msg=hello", "not JSON", "---", "", ""})
+
+	// Fields mode prompts for the fields
+	pager.mode.onRune('J')
+	fieldsMode, ok := pager.mode.(*PagerModeJSONFields)
+	assert.Assert(t, ok)
+	for _, char := range ".msg,.level" {
+		fieldsMode.onRune(char)
+	}
+	fieldsMode.onKey(twin.KeyEnter)
+	pager.mode = PagerModeViewing{pager: pager}
+	assert.DeepEqual(t, rows(), []string{"hello info", "not JSON", "---", "", ""})
+
+	// And back to the start
+	pager.mode.onRune('J')
+	assert.Equal(t, pager.jsonLinesView.Mode, reader.JSONLinesRaw)
+}
+
+func TestParseJSONFields(t *testing.T) {
+	assert.DeepEqual(t, parseJSONFields(".ts,.level,.msg"), []string{"ts", "level", "msg"})
+	assert.DeepEqual(t, parseJSONFields(" ts  http.status, msg "), []string{"ts", "http.status", "msg"})
+	assert.DeepEqual(t, parseJSONFields(" , "), []string{})
+}
@@ -0,0 +1,109 @@
+// Step through the links on screen, and open the selected one
+
+package internal
+
+import (
+	"fmt"
+
+	"github.com/walles/moor/v2/twin"
+)
+
+type PagerModeLinks struct {
+	pager *Pager
+
+	// Index into the links on screen
+	selected int
+}
+
+// Enters link mode if there are any links on screen
+func (p *Pager) selectLink() {
+	if len(p.linksOnScreen()) == 0 {
+		p.mode = &PagerModeInfo{Pager: p, Text: "No links on screen"}
+		return
+	}
+
+	p.mode = &PagerModeLinks{pager: p}
+}
+
+func (p *Pager) linksOnScreen() []screenLink {
+	return findLinks(p.screenRows())
+}
+
+// Header lines followed by the other lines, as rendered on screen
+func (p *Pager) screenRows() []renderedLine {
+	rendered := p.renderLines()
+	return append(rendered.headerLines, rendered.lines...)
+}
+
+func (m *PagerModeLinks) drawFooter(_ string, _ string, _ string) {
+	p := m.pager
+
+	rows := p.screenRows()
+	links := findLinks(rows)
+	if len(links) == 0 {
+		p.setFooter("No links on screen", "", "", footerHints(p.Keymap,
+			footerHint{"link-back", "to go back"}))
+		return
+	}
+	m.selected = max(0, min(m.selected, len(links)-1))
+	link := links[m.selected]
+
+	// Highlight the selected link on top of what's already on screen
+	column := 0
+	for i, cell := range rows[link.row].cells {
+		if i >= link.end {
+			break
+		}
+		if i >= link.start {
+			cell.Style = cell.Style.WithAttr(twin.AttrReverse)
+		}
+		column += p.screen.SetCell(column, link.row, cell.ToStyledRune())
+	}
+
+	status := fmt.Sprintf("Link %d/%d: %s", m.selected+1, len(links), link.target)
+	p.setFooter("", "", status, footerHints(p.Keymap,
+		footerHint{"link-open", "to open"},
+		footerHint{"link-next", "for next"},
+		footerHint{"link-back", "to go back"}))
+}
+
+// Actions for the link selection key bindings, see Keymap
+func linksActions() []pagerAction {
+	return []pagerAction{
+		{"link-open", helpGroupLinks, "open the selected link", inMode(func(m *PagerModeLinks) {
+			links := m.pager.linksOnScreen()
+			if m.selected >= len(links) {
+				m.pager.mode = PagerModeViewing{pager: m.pager}
+				return
+			}
+			m.pager.openLink(links[m.selected].target)
+		})},
+		{"link-back", helpGroupLinks, "stop selecting links", inMode(func(m *PagerModeLinks) {
+			m.pager.mode = PagerModeViewing{pager: m.pager}
+		})},
+		{"link-next", helpGroupLinks, "select the next link", inMode(func(m *PagerModeLinks) {
+			m.step(1)
+		})},
+		{"link-previous", helpGroupLinks, "select the previous link", inMode(func(m *PagerModeLinks) {
+			m.step(-1)
+		})},
+	}
+}
+
+func (m *PagerModeLinks) onKey(key twin.KeyCode) {
+	m.pager.handleModeKeyStroke(keymapModeLinks, keyStroke{keyCode: key})
+}
+
+func (m *PagerModeLinks) onRune(char rune) {
+	m.pager.handleModeKeyStroke(keymapModeLinks, keyStroke{isRune: true, char: char})
+}
+
+// Move the selection, wrapping around at the ends
+func (m *PagerModeLinks) step(delta int) {
+	count := len(m.pager.linksOnScreen())
+	if count == 0 {
+		return
+	}
+
+	m.selected = ((m.selected+delta)%count + count) % count
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/reader"
+	"github.com/walles/moor/v2/twin"
+	"gotest.tools/v3/assert"
+)
+
+func TestLinksMode(t *testing.T) {
+	reader := reader.NewFromTextForTesting("TestLinksMode", "See https://example.com/\nand links.go:3")
+	pager := NewPager(reader)
+	pager.ShowLineNumbers = false
+	pager.showLineNumbers = false
+	pager.screen = twin.NewFakeScreen(40, 5)
+	assert.NilError(t, reader.Wait())
+
+	pager.mode = PagerModeViewing{pager: pager}
+	pager.mode.onRune('l')
+	mode, ok := pager.mode.(*PagerModeLinks)
+	assert.Assert(t, ok)
+
+	// TAB steps forward, and wraps around
+	mode.onRune('\t')
+	assert.Equal(t, mode.selected, 1)
+	mode.onRune('\t')
+	assert.Equal(t, mode.selected, 0)
+	mode.onKey(twin.KeyUp)
+	assert.Equal(t, mode.selected, 1)
+
+	// The selected link is highlighted
+	pager.redraw("")
+	screen := pager.screen.(*twin.FakeScreen)
+	assert.Equal(t, screen.GetCell(4, 1).Rune, 'l')
+	assert.Equal(t, screen.GetCell(4, 1).Style, screen.GetCell(4, 1).Style.WithAttr(twin.AttrReverse))
+	assert.Equal(t, screen.GetCell(0, 1).Style, screen.GetCell(0, 1).Style.WithoutAttr(twin.AttrReverse))
+
+	// Local files open in a new reader, at the right line
+	mode.onKey(twin.KeyEnter)
+	assert.Assert(t, pager.isViewing())
+	assert.Equal(t, len(pager.readers), 2)
+	assert.Equal(t, pager.currentReader, 1)
+	assert.Equal(t, *pager.TargetLine, linemetadata.IndexFromOneBased(3))
+	assert.NilError(t, pager.readers[1].Wait())
+
+	// Backspace returns to where we were
+	pager.scrollPosition = pager.scrollPosition.NextLine(1)
+	pager.mode.onKey(twin.KeyBackspace)
+	assert.Equal(t, pager.currentReader, 0)
+	assert.Equal(t, len(pager.readers), 2)
+	assert.Equal(t, *pager.lineIndex(), linemetadata.Index{})
+	assert.Assert(t, pager.TargetLine == nil)
+
+	// Nothing more to go back to
+	pager.mode.onKey(twin.KeyBackspace)
+	assert.Equal(t, pager.currentReader, 0)
+	_, ok = pager.mode.(*PagerModeInfo)
+	assert.Assert(t, ok)
+}
+
+func TestLinksModeNoLinks(t *testing.T) {
+	reader := reader.NewFromTextForTesting("TestLinksModeNoLinks", "Nothing to see here")
+	pager := NewPager(reader)
+	pager.screen = twin.NewFakeScreen(40, 5)
+	assert.NilError(t, reader.Wait())
+
+	pager.selectLink()
+	_, ok := pager.mode.(*PagerModeInfo)
+	assert.Assert(t, ok)
+}
+
+func TestLinksModeRemapped(t *testing.T) {
+	reader := reader.NewFromTextForTesting("TestLinksModeRemapped", "See https://example.com/\nand links.go:3")
+	pager := NewPager(reader)
+	pager.screen = twin.NewFakeScreen(40, 5)
+	assert.NilError(t, reader.Wait())
+	assert.NilError(t, pager.Keymap.Bind("x", "link-next"))
+	assert.NilError(t, pager.Keymap.Bind("z", "link-back"))
+
+	pager.selectLink()
+	mode, ok := pager.mode.(*PagerModeLinks)
+	assert.Assert(t, ok)
+
+	mode.onRune('x')
+	assert.Equal(t, mode.selected, 1)
+
+	mode.onRune('z')
+	assert.Assert(t, pager.isViewing())
+}
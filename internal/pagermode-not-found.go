@@ -11,23 +11,24 @@ func (m PagerModeNotFound) drawFooter(_ string, _ string, _ string) {
 }
 
 func (m PagerModeNotFound) onKey(key twin.KeyCode) {
-	m.pager.mode = PagerModeViewing(m)
-	m.pager.mode.onKey(key)
+	m.onKeyStroke(keyStroke{keyCode: key})
 }
 
 func (m PagerModeNotFound) onRune(char rune) {
-	switch char {
+	m.onKeyStroke(keyStroke{isRune: true, char: char})
+}
 
-	// Should match the pagermode-viewing.go next-search-hit bindings
-	case 'n':
-		m.pager.scrollToNextSearchHit()
+func (m PagerModeNotFound) onKeyStroke(stroke keyStroke) {
+	action, _ := m.pager.Keymap.lookup(keymapModeViewing, []keyStroke{stroke})
+	switch action {
 
-	// Should match the pagermode-viewing.go previous-search-hit bindings
-	case 'p', 'N':
-		m.pager.scrollToPreviousSearchHit()
+	// Searching again from here wraps around, so do it without leaving this
+	// mode. See scrollToNextSearchHit().
+	case "search-next", "search-previous":
+		findPagerAction(action).run(m.pager)
 
 	default:
 		m.pager.mode = PagerModeViewing(m)
-		m.pager.mode.onRune(char)
+		m.pager.handleKeyStroke(stroke)
 	}
 }
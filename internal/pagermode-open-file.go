@@ -0,0 +1,147 @@
+// Type a file name to open it, like less' ":e". TAB completes file names.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/walles/moor/v2/twin"
+)
+
+type PagerModeOpenFile struct {
+	pager    *Pager
+	inputBox *InputBox
+}
+
+func NewPagerModeOpenFile(p *Pager) *PagerModeOpenFile {
+	return &PagerModeOpenFile{
+		pager: p,
+		inputBox: &InputBox{
+			accept:   INPUTBOX_ACCEPT_ALL,
+			complete: completeFileName,
+		},
+	}
+}
+
+func (m *PagerModeOpenFile) drawFooter(_ string, _ string, _ string) {
+	m.inputBox.draw(m.pager.screen, "'TAB' completes, 'ENTER' opens, 'ESC' exits", "Open file: ")
+}
+
+func (m *PagerModeOpenFile) onKey(key twin.KeyCode) {
+	p := m.pager
+
+	if m.inputBox.handleKey(key) {
+		return
+	}
+
+	switch key {
+	case twin.KeyEnter:
+		p.mode = PagerModeViewing{pager: p}
+		if m.inputBox.text != "" {
+			p.examineFile(expandTilde(m.inputBox.text))
+		}
+
+	case twin.KeyEscape:
+		p.mode = PagerModeViewing{pager: p}
+
+	default:
+		log.Debugf("Unhandled open file key event %v", key)
+	}
+}
+
+func (m *PagerModeOpenFile) onRune(char rune) {
+	m.inputBox.handleRune(char)
+}
+
+// Switch to the named file, opening it first unless it's already open
+func (p *Pager) examineFile(name string) {
+	if os.Getenv("LESSSECURE") == "1" {
+		p.mode = &PagerModeInfo{
+			Pager: p,
+			Text:  "Not opening files since LESSSECURE=1 is set in the environment",
+		}
+		return
+	}
+
+	if index := p.findFile(name); index >= 0 {
+		p.switchToFile(index)
+		return
+	}
+
+	err := p.openFile(name, nil)
+	if err != nil {
+		p.mode = &PagerModeInfo{Pager: p, Text: "Failed to open " + name + ": " + err.Error()}
+	}
+}
+
+// "~/x" means "x" in the user's home directory
+func expandTilde(name string) string {
+	if !strings.HasPrefix(name, "~/") {
+		return name
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		log.Debug("Failed to get home directory: ", err)
+		return name
+	}
+
+	return filepath.Join(home, name[2:])
+}
+
+// Complete a file name as far as it's unambiguous, like shells do on TAB.
+// Directories get a trailing slash so that you can continue typing.
+func completeFileName(text string) string {
+	directory, prefix := filepath.Split(text)
+
+	searchDirectory := expandTilde(directory)
+	if searchDirectory == "" {
+		searchDirectory = "."
+	}
+
+	entries, err := os.ReadDir(searchDirectory)
+	if err != nil {
+		log.Debug("File name completion failed: ", err)
+		return text
+	}
+
+	candidates := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".") {
+			// Hidden, and the user didn't ask for it
+			continue
+		}
+
+		// Stat rather than entry.IsDir() to follow symlinks
+		stat, err := os.Stat(filepath.Join(searchDirectory, name))
+		if err == nil && stat.IsDir() {
+			name += string(filepath.Separator)
+		}
+		candidates = append(candidates, name)
+	}
+
+	if len(candidates) == 0 {
+		return text
+	}
+
+	common := candidates[0]
+	for _, candidate := range candidates[1:] {
+		for !strings.HasPrefix(candidate, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	for !utf8.ValidString(common) {
+		// Don't split multi byte characters
+		common = common[:len(common)-1]
+	}
+
+	return directory + common
+}
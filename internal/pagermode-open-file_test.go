@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCompleteFileName(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"apa.txt", "apan.txt", "bepa.txt", ".hidden"} {
+		assert.NilError(t, os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o600))
+	}
+	assert.NilError(t, os.Mkdir(filepath.Join(dir, "cepa"), 0o700))
+
+	dir += string(filepath.Separator)
+	assert.Equal(t, completeFileName(dir+"b"), dir+"bepa.txt")
+	assert.Equal(t, completeFileName(dir+"a"), dir+"apa")
+	assert.Equal(t, completeFileName(dir+"c"), dir+"cepa"+string(filepath.Separator))
+	assert.Equal(t, completeFileName(dir+"x"), dir+"x")
+	assert.Equal(t, completeFileName(dir+"."), dir+".hidden")
+
+	// Hidden files don't count unless asked for
+	assert.Equal(t, completeFileName(dir), dir)
+}
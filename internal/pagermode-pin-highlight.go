@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/walles/moor/v2/internal/reader"
+	"github.com/walles/moor/v2/internal/search"
+	"github.com/walles/moor/v2/twin"
+)
+
+// Pinned highlights get these background colors, in order
+var pinnedHighlightColors = []twin.Color{
+	twin.NewColor16(1), // Red
+	twin.NewColor16(3), // Yellow
+	twin.NewColor16(4), // Blue
+	twin.NewColor16(2), // Green
+	twin.NewColor16(5), // Magenta
+	twin.NewColor16(6), // Cyan
+}
+
+// Type a pattern and press ENTER to pin it, or to unpin it if it's already
+// pinned. BACKSPACE in an empty input box unpins the most recent pattern.
+type PagerModePinHighlight struct {
+	pager    *Pager
+	inputBox *InputBox
+}
+
+func NewPagerModePinHighlight(p *Pager) *PagerModePinHighlight {
+	m := &PagerModePinHighlight{
+		pager: p,
+		inputBox: &InputBox{
+			accept: INPUTBOX_ACCEPT_ALL,
+		},
+	}
+
+	// Offer to pin whatever the user last searched for
+	m.inputBox.setText(p.search.String())
+
+	return m
+}
+
+func (m *PagerModePinHighlight) drawFooter(_ string, _ string, _ string) {
+	prompt := "Pin highlight: "
+	if len(m.pager.pinnedHighlights) > 0 {
+		patterns := []string{}
+		for _, highlight := range m.pager.pinnedHighlights {
+			patterns = append(patterns, highlight.Search.String())
+		}
+		prompt = "Pinned [" + strings.Join(patterns, "] [") + "], pin or unpin: "
+	}
+
+	m.inputBox.draw(m.pager.screen, "'ENTER' toggles, 'ESC' exits", prompt)
+}
+
+// Pin the pattern, or unpin it if it is already pinned
+func (p *Pager) togglePinnedHighlight(pattern string) {
+	toggleMe := search.For(pattern)
+	if toggleMe.Inactive() {
+		return
+	}
+
+	for i, highlight := range p.pinnedHighlights {
+		if highlight.Search.Equals(toggleMe) {
+			p.pinnedHighlights = slices.Delete(p.pinnedHighlights, i, i+1)
+			return
+		}
+	}
+
+	p.pinnedHighlights = append(p.pinnedHighlights, reader.PinnedHighlight{
+		Search: toggleMe,
+		Style:  p.nextPinnedHighlightStyle(),
+	})
+}
+
+// Pick the first color not in use, or cycle if all colors are taken
+func (p *Pager) nextPinnedHighlightStyle() twin.Style {
+	color := pinnedHighlightColors[len(p.pinnedHighlights)%len(pinnedHighlightColors)]
+	for _, candidate := range pinnedHighlightColors {
+		taken := slices.ContainsFunc(p.pinnedHighlights, func(highlight reader.PinnedHighlight) bool {
+			return highlight.Style == pinnedHighlightStyle(candidate)
+		})
+		if !taken {
+			color = candidate
+			break
+		}
+	}
+
+	return pinnedHighlightStyle(color)
+}
+
+func pinnedHighlightStyle(background twin.Color) twin.Style {
+	return twin.StyleDefault.WithForeground(twin.NewColor16(0)).WithBackground(background)
+}
+
+func (m *PagerModePinHighlight) onKey(key twin.KeyCode) {
+	p := m.pager
+
+	if key == twin.KeyBackspace && m.inputBox.text == "" && len(p.pinnedHighlights) > 0 {
+		p.pinnedHighlights = p.pinnedHighlights[:len(p.pinnedHighlights)-1]
+		return
+	}
+
+	if m.inputBox.handleKey(key) {
+		return
+	}
+
+	switch key {
+	case twin.KeyEnter:
+		if m.inputBox.text == "" {
+			p.mode = PagerModeViewing{pager: p}
+			return
+		}
+
+		p.togglePinnedHighlight(m.inputBox.text)
+		m.inputBox.setText("")
+
+	case twin.KeyEscape:
+		p.mode = PagerModeViewing{pager: p}
+
+	default:
+		log.Debugf("Unhandled pin highlight key event %v", key)
+	}
+}
+
+func (m *PagerModePinHighlight) onRune(char rune) {
+	m.inputBox.handleRune(char)
+}
@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/walles/moor/v2/internal/reader"
+	"github.com/walles/moor/v2/twin"
+	"gotest.tools/v3/assert"
+)
+
+func TestPinHighlights(t *testing.T) {
+	reader := reader.NewFromTextForTesting("TestPinHighlights", "apa\nbepa\ncepa")
+	pager := NewPager(reader)
+	pager.screen = twin.NewFakeScreen(40, 4)
+	assert.NilError(t, reader.Wait())
+
+	pager.search.For("apa")
+
+	// 'H' should open the pin mode, pre-filled with the current search
+	pager.mode = PagerModeViewing{pager: pager}
+	pager.mode.onRune('H')
+	mode, ok := pager.mode.(*PagerModePinHighlight)
+	assert.Assert(t, ok)
+	assert.Equal(t, mode.inputBox.text, "apa")
+
+	// Pin the search, then another pattern
+	mode.onKey(twin.KeyEnter)
+	for _, char := range "bepa" {
+		mode.onRune(char)
+	}
+	mode.onKey(twin.KeyEnter)
+
+	assert.Equal(t, len(pager.pinnedHighlights), 2)
+	assert.Equal(t, pager.pinnedHighlights[0].Search.String(), "apa")
+	assert.Equal(t, pager.pinnedHighlights[1].Search.String(), "bepa")
+	assert.Assert(t, pager.pinnedHighlights[0].Style != pager.pinnedHighlights[1].Style)
+
+	// Pinning "apa" again unpins it
+	for _, char := range "apa" {
+		mode.onRune(char)
+	}
+	mode.onKey(twin.KeyEnter)
+	assert.Equal(t, len(pager.pinnedHighlights), 1)
+	assert.Equal(t, pager.pinnedHighlights[0].Search.String(), "bepa")
+
+	// A new pin should get the color "apa" had, since that's free now
+	pager.togglePinnedHighlight("cepa")
+	assert.Equal(t, pager.pinnedHighlights[1].Style, pinnedHighlightStyle(pinnedHighlightColors[0]))
+
+	// Backspace on empty input unpins the last one
+	mode.onKey(twin.KeyBackspace)
+	assert.Equal(t, len(pager.pinnedHighlights), 1)
+
+	// Enter on empty input exits
+	mode.onKey(twin.KeyEnter)
+	assert.Assert(t, pager.isViewing())
+}
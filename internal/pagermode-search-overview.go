@@ -0,0 +1,205 @@
+// Lists all search hits in a panel at the bottom of the screen, like Emacs'
+// "occur" buffer. The main view follows the selected hit.
+
+package internal
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/textstyles"
+	"github.com/walles/moor/v2/twin"
+)
+
+type PagerModeSearchOverview struct {
+	pager *Pager
+
+	// Index into the search hits
+	selected int
+
+	// The first hit shown in the panel
+	firstShown int
+
+	// Where to go back to on ESC
+	scrollPositionBefore scrollPosition
+}
+
+func NewPagerModeSearchOverview(p *Pager) *PagerModeSearchOverview {
+	m := &PagerModeSearchOverview{
+		pager:                p,
+		scrollPositionBefore: p.scrollPosition,
+	}
+
+	// Start at the first hit on screen or after it
+	hits := m.hits()
+	m.selected = len(hits) - 1
+	if topIndex := p.lineIndex(); topIndex != nil {
+		i, _ := slices.BinarySearchFunc(hits, *topIndex, func(hit linemetadata.Index, target linemetadata.Index) int {
+			return hit.Index() - target.Index()
+		})
+		m.selected = min(i, len(hits)-1)
+	}
+	m.selected = max(m.selected, 0)
+
+	return m
+}
+
+func (m *PagerModeSearchOverview) hits() []linemetadata.Index {
+	index := m.pager.updateSearchIndex()
+	if index == nil {
+		return nil
+	}
+
+	hits, _ := index.snapshot()
+	return hits
+}
+
+// The panel covers the bottom half of the screen, but leaves room for the
+// status bar
+func (m *PagerModeSearchOverview) panelHeight() int {
+	return max(m.pager.visibleHeight()/2, 1)
+}
+
+func (m *PagerModeSearchOverview) drawFooter(_ string, _ string, _ string) {
+	p := m.pager
+	width, height := p.screen.Size()
+	panelHeight := m.panelHeight()
+	firstRow := height - 1 - panelHeight
+
+	hits := m.hits()
+	m.selected = min(m.selected, max(len(hits)-1, 0))
+
+	// Keep the selected hit in view
+	if m.selected < m.firstShown {
+		m.firstShown = m.selected
+	}
+	if m.selected >= m.firstShown+panelHeight {
+		m.firstShown = m.selected - panelHeight + 1
+	}
+
+	// Make room for the longest line number
+	numberPrefixLength := 0
+	if len(hits) > 0 {
+		lastLine := p.Reader().GetLine(hits[len(hits)-1])
+		if lastLine != nil {
+			numberPrefixLength = len(lastLine.Number.Format()) + 1
+		}
+	}
+
+	for row := range panelHeight {
+		screenRow := firstRow + row
+		for column := range width {
+			p.screen.SetCell(column, screenRow, twin.NewStyledRune(' ', twin.StyleDefault))
+		}
+
+		hitNumber := m.firstShown + row
+		if hitNumber >= len(hits) {
+			continue
+		}
+
+		line := p.Reader().GetLine(hits[hitNumber])
+		if line == nil {
+			continue
+		}
+
+		numberStyle := lineNumbersStyle
+		if hitNumber == m.selected {
+			numberStyle = statusbarStyle
+		}
+		column := 0
+		for _, char := range fmt.Sprintf("%*s ", numberPrefixLength-1, line.Number.Format()) {
+			column += p.screen.SetCell(column, screenRow, twin.NewStyledRune(char, numberStyle))
+		}
+
+		highlighted := line.HighlightedTokens(plainTextStyle, searchHitStyle, p.search, p.pinnedHighlights, 0)
+		for _, cell := range searchHitExcerpt(highlighted.StyledRunes, width-column) {
+			column += p.screen.SetCell(column, screenRow, cell.ToStyledRune())
+		}
+	}
+
+	status := fmt.Sprintf("Search hits for %q: ", p.search.String())
+	if len(hits) > 0 {
+		status += fmt.Sprintf("%d/%d", m.selected+1, len(hits))
+	} else {
+		status += "none"
+	}
+	p.setFooter("", "", status, footerHints(p.Keymap,
+		footerHint{"overview-jump", "to jump"},
+		footerHint{"overview-back", "to go back"}))
+}
+
+// Skip the beginning of the line if needed to show the first search hit.
+// Skipped parts are indicated with an ellipsis.
+func searchHitExcerpt(cells []textstyles.CellWithMetadata, width int) []textstyles.CellWithMetadata {
+	hitStart := slices.IndexFunc(cells, func(cell textstyles.CellWithMetadata) bool {
+		return cell.StartsSearchHit
+	})
+
+	// Keep some context before the hit
+	skip := hitStart - width/4
+	if hitStart < 0 || skip <= 1 {
+		return cells
+	}
+
+	ellipsis := textstyles.CellWithMetadata{Rune: '…', Style: lineNumbersStyle}
+	return append([]textstyles.CellWithMetadata{ellipsis}, cells[skip:]...)
+}
+
+// Make the main view show the selected hit
+func (m *PagerModeSearchOverview) showSelected() {
+	hits := m.hits()
+	if len(hits) == 0 {
+		return
+	}
+
+	m.selected = max(0, min(m.selected, len(hits)-1))
+	m.pager.scrollPosition = NewScrollPositionFromIndex(hits[m.selected], "searchOverview")
+	m.pager.setTargetLine(nil)
+}
+
+// Actions for the search overview key bindings, see Keymap
+func searchOverviewActions() []pagerAction {
+	return []pagerAction{
+		{"overview-jump", helpGroupSearchOverview, "jump to the selected hit", inMode(func(m *PagerModeSearchOverview) {
+			m.showSelected()
+			m.pager.mode = PagerModeViewing{pager: m.pager}
+		})},
+		{"overview-back", helpGroupSearchOverview, "go back to where you were", inMode(func(m *PagerModeSearchOverview) {
+			m.pager.scrollPosition = m.scrollPositionBefore
+			m.pager.mode = PagerModeViewing{pager: m.pager}
+		})},
+		{"overview-up", helpGroupSearchOverview, "select the previous hit", inMode(func(m *PagerModeSearchOverview) {
+			m.selected--
+			m.showSelected()
+		})},
+		{"overview-down", helpGroupSearchOverview, "select the next hit", inMode(func(m *PagerModeSearchOverview) {
+			m.selected++
+			m.showSelected()
+		})},
+		{"overview-page-up", helpGroupSearchOverview, "move the selection up a page", inMode(func(m *PagerModeSearchOverview) {
+			m.selected -= m.panelHeight()
+			m.showSelected()
+		})},
+		{"overview-page-down", helpGroupSearchOverview, "move the selection down a page", inMode(func(m *PagerModeSearchOverview) {
+			m.selected += m.panelHeight()
+			m.showSelected()
+		})},
+		{"overview-first", helpGroupSearchOverview, "select the first hit", inMode(func(m *PagerModeSearchOverview) {
+			m.selected = 0
+			m.showSelected()
+		})},
+		{"overview-last", helpGroupSearchOverview, "select the last hit", inMode(func(m *PagerModeSearchOverview) {
+			m.selected = len(m.hits()) - 1
+			m.showSelected()
+		})},
+	}
+}
+
+func (m *PagerModeSearchOverview) onKey(key twin.KeyCode) {
+	m.pager.handleModeKeyStroke(keymapModeSearchOverview, keyStroke{keyCode: key})
+}
+
+func (m *PagerModeSearchOverview) onRune(char rune) {
+	m.pager.handleModeKeyStroke(keymapModeSearchOverview, keyStroke{isRune: true, char: char})
+}
@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/reader"
+	"github.com/walles/moor/v2/internal/textstyles"
+	"github.com/walles/moor/v2/twin"
+	"gotest.tools/v3/assert"
+)
+
+func TestSearchOverview(t *testing.T) {
+	reader := reader.NewFromTextForTesting(t.Name(), "hit\na\nb\nhit\nc\nd\nhit"+strings.Repeat("\nx", 20))
+	pager := NewPager(reader)
+	screen := twin.NewFakeScreen(60, 10)
+	pager.screen = screen
+	assert.NilError(t, reader.Wait())
+	pager.mode = PagerModeViewing{pager: pager}
+
+	// No search, no overview
+	pager.mode.onRune('o')
+	_, ok := pager.mode.(*PagerModeInfo)
+	assert.Assert(t, ok)
+
+	pager.search.For("hit")
+	pager.scrollPosition = NewScrollPositionFromIndex(linemetadata.IndexFromZeroBased(2), "test")
+	pager.mode = PagerModeViewing{pager: pager}
+	pager.mode.onRune('o')
+	mode, ok := pager.mode.(*PagerModeSearchOverview)
+	assert.Assert(t, ok)
+
+	// The first hit after the top line should be selected
+	assert.Equal(t, mode.selected, 1)
+	pager.redraw("")
+	status := rowToString(screen.GetRow(9))
+	assert.Assert(t, strings.Contains(status, "2/3"), status)
+
+	// Moving the selection scrolls the main view
+	mode.onKey(twin.KeyDown)
+	assert.Equal(t, mode.selected, 2)
+	assert.Equal(t, *pager.lineIndex(), linemetadata.IndexFromZeroBased(6))
+
+	// Can't move past the last hit
+	mode.onKey(twin.KeyDown)
+	assert.Equal(t, mode.selected, 2)
+
+	// ESC goes back to where we were
+	mode.onKey(twin.KeyEscape)
+	assert.Assert(t, pager.isViewing())
+	assert.Equal(t, *pager.lineIndex(), linemetadata.IndexFromZeroBased(2))
+
+	// ENTER jumps to the selected hit
+	pager.mode.onRune('o')
+	mode = pager.mode.(*PagerModeSearchOverview)
+	mode.onKey(twin.KeyHome)
+	mode.onKey(twin.KeyEnter)
+	assert.Assert(t, pager.isViewing())
+	assert.Equal(t, *pager.lineIndex(), linemetadata.IndexFromZeroBased(0))
+}
+
+func TestSearchHitExcerpt(t *testing.T) {
+	cells := []textstyles.CellWithMetadata{}
+	for i, char := range "0123456789hit" {
+		cells = append(cells, textstyles.CellWithMetadata{Rune: char, StartsSearchHit: i == 10})
+	}
+
+	// Hit visible without skipping
+	assert.Equal(t, len(searchHitExcerpt(cells, 40)), len(cells))
+
+	// Skip to a few characters before the hit
+	excerpt := searchHitExcerpt(cells, 8)
+	assert.Equal(t, excerpt[0].Rune, '…')
+	assert.Equal(t, excerpt[1].Rune, '8')
+}
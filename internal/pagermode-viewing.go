@@ -2,10 +2,9 @@ package internal
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 
-	log "github.com/sirupsen/logrus"
-	"github.com/walles/moor/v2/internal/linemetadata"
-	"github.com/walles/moor/v2/internal/search"
 	"github.com/walles/moor/v2/internal/textstyles"
 	"github.com/walles/moor/v2/twin"
 )
@@ -15,27 +14,44 @@ type PagerModeViewing struct {
 }
 
 func (m PagerModeViewing) drawFooter(filenameText string, statusText string, spinner string) {
+	keymap := m.pager.Keymap
+
 	prefix := ""
 	colonHelp := ""
 	m.pager.readerLock.Lock()
 	if len(m.pager.readers) > 1 {
 		prefix = fmt.Sprintf("[%d/%d] ", m.pager.currentReader+1, len(m.pager.readers))
-		colonHelp = "':' to switch, "
+		colonHelp = footerHelp(keymap, "to switch", "switch-file")
+	}
+	if len(m.pager.backStack) > 0 {
+		// Returning is more likely than switching after following a link
+		colonHelp = footerHelp(keymap, "to go back", "go-back")
 	}
 	m.pager.readerLock.Unlock()
 
-	searchHelp := "'/' to search"
+	searchHelp := footerHelp(keymap, "to search", "search-forward")
 	if !m.pager.search.Inactive() {
-		searchHelp = "'n'/'p' to search next/previous"
+		searchHelp = footerHelp(keymap, "to search next/previous", "search-next", "search-previous")
 	}
-	helpText := "Press 'ESC' / 'q' to exit, " + colonHelp + searchHelp + ", '&' to filter, 'h' for help"
+
+	quitHelp := footerHelp(keymap, "to exit", "quit")
+	helpTexts := []string{quitHelp, colonHelp, searchHelp, footerHelp(keymap, "to filter", "filter"), footerHelp(keymap, "for help", "help")}
 
 	if m.pager.isShowingHelp {
-		helpText = "Press 'ESC' / 'q' to exit help, " + searchHelp
+		helpTexts = []string{footerHelp(keymap, "to exit help", "quit"), searchHelp}
 		prefix = ""
 	}
 
+	helpTexts = slices.DeleteFunc(helpTexts, func(text string) bool { return text == "" })
+	helpText := strings.Join(helpTexts, ", ")
+	if len(helpText) > 0 {
+		helpText = "Press " + helpText
+	}
+
 	if m.pager.ShowStatusBar {
+		if hitsStatus := m.pager.searchHitsStatus(); hitsStatus != "" {
+			statusText += "  " + hitsStatus
+		}
 		if len(spinner) > 0 {
 			spinner = "  " + spinner
 		}
@@ -43,198 +59,57 @@ func (m PagerModeViewing) drawFooter(filenameText string, statusText string, spi
 	}
 }
 
-func (m PagerModeViewing) onKey(keyCode twin.KeyCode) {
-	p := m.pager
-
-	switch keyCode {
-	case twin.KeyEscape:
-		p.Quit()
-
-	case twin.KeyUp:
-		// Clipping is done in _Redraw()
-		p.scrollPosition = p.scrollPosition.PreviousLine(1)
-		p.handleScrolledUp()
-
-	case twin.KeyDown, twin.KeyEnter:
-		// Clipping is done in _Redraw()
-		p.scrollPosition = p.scrollPosition.NextLine(1)
-		p.handleScrolledDown()
-
-	case twin.KeyRight:
-		p.moveRight(p.SideScrollAmount)
-
-	case twin.KeyLeft:
-		p.moveRight(-p.SideScrollAmount)
-
-	case twin.KeyAltRight:
-		p.moveRight(1)
-
-	case twin.KeyAltLeft:
-		p.moveRight(-1)
-
-	case twin.KeyHome:
-		p.scrollPosition = newScrollPosition("Pager scroll position")
-		p.handleScrolledUp()
-
-	case twin.KeyEnd:
-		p.scrollToEnd()
-
-	case twin.KeyPgUp:
-		p.scrollPosition = p.scrollPosition.PreviousLine(p.visibleHeight())
-		p.handleScrolledUp()
-
-	case twin.KeyPgDown:
-		p.scrollPosition = p.scrollPosition.NextLine(p.visibleHeight())
-		p.handleScrolledDown()
-
-	default:
-		log.Debugf("Unhandled key event %v", keyCode)
-	}
-}
-
-func (m PagerModeViewing) onRune(char rune) {
-	p := m.pager
-
-	switch char {
-	case 'q':
-		p.Quit()
-
-	case 'v':
-		handleEditingRequest(p)
-
-	case 'h':
-		if p.isShowingHelp {
-			break
-		}
-
-		p.preHelpState = &_PreHelpState{
-			scrollPosition:      p.scrollPosition,
-			leftColumnZeroBased: p.leftColumnZeroBased,
-			targetLine:          p.TargetLine,
+// Renders something like "'n'/'p' to search next/previous". Returns an empty
+// string if any of the actions is unbound.
+func footerHelp(keymap *Keymap, description string, actions ...string) string {
+	keys := []string{}
+	for _, action := range actions {
+		actionKeys := keymap.keysFor(action)
+		if len(actionKeys) == 0 {
+			return ""
 		}
-		p.scrollPosition = newScrollPosition("Pager scroll position")
-		p.leftColumnZeroBased = 0
-		p.setTargetLine(nil)
-		p.isShowingHelp = true
-
-	case '=':
-		p.ShowStatusBar = !p.ShowStatusBar
-
-	// '\x10' = CTRL-p, should scroll up one line.
-	// Ref: https://github.com/walles/moor/issues/107#issuecomment-1328354080
-	case 'k', 'y', '\x10':
-		// Clipping is done in _Redraw()
-		p.scrollPosition = p.scrollPosition.PreviousLine(1)
-		p.handleScrolledUp()
-
-	// '\x0e' = CTRL-n, should scroll down one line.
-	// Ref: https://github.com/walles/moor/issues/107#issuecomment-1328354080
-	case 'j', 'e', '\x0e':
-		// Clipping is done in _Redraw()
-		p.scrollPosition = p.scrollPosition.NextLine(1)
-		p.handleScrolledDown()
-
-	case '<':
-		p.scrollPosition = newScrollPosition("Pager scroll position")
-		p.handleScrolledUp()
-
-	case '>', 'G':
-		p.scrollToEnd()
-
-	case 'f', ' ':
-		p.scrollPosition = p.scrollPosition.NextLine(p.visibleHeight())
-		p.handleScrolledDown()
-
-	case 'b':
-		p.scrollPosition = p.scrollPosition.PreviousLine(p.visibleHeight())
-		p.handleScrolledUp()
-
-	// '\x15' = CTRL-u, should work like just 'u'.
-	// Ref: https://github.com/walles/moor/issues/90
-	case 'u', '\x15':
-		p.scrollPosition = p.scrollPosition.PreviousLine(p.visibleHeight() / 2)
-		p.handleScrolledUp()
-
-	// '\x04' = CTRL-d, should work like just 'd'.
-	// Ref: https://github.com/walles/moor/issues/90
-	case 'd', '\x04':
-		p.scrollPosition = p.scrollPosition.NextLine(p.visibleHeight() / 2)
-		p.handleScrolledDown()
-
-	case '/':
-		p.mode = NewPagerModeSearch(p, SearchDirectionForward, p.scrollPosition)
-		p.search.Clear()
-
-		// Searchers want to scan the whole file, start reading as much as we can
-		reallyHigh := linemetadata.IndexMax()
-		p.setTargetLine(&reallyHigh)
-
-	case '?':
-		p.mode = NewPagerModeSearch(p, SearchDirectionBackward, p.scrollPosition)
-		p.search.Clear()
-
-		// Searchers want to scan the whole file, start reading as much as we can
-		reallyHigh := linemetadata.IndexMax()
-		p.setTargetLine(&reallyHigh)
-
-	case '&':
-		if !p.isShowingHelp {
-			// Filtering the help text is not supported. Feel free to work on
-			// that if you feel that's time well spent.
-			p.mode = NewPagerModeFilter(p)
-			p.search.Clear()
-			p.filter = search.Search{}
-		}
-
-	case 'g':
-		p.mode = NewPagerModeGotoLine(p)
-		p.setTargetLine(nil)
 
-	case ':':
-		if len(p.readers) > 1 {
-			p.mode = &PagerModeColonCommand{pager: p}
-			p.setTargetLine(nil)
+		if len(actions) == 1 {
+			// List all keys for single actions, like "'ESC' / 'q' to exit"
+			keys = append(keys, strings.Join(actionKeys, " / "))
 		} else {
-			p.mode = &PagerModeInfo{Pager: p, Text: "Pass more files on the command line to be able to switch between them."}
+			keys = append(keys, actionKeys[0])
 		}
+	}
 
-	// Should match the pagermode-not-found.go previous-search-hit bindings
-	case 'n':
-		p.scrollToNextSearchHit()
-
-	// Should match the pagermode-not-found.go next-search-hit bindings
-	case 'p', 'N':
-		p.scrollToPreviousSearchHit()
-
-	case 'm':
-		p.mode = PagerModeMark{pager: p}
-		p.setTargetLine(nil)
+	return strings.Join(keys, "/") + " " + description
+}
 
-	case '\'':
-		p.mode = PagerModeJumpToMark{pager: p}
-		p.setTargetLine(nil)
+// One key hint for the footer of a mode with its own key bindings
+type footerHint struct {
+	action      string
+	description string
+}
 
-	case 'w':
-		p.WrapLongLines = !p.WrapLongLines
-		if p.WrapLongLines {
-			p.mode = &PagerModeInfo{Pager: p, Text: "Word wrapping enabled"}
-		} else {
-			p.mode = &PagerModeInfo{Pager: p, Text: "Word wrapping disabled"}
+// Renders something like "Press 'ENTER' to jump, 'ESC' to go back". Each
+// action is shown with the first key bound to it, unbound ones are left out.
+func footerHints(keymap *Keymap, hints ...footerHint) string {
+	texts := []string{}
+	for _, hint := range hints {
+		keys := keymap.keysFor(hint.action)
+		if len(keys) == 0 {
+			continue
 		}
+		texts = append(texts, keys[0]+" "+hint.description)
+	}
 
-	case '\x14': // CTRL-t
-		p.cycleTabSize()
+	if len(texts) == 0 {
+		return ""
+	}
+	return "Press " + strings.Join(texts, ", ")
+}
 
-	case '\x01': // CTRL-a
-		p.leftColumnZeroBased = 0
-		if !p.showLineNumbers {
-			// Line numbers not visible, turn them on if the user wants them.
-			p.showLineNumbers = p.ShowLineNumbers
-		}
+func (m PagerModeViewing) onKey(keyCode twin.KeyCode) {
+	m.pager.handleKeyStroke(keyStroke{keyCode: keyCode})
+}
 
-	default:
-		log.Debugf("Unhandled rune keypress '%s'/0x%08x", string(char), int32(char))
-	}
+func (m PagerModeViewing) onRune(char rune) {
+	m.pager.handleKeyStroke(keyStroke{isRune: true, char: char})
 }
 
 func (p *Pager) cycleTabSize() {
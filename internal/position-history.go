@@ -0,0 +1,415 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/adrg/xdg"
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/reader"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PositionHistory remembers the last scroll position and the marks for files
+// you have paged, so that you can continue where you left off.
+//
+// Files are identified by absolute path plus size and modification time. If
+// any of those change, the file is considered a new one.
+type PositionHistory struct {
+	// Empty means no history file. Set by BootPositionHistory().
+	absFileName string
+
+	// Oldest first
+	entries []positionEntry
+}
+
+type positionEntry struct {
+	key positionKey
+
+	// First line visible on screen
+	lineNumber linemetadata.Number
+
+	marks map[rune]linemetadata.Number
+}
+
+type positionKey struct {
+	absPath string
+	size    int64
+	mtimeNs int64
+}
+
+const maxPositionHistoryEntries = 640 // This should be enough for anyone
+
+// Like BootSearchHistory(): A relative path or just a file name means relative
+// to the user's home directory. Empty means follow the XDG spec for data files.
+func BootPositionHistory(fileName string) *PositionHistory {
+	if fileName == "" {
+		xdgPath, err := xdg.DataFile("moor/positions")
+		if err != nil {
+			log.Infof("Could not resolve XDG data file path for position history: %v", err)
+			return &PositionHistory{}
+		}
+		fileName = xdgPath
+	} else {
+		fileName = resolveHistoryFilePath(fileName)
+	}
+
+	if fileName == "" {
+		return &PositionHistory{}
+	}
+
+	entries, err := loadPositionHistory(fileName)
+	if err != nil {
+		log.Infof("Could not load position history from %s: %v", fileName, err)
+		// IO Error, give up
+		return &PositionHistory{}
+	}
+
+	log.Debugf("Loaded %d position history entries from %s", len(entries), fileName)
+	return &PositionHistory{
+		absFileName: fileName,
+		entries:     entries,
+	}
+}
+
+// Returns an empty list if the file doesn't exist
+func loadPositionHistory(fileName string) ([]positionEntry, error) {
+	entries := []positionEntry{}
+
+	err := iterateFileByLines(fileName, func(line string) {
+		entry, err := parsePositionEntry(line)
+		if err != nil {
+			log.Debugf("Ignoring broken position history line <%s>: %v", line, err)
+			return
+		}
+		entries = append(entries, entry)
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Format, tab separated: "quoted path", size, mtime in nanoseconds, one based
+// line number, marks. Marks are like "97:17,98:42", where 97 and 98 are the
+// mark characters' code points.
+func (e positionEntry) String() string {
+	marks := []string{}
+	for mark, lineNumber := range e.marks {
+		marks = append(marks, fmt.Sprintf("%d:%d", mark, lineNumber.AsOneBased()))
+	}
+	sort.Strings(marks)
+
+	return strings.Join([]string{
+		strconv.Quote(e.key.absPath),
+		strconv.FormatInt(e.key.size, 10),
+		strconv.FormatInt(e.key.mtimeNs, 10),
+		strconv.Itoa(e.lineNumber.AsOneBased()),
+		strings.Join(marks, ","),
+	}, "\t")
+}
+
+func parsePositionEntry(line string) (positionEntry, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) != 5 {
+		return positionEntry{}, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+
+	absPath, err := strconv.Unquote(fields[0])
+	if err != nil {
+		return positionEntry{}, fmt.Errorf("path: %w", err)
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return positionEntry{}, fmt.Errorf("size: %w", err)
+	}
+	mtimeNs, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return positionEntry{}, fmt.Errorf("mtime: %w", err)
+	}
+	lineNumber, err := parseOneBasedLineNumber(fields[3])
+	if err != nil {
+		return positionEntry{}, fmt.Errorf("line number: %w", err)
+	}
+
+	marks := map[rune]linemetadata.Number{}
+	if fields[4] != "" {
+		for _, mark := range strings.Split(fields[4], ",") {
+			codePoint, markLine, found := strings.Cut(mark, ":")
+			if !found {
+				return positionEntry{}, fmt.Errorf("mark without line number: %s", mark)
+			}
+
+			char, err := strconv.ParseInt(codePoint, 10, 32)
+			if err != nil {
+				return positionEntry{}, fmt.Errorf("mark %s: %w", mark, err)
+			}
+
+			markLineNumber, err := parseOneBasedLineNumber(markLine)
+			if err != nil {
+				return positionEntry{}, fmt.Errorf("mark %s line number: %w", mark, err)
+			}
+			marks[rune(char)] = markLineNumber
+		}
+	}
+
+	return positionEntry{
+		key: positionKey{
+			absPath: absPath,
+			size:    size,
+			mtimeNs: mtimeNs,
+		},
+		lineNumber: lineNumber,
+		marks:      marks,
+	}, nil
+}
+
+func parseOneBasedLineNumber(s string) (linemetadata.Number, error) {
+	oneBased, err := strconv.Atoi(s)
+	if err != nil {
+		return linemetadata.Number{}, err
+	}
+	if oneBased < 1 {
+		return linemetadata.Number{}, fmt.Errorf("must be at least 1: %d", oneBased)
+	}
+
+	return linemetadata.NumberFromOneBased(oneBased), nil
+}
+
+// Returns nil if this reader isn't backed by a regular file
+func positionKeyFor(r *reader.ReaderImpl) *positionKey {
+	if r == nil || r.FileName == nil {
+		return nil
+	}
+
+	absPath, err := filepath.Abs(*r.FileName)
+	if err != nil {
+		return nil
+	}
+
+	stat, err := os.Stat(absPath)
+	if err != nil || !stat.Mode().IsRegular() {
+		return nil
+	}
+
+	return &positionKey{
+		absPath: absPath,
+		size:    stat.Size(),
+		mtimeNs: stat.ModTime().UnixNano(),
+	}
+}
+
+// Returns nil if we have no history for this reader
+func (h *PositionHistory) lookup(r *reader.ReaderImpl) *positionEntry {
+	key := positionKeyFor(r)
+	if key == nil {
+		return nil
+	}
+
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		if h.entries[i].key == *key {
+			return &h.entries[i]
+		}
+	}
+
+	return nil
+}
+
+// Remember a position for this reader. Call write() to save the history to
+// disk.
+func (h *PositionHistory) remember(r *reader.ReaderImpl, lineNumber linemetadata.Number, marks map[rune]linemetadata.Number) {
+	key := positionKeyFor(r)
+	if key == nil {
+		return
+	}
+
+	// Drop any older entry for the same path, even if size or mtime differ,
+	// and add the new one at the end
+	entries := []positionEntry{}
+	for _, entry := range h.entries {
+		if entry.key.absPath != key.absPath {
+			entries = append(entries, entry)
+		}
+	}
+	entries = append(entries, positionEntry{key: *key, lineNumber: lineNumber, marks: marks})
+	for len(entries) > maxPositionHistoryEntries {
+		// Remove oldest entry
+		entries = entries[1:]
+	}
+	h.entries = entries
+}
+
+// Save the history to disk, unless we shouldn't
+func (h *PositionHistory) write() {
+	if os.Getenv("LESSSECURE") == "1" {
+		// LESSSECURE=1 means not writing anything to disk
+		return
+	}
+
+	if h.absFileName == "" {
+		// No history file configured
+		return
+	}
+
+	err := h.save()
+	if err != nil {
+		log.Infof("Could not save position history to %s: %v", h.absFileName, err)
+	}
+}
+
+// Write to a temp file and rename it into place
+func (h *PositionHistory) save() error {
+	tmpFilePath := h.absFileName + ".tmp"
+	f, err := os.Create(tmpFilePath)
+	if err != nil {
+		return err
+	}
+
+	// The file names you page are nobody else's business. Best effort, if
+	// this fails it fails.
+	_ = f.Chmod(0o600)
+
+	writer := bufio.NewWriter(f)
+	for _, entry := range h.entries {
+		_, err = writer.WriteString(entry.String() + "\n")
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		removeErr := os.Remove(tmpFilePath)
+		if removeErr != nil {
+			log.Infof("Could not remove temp position history file %s: %v", tmpFilePath, removeErr)
+		}
+		return err
+	}
+
+	return os.Rename(tmpFilePath, h.absFileName)
+}
+
+// Scroll to where the user left off last time in the current file, if we know.
+// Must be called before setTargetLine(), see StartPaging() and openFile().
+func (p *Pager) restorePosition() {
+	if p.PositionHistory == nil {
+		return
+	}
+
+	p.readerLock.Lock()
+	r := p.readers[p.currentReader]
+	p.readerLock.Unlock()
+
+	entry := p.PositionHistory.lookup(r)
+	if entry == nil {
+		return
+	}
+
+	for mark, lineNumber := range entry.marks {
+		p.bookmarks[mark] = NewScrollPositionFromIndex(
+			linemetadata.IndexFromZeroBased(lineNumber.AsZeroBased()),
+			"Restored bookmark")
+	}
+
+	if p.TargetLine != nil {
+		// The user asked for a specific line, that's more important than
+		// where we were last time
+		return
+	}
+
+	if entry.lineNumber.IsZero() {
+		// Already there
+		return
+	}
+
+	targetLine := linemetadata.IndexFromZeroBased(entry.lineNumber.AsZeroBased())
+	p.TargetLine = &targetLine
+	log.Debugf("Restoring last position in %s: %s", entry.key.absPath, entry.lineNumber.Format())
+}
+
+// Save the positions and marks in all files we have looked at to the position
+// history
+func (p *Pager) rememberPosition() {
+	if p.PositionHistory == nil {
+		return
+	}
+
+	p.readerLock.Lock()
+	defer p.readerLock.Unlock()
+
+	for i, r := range p.readers {
+		if i == p.currentReader {
+			// Done last, to make it the most recent entry
+			continue
+		}
+
+		state, found := p.viewStates[r]
+		if !found {
+			// Never looked at, leave any old entry alone
+			continue
+		}
+
+		p.PositionHistory.remember(r, state.lineNumber, state.marks)
+	}
+
+	lineNumber, marks := p.positionLineNumbers()
+	p.PositionHistory.remember(p.readers[p.currentReader], lineNumber, marks)
+
+	p.PositionHistory.write()
+}
+
+// Where we are in the current file and where the marks are, as input line
+// numbers
+func (p *Pager) positionLineNumbers() (linemetadata.Number, map[rune]linemetadata.Number) {
+	position := p.scrollPosition
+	if p.isShowingHelp && p.preHelpState != nil {
+		position = p.preHelpState.scrollPosition
+	}
+
+	lineNumber := linemetadata.Number{}
+	if number := p.lineNumberOf(position.internalDontTouch.lineIndex); number != nil {
+		lineNumber = *number
+	}
+
+	marks := map[rune]linemetadata.Number{}
+	for mark, markPosition := range p.bookmarks {
+		if number := p.lineNumberOf(markPosition.internalDontTouch.lineIndex); number != nil {
+			marks[mark] = *number
+		}
+	}
+
+	return lineNumber, marks
+}
+
+// Map an index in the possibly filtered view to an input line number. Returns
+// nil if there is no such line.
+func (p *Pager) lineNumberOf(index *linemetadata.Index) *linemetadata.Number {
+	if index == nil {
+		return nil
+	}
+
+	line := p.filteringReader.GetLine(*index)
+	if line == nil {
+		return nil
+	}
+
+	return &line.Number
+}
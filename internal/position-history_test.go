@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/reader"
+	"github.com/walles/moor/v2/twin"
+	"gotest.tools/v3/assert"
+)
+
+func TestPositionEntryRoundTrip(t *testing.T) {
+	entry := positionEntry{
+		key: positionKey{
+			absPath: "/tmp/with\ttab.txt",
+			size:    1234,
+			mtimeNs: 5678,
+		},
+		lineNumber: linemetadata.NumberFromOneBased(42),
+		marks: map[rune]linemetadata.Number{
+			'a': linemetadata.NumberFromOneBased(17),
+			',': linemetadata.NumberFromOneBased(1),
+		},
+	}
+
+	parsed, err := parsePositionEntry(entry.String())
+	assert.NilError(t, err)
+	assert.Equal(t, parsed.key, entry.key)
+	assert.Equal(t, parsed.lineNumber, entry.lineNumber)
+	assert.Equal(t, len(parsed.marks), 2)
+	assert.Equal(t, parsed.marks['a'], entry.marks['a'])
+	assert.Equal(t, parsed.marks[','], entry.marks[','])
+}
+
+func TestParsePositionEntryBroken(t *testing.T) {
+	_, err := parsePositionEntry("\"/x\"\t1\t2\t0\t")
+	assert.Error(t, err, "line number: must be at least 1: 0")
+
+	_, err = parsePositionEntry("\"/x\"\t1\t2\t3")
+	assert.Error(t, err, "expected 5 fields, got 4")
+}
+
+func newPagerForPositionTest(t *testing.T, fileName string, history *PositionHistory) *Pager {
+	r, err := reader.NewFromFilename(fileName, formatters.TTY16m, reader.ReaderOptions{Style: styles.Get("native")})
+	assert.NilError(t, err)
+	assert.NilError(t, r.Wait())
+
+	pager := NewPager(r)
+	pager.screen = twin.NewFakeScreen(20, 5)
+	pager.bookmarks = make(map[rune]scrollPosition)
+	pager.PositionHistory = history
+
+	return pager
+}
+
+func TestRememberAndRestorePosition(t *testing.T) {
+	dir := t.TempDir()
+	historyFile := filepath.Join(dir, "positions")
+	textFile := filepath.Join(dir, "text.txt")
+	assert.NilError(t, os.WriteFile(textFile, []byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"), 0o600))
+
+	// Scroll down a bit, set a mark and leave
+	pager := newPagerForPositionTest(t, textFile, BootPositionHistory(historyFile))
+	pager.scrollPosition = NewScrollPositionFromIndex(linemetadata.IndexFromOneBased(4), "test")
+	pager.bookmarks['x'] = NewScrollPositionFromIndex(linemetadata.IndexFromOneBased(7), "test")
+	pager.rememberPosition()
+
+	// Come back with a fresh history to prove it was saved to disk
+	pager = newPagerForPositionTest(t, textFile, BootPositionHistory(historyFile))
+	pager.restorePosition()
+	assert.Equal(t, *pager.TargetLine, linemetadata.IndexFromOneBased(4))
+	assert.Equal(t, *pager.bookmarks['x'].internalDontTouch.lineIndex, linemetadata.IndexFromOneBased(7))
+
+	// An explicit target line should win over the remembered one
+	pager = newPagerForPositionTest(t, textFile, BootPositionHistory(historyFile))
+	explicit := linemetadata.IndexFromOneBased(2)
+	pager.TargetLine = &explicit
+	pager.restorePosition()
+	assert.Equal(t, *pager.TargetLine, explicit)
+
+	// Changing the file should make us forget
+	assert.NilError(t, os.WriteFile(textFile, []byte("1\n2\n3\n"), 0o600))
+	pager = newPagerForPositionTest(t, textFile, BootPositionHistory(historyFile))
+	pager.restorePosition()
+	assert.Assert(t, pager.TargetLine == nil)
+	assert.Equal(t, len(pager.bookmarks), 0)
+}
+
+func TestRememberPositionLessSecure(t *testing.T) {
+	t.Setenv("LESSSECURE", "1")
+
+	dir := t.TempDir()
+	historyFile := filepath.Join(dir, "positions")
+	textFile := filepath.Join(dir, "text.txt")
+	assert.NilError(t, os.WriteFile(textFile, []byte("1\n2\n3\n"), 0o600))
+
+	pager := newPagerForPositionTest(t, textFile, BootPositionHistory(historyFile))
+	pager.rememberPosition()
+
+	_, err := os.Stat(historyFile)
+	assert.Assert(t, os.IsNotExist(err))
+}
+
+func TestRememberPositionsInAllFiles(t *testing.T) {
+	dir := t.TempDir()
+	historyFile := filepath.Join(dir, "positions")
+	firstFile := filepath.Join(dir, "first.txt")
+	secondFile := filepath.Join(dir, "second.txt")
+	assert.NilError(t, os.WriteFile(firstFile, []byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"), 0o600))
+	assert.NilError(t, os.WriteFile(secondFile, []byte("a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"), 0o600))
+
+	// Look at both files, ending up in the first one
+	pager := newPagerForPositionTest(t, firstFile, BootPositionHistory(historyFile))
+	pager.scrollPosition = NewScrollPositionFromIndex(linemetadata.IndexFromOneBased(3), "test")
+	assert.NilError(t, pager.openFile(secondFile, nil))
+	assert.NilError(t, pager.readers[1].Wait())
+	pager.scrollPosition = NewScrollPositionFromIndex(linemetadata.IndexFromOneBased(6), "test")
+	pager.bookmarks['y'] = NewScrollPositionFromIndex(linemetadata.IndexFromOneBased(8), "test")
+	pager.switchToFile(0)
+	pager.rememberPosition()
+
+	// Opening the second file from inside the pager should take us back to
+	// where we were in it
+	pager = newPagerForPositionTest(t, firstFile, BootPositionHistory(historyFile))
+	pager.restorePosition()
+	assert.Equal(t, *pager.TargetLine, linemetadata.IndexFromOneBased(3))
+
+	assert.NilError(t, pager.openFile(secondFile, nil))
+	assert.Equal(t, *pager.TargetLine, linemetadata.IndexFromOneBased(6))
+	assert.Equal(t, *pager.bookmarks['y'].internalDontTouch.lineIndex, linemetadata.IndexFromOneBased(8))
+
+	// Unless we were told where to go
+	pager.switchToFile(0)
+	pager.dropFile(1)
+	explicit := linemetadata.IndexFromOneBased(2)
+	assert.NilError(t, pager.openFile(secondFile, &explicit))
+	assert.Equal(t, *pager.TargetLine, explicit)
+}
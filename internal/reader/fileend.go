@@ -0,0 +1,166 @@
+package reader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	log "github.com/sirupsen/logrus"
+)
+
+// Files smaller than this are read quickly enough that there's no need to show
+// their ends early. A variable so that tests can lower it.
+var fileEndMinSize int64 = 64 * 1024 * 1024
+
+// Read this much from the end of the file
+const fileEndBytes = 1024 * 1024
+
+// For readers of only the end of a file, see NewFromFileEnd()
+type fileEnd struct {
+	// Where in the file our first line starts
+	offset int64
+
+	// How many lines come before our first one. Estimated until counted is
+	// true.
+	linesBefore int
+	counted     bool
+}
+
+// NewFromFileEnd creates a reader for the last part of a large file. This
+// makes it possible to show the end of a file without first reading all of it.
+//
+// Line numbers are estimates until the lines before ours have been counted in
+// the background. Just like for NewFromFilename() readers, the file is tailed
+// after the initial read.
+//
+// Returns an error for files that are small, compressed or not regular files.
+func NewFromFileEnd(filename string, formatter chroma.Formatter, options ReaderOptions) (*ReaderImpl, error) {
+	file, err := openForTailing(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !stat.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", filename)
+	}
+	if stat.Size() < fileEndMinSize {
+		return nil, fmt.Errorf("%s is small enough to read all of it", filename)
+	}
+
+	// Include the byte before, so that we can tell if we start at a line
+	// start
+	readFrom := max(stat.Size()-fileEndBytes-1, 0)
+	buffer := make([]byte, stat.Size()-readFrom)
+	_, err = file.ReadAt(buffer, readFrom)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the end of %s: %w", filename, err)
+	}
+
+	// Start at the first complete line
+	newline := bytes.IndexByte(buffer, '\n')
+	if newline == -1 || newline == len(buffer)-1 {
+		return nil, fmt.Errorf("no line breaks near the end of %s", filename)
+	}
+	offset := readFrom + int64(newline) + 1
+
+	if options.Lexer == nil {
+		options.Lexer = lexers.Match(filename)
+	}
+
+	// The whole point is to get going quickly
+	options.ShouldFormat = false
+
+	returnMe := newReaderImpl(&filename, options)
+	returnMe.bytesCount = offset
+	returnMe.fileEnd = &fileEnd{offset: offset}
+	returnMe.startReading(bytes.NewReader(buffer[newline+1:]), formatter, options)
+
+	go func() {
+		defer func() {
+			PanicHandler("NewFromFileEnd()/countLinesBefore()", recover(), debug.Stack())
+		}()
+
+		returnMe.countLinesBefore(filename, offset)
+	}()
+
+	if options.Lexer == nil {
+		returnMe.HighlightingDone.Store(true)
+	}
+
+	if options.Style != nil {
+		returnMe.SetStyleForHighlighting(*options.Style)
+	}
+
+	log.Debugf("Showing %s from byte %d of %d", filename, offset, stat.Size())
+	return returnMe, nil
+}
+
+// Count the lines before the offset, which is at the start of a line
+func (reader *ReaderImpl) countLinesBefore(filename string, offset int64) {
+	file, err := os.Open(filename)
+	if err != nil {
+		log.Warn("Failed to open ", filename, " for counting lines: ", err)
+		return
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	count, err := countLinesInStream(io.NewSectionReader(file, 0, offset))
+	if err != nil {
+		log.Warn("Failed to count lines in ", filename, ": ", err)
+		return
+	}
+
+	reader.Lock()
+	reader.fileEnd.linesBefore = int(count)
+	reader.fileEnd.counted = true
+	reader.Unlock()
+	log.Debugf("%d lines before the end of %s", count, filename)
+
+	select {
+	case reader.MoreLinesAdded <- true:
+	default:
+	}
+}
+
+// LinesBefore returns how many lines come before the first one of this reader,
+// which is more than zero for NewFromFileEnd() readers. The count is exact if
+// the second return value is true, otherwise it's an estimate.
+func (reader *ReaderImpl) LinesBefore() (int, bool) {
+	reader.RLock()
+	defer reader.RUnlock()
+
+	return reader.linesBeforeLocked()
+}
+
+// Assume lock held
+func (reader *ReaderImpl) linesBeforeLocked() (int, bool) {
+	if reader.fileEnd == nil {
+		return 0, true
+	}
+
+	if reader.fileEnd.counted {
+		return reader.fileEnd.linesBefore, true
+	}
+
+	// Assume the lines before ours are as long as ours
+	ourBytes := reader.bytesCount - reader.fileEnd.offset
+	ourLines := reader.lineCountLocked()
+	if ourBytes <= 0 || ourLines == 0 {
+		return 0, false
+	}
+
+	return int(reader.fileEnd.offset * int64(ourLines) / ourBytes), false
+}
@@ -0,0 +1,68 @@
+package reader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/styles"
+	"gotest.tools/v3/assert"
+
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/util"
+)
+
+func TestFileEnd(t *testing.T) {
+	lines := []string{}
+	for i := range 200_000 {
+		lines = append(lines, fmt.Sprint("Line ", i+1))
+	}
+	fileName := filepath.Join(t.TempDir(), "large.txt")
+	assert.NilError(t, os.WriteFile(fileName, []byte(strings.Join(lines, "\n")+"\n"), 0o600))
+
+	original := fileEndMinSize
+	fileEndMinSize = 0
+	t.Cleanup(func() { fileEndMinSize = original })
+
+	reader, err := NewFromFileEnd(fileName, formatters.TTY16m, ReaderOptions{Style: styles.Get("native")})
+	assert.NilError(t, err)
+	assert.NilError(t, reader.Wait())
+
+	// We should have the end of the file, but not all of it
+	lineCount := reader.GetLineCount()
+	assert.Assert(t, lineCount > 0)
+	assert.Assert(t, lineCount < len(lines))
+	last := reader.GetLine(linemetadata.IndexFromZeroBased(lineCount - 1))
+	assert.Equal(t, last.Plain(), "Line 200000")
+
+	// Wait for the lines before ours to be counted
+	for range 50 {
+		if _, exact := reader.LinesBefore(); exact {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	linesBefore, exact := reader.LinesBefore()
+	assert.Assert(t, exact)
+	assert.Equal(t, linesBefore+lineCount, len(lines))
+
+	first := reader.GetLine(linemetadata.Index{})
+	assert.Equal(t, first.Plain(), lines[linesBefore])
+	assert.Equal(t, first.Number, linemetadata.NumberFromZeroBased(linesBefore))
+	assert.Assert(t, !first.NumberIsEstimate)
+
+	status := reader.GetLines(linemetadata.Index{}, 1).StatusText
+	assert.Assert(t, strings.HasPrefix(status, ": "+util.FormatInt(len(lines))+" lines"), status)
+}
+
+func TestFileEndOfSmallFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "small.txt")
+	assert.NilError(t, os.WriteFile(fileName, []byte("small\n"), 0o600))
+
+	_, err := NewFromFileEnd(fileName, nil, ReaderOptions{})
+	assert.ErrorContains(t, err, "small enough")
+}
@@ -0,0 +1,157 @@
+//go:build linux
+
+package reader
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+	"unsafe"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// Tells us when a file may have changed, using inotify
+type fileWatcher struct {
+	fd int
+
+	// We watch the directory to notice the file being replaced. This is the
+	// name of the file we care about in there.
+	baseName string
+
+	// We also watch the file itself, to notice writes to it after it has been
+	// renamed away. -1 if not watching it.
+	fileWd int
+
+	// Writing to this pipe makes wait() return, see interrupt()
+	interruptRead  int
+	interruptWrite int
+}
+
+func newFileWatcher(fileName string) (*fileWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	const events = unix.IN_MODIFY | unix.IN_ATTRIB | unix.IN_CLOSE_WRITE |
+		unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+	_, err = unix.InotifyAddWatch(fd, filepath.Dir(fileName), events)
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, err
+	}
+
+	interruptFds := make([]int, 2)
+	err = unix.Pipe2(interruptFds, unix.O_CLOEXEC|unix.O_NONBLOCK)
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, err
+	}
+
+	watcher := &fileWatcher{
+		fd:             fd,
+		baseName:       filepath.Base(fileName),
+		fileWd:         -1,
+		interruptRead:  interruptFds[0],
+		interruptWrite: interruptFds[1],
+	}
+	watcher.watchFile(fileName)
+	return watcher, nil
+}
+
+// Watch whatever file is at fileName now, rather than the one we watched
+// before. Call this after reopening a replaced file.
+func (w *fileWatcher) watchFile(fileName string) {
+	if w.fileWd != -1 {
+		// Fails if the old file is gone, never mind
+		_, _ = unix.InotifyRmWatch(w.fd, uint32(w.fileWd))
+		w.fileWd = -1
+	}
+
+	wd, err := unix.InotifyAddWatch(w.fd, fileName, unix.IN_MODIFY)
+	if err != nil {
+		log.Debugf("Not watching %s for writes after renames: %s", fileName, err.Error())
+		return
+	}
+	w.fileWd = wd
+}
+
+// Returns when the file may have changed, or after the timeout
+func (w *fileWatcher) wait(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return
+		}
+
+		fds := []unix.PollFd{
+			{Fd: int32(w.fd), Events: unix.POLLIN},
+			{Fd: int32(w.interruptRead), Events: unix.POLLIN},
+		}
+		ready, err := unix.Poll(fds, int(remaining.Milliseconds())+1)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			log.Debugf("Polling for file changes failed: %s", err.Error())
+			time.Sleep(remaining)
+			return
+		}
+		if ready == 0 {
+			// Timed out
+			return
+		}
+
+		if fds[1].Revents != 0 {
+			// Interrupted. Leave the byte in the pipe, so that any further
+			// waits return right away as well.
+			return
+		}
+
+		if w.readEvents() {
+			return
+		}
+	}
+}
+
+// Returns true if any event was about our file
+func (w *fileWatcher) readEvents() bool {
+	buffer := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	aboutOurFile := false
+	for {
+		length, err := unix.Read(w.fd, buffer)
+		if err != nil || length <= 0 {
+			// Nothing more to read for now
+			return aboutOurFile
+		}
+
+		offset := 0
+		for offset+unix.SizeofInotifyEvent <= length {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			nameEnd := min(nameStart+int(event.Len), length)
+			name := strings.TrimRight(string(buffer[nameStart:nameEnd]), "\x00")
+
+			fromFileWatch := w.fileWd != -1 && int(event.Wd) == w.fileWd
+			if name == w.baseName || fromFileWatch || event.Mask&unix.IN_Q_OVERFLOW != 0 {
+				aboutOurFile = true
+			}
+
+			offset = nameEnd
+		}
+	}
+}
+
+// Make wait() return right away, now and in the future
+func (w *fileWatcher) interrupt() {
+	_, _ = unix.Write(w.interruptWrite, []byte{0})
+}
+
+func (w *fileWatcher) close() {
+	_ = unix.Close(w.fd)
+	_ = unix.Close(w.interruptRead)
+	_ = unix.Close(w.interruptWrite)
+}
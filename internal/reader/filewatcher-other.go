@@ -0,0 +1,28 @@
+//go:build !linux
+
+package reader
+
+import (
+	"errors"
+	"time"
+)
+
+// Change notifications are only implemented for Linux, other platforms poll
+type fileWatcher struct{}
+
+func newFileWatcher(_ string) (*fileWatcher, error) {
+	return nil, errors.New("file change notifications not implemented on this platform")
+}
+
+func (w *fileWatcher) watchFile(_ string) {
+}
+
+func (w *fileWatcher) interrupt() {
+}
+
+func (w *fileWatcher) wait(timeout time.Duration) {
+	time.Sleep(timeout)
+}
+
+func (w *fileWatcher) close() {
+}
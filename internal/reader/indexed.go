@@ -0,0 +1,306 @@
+package reader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Files at least this large are indexed rather than read into memory. A
+// variable so that tests can lower it.
+var indexedFileMinSize int64 = 256 * 1024 * 1024
+
+// Keep this many chunks of lazyHighlightChunkLines lines in memory. Using the
+// same chunk size as the lazy highlighter makes it easy to tell it which
+// highlighted lines have been dropped.
+const indexedCacheChunks = 100
+
+// Lines of a file too large to keep in memory. We keep track of where each line
+// starts, and read the lines from disk when they are asked for.
+//
+// Lines are read a chunk at a time, and the most recently used chunks are
+// cached.
+type indexedLines struct {
+	fileName string
+
+	// Our own handle for reading lines, replaced when the file is replaced
+	file *os.File
+
+	// Where in the file each line starts. Guarded by the reader lock.
+	starts []int64
+
+	// Lines are read with only the reader's read lock held, so the cache needs
+	// a lock of its own
+	cacheLock sync.Mutex
+	cache     map[int]indexedChunk
+
+	// Cached chunk numbers, the most recently used last
+	recentlyUsed []int
+
+	// Called with the cache lock held for chunks dropped from the cache. Set
+	// with the reader write lock held.
+	onEvict func(chunk int)
+}
+
+type indexedChunk struct {
+	lines []*Line
+
+	// Where in the file the chunk ends. If this changes the chunk is stale,
+	// since either its last line got longer or more lines were added.
+	end int64
+}
+
+func newIndexedLines(fileName string) (*indexedLines, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &indexedLines{
+		fileName: fileName,
+		file:     file,
+		cache:    map[int]indexedChunk{},
+	}, nil
+}
+
+// Should we index this stream rather than reading it into memory?
+func shouldIndex(stream io.Reader, options ReaderOptions) bool {
+	if options.ShouldFormat {
+		// Reformatting needs the whole input in memory
+		return false
+	}
+
+	if options.MaxLines > 0 {
+		// Keeping only the last lines in memory is cheap enough, and unlike
+		// indexing it doesn't grow forever when following the file
+		return false
+	}
+
+	file, ok := stream.(*os.File)
+	if !ok {
+		// Compressed, or not a file
+		return false
+	}
+
+	stat, err := file.Stat()
+	if err != nil || !stat.Mode().IsRegular() {
+		return false
+	}
+
+	return stat.Size() >= indexedFileMinSize
+}
+
+// Assume reader lock held. The end is where the last line ends, which is the
+// number of bytes indexed so far.
+func (l *indexedLines) line(index int, end int64) *Line {
+	chunk := l.chunk(index/lazyHighlightChunkLines, end)
+	return chunk[index%lazyHighlightChunkLines]
+}
+
+// Assume reader lock held. Returns lines first to last, last exclusive.
+func (l *indexedLines) lines(first int, last int, end int64) []*Line {
+	lines := make([]*Line, 0, last-first)
+	for index := first; index < last; {
+		chunk := l.chunk(index/lazyHighlightChunkLines, end)
+		fromChunk := chunk[index%lazyHighlightChunkLines:]
+		fromChunk = fromChunk[:min(len(fromChunk), last-index)]
+
+		lines = append(lines, fromChunk...)
+		index += len(fromChunk)
+	}
+
+	return lines
+}
+
+// Assume reader lock held
+func (l *indexedLines) chunk(chunk int, end int64) []*Line {
+	first := chunk * lazyHighlightChunkLines
+	last := min(first+lazyHighlightChunkLines, len(l.starts))
+
+	chunkEnd := end
+	if last < len(l.starts) {
+		chunkEnd = l.starts[last]
+	}
+
+	l.cacheLock.Lock()
+	defer l.cacheLock.Unlock()
+
+	cached, found := l.cache[chunk]
+	if found && cached.end == chunkEnd {
+		l.touch(chunk)
+		return cached.lines
+	}
+
+	lines, err := l.readLines(first, last, chunkEnd)
+	if err != nil {
+		// Show something rather than nothing, and try again next time
+		log.Warn("Failed to read lines from ", l.fileName, ": ", err)
+		return lines
+	}
+
+	if found {
+		// Stale, any highlighting of the old lines is gone now
+		l.evict(chunk)
+	}
+	l.cache[chunk] = indexedChunk{lines: lines, end: chunkEnd}
+	l.touch(chunk)
+
+	for len(l.recentlyUsed) > indexedCacheChunks {
+		l.evict(l.recentlyUsed[0])
+	}
+
+	return lines
+}
+
+// Read lines first to last, last exclusive, from the file. On errors, empty
+// lines are returned along with the error.
+func (l *indexedLines) readLines(first int, last int, end int64) ([]*Line, error) {
+	lines := make([]*Line, last-first)
+	for i := range lines {
+		lines[i] = &Line{}
+	}
+
+	buffer := make([]byte, end-l.starts[first])
+	_, err := l.file.ReadAt(buffer, l.starts[first])
+	if err != nil {
+		return lines, fmt.Errorf("failed to read %d bytes at %d: %w", len(buffer), l.starts[first], err)
+	}
+
+	for i := range lines {
+		lineEnd := end
+		if first+i+1 < last {
+			lineEnd = l.starts[first+i+1]
+		}
+
+		raw := buffer[l.starts[first+i]-l.starts[first] : lineEnd-l.starts[first]]
+		raw = bytes.TrimSuffix(raw, []byte{'\n'})
+		raw = bytes.TrimSuffix(raw, []byte{'\r'})
+		lines[i].raw = raw
+	}
+
+	return lines, nil
+}
+
+// Assume cache lock held. Move the chunk to the most recently used end.
+func (l *indexedLines) touch(chunk int) {
+	l.recentlyUsed = slices.DeleteFunc(l.recentlyUsed, func(c int) bool { return c == chunk })
+	l.recentlyUsed = append(l.recentlyUsed, chunk)
+}
+
+// Assume cache lock held
+func (l *indexedLines) evict(chunk int) {
+	delete(l.cache, chunk)
+	l.recentlyUsed = slices.DeleteFunc(l.recentlyUsed, func(c int) bool { return c == chunk })
+
+	if l.onEvict != nil {
+		l.onEvict(chunk)
+	}
+}
+
+// Assume reader write lock held. Replace a cached line, as long as it's still
+// there. Returns false if it wasn't.
+func (l *indexedLines) replace(index int, original *Line, replacement *Line) bool {
+	l.cacheLock.Lock()
+	defer l.cacheLock.Unlock()
+
+	cached, found := l.cache[index/lazyHighlightChunkLines]
+	if !found || cached.lines[index%lazyHighlightChunkLines] != original {
+		return false
+	}
+
+	cached.lines[index%lazyHighlightChunkLines] = replacement
+	return true
+}
+
+// Assume reader write lock held. Forget all lines and start over from the
+// beginning of the file, which may be a new one with the same name.
+func (l *indexedLines) reset() {
+	l.cacheLock.Lock()
+	for len(l.recentlyUsed) > 0 {
+		l.evict(l.recentlyUsed[0])
+	}
+	l.cacheLock.Unlock()
+
+	l.starts = nil
+
+	file, err := os.Open(l.fileName)
+	if err != nil {
+		// Keep the old one, the file should be back soon
+		log.Debug("Failed to reopen ", l.fileName, ", keeping the old one: ", err)
+		return
+	}
+
+	_ = l.file.Close()
+	l.file = file
+}
+
+// Index lines from a stream positioned at reader.bytesCount in our file, until
+// the stream ends
+func (reader *ReaderImpl) indexLinesFromStream(stream io.Reader) {
+	const byteBufferSize = 1024 * 1024
+
+	byteBuffer := make([]byte, byteBufferSize)
+	awaitingFirstByte := true
+	for {
+		readBytes, err := stream.Read(byteBuffer)
+
+		if awaitingFirstByte && readBytes > 0 {
+			select {
+			case reader.doneWaitingForFirstByte <- true:
+			default:
+			}
+
+			awaitingFirstByte = false
+		}
+
+		reader.Lock()
+		indexed := reader.indexed
+		for position := 0; position < readBytes; {
+			if len(indexed.starts) == 0 || reader.endsWithNewline {
+				indexed.starts = append(indexed.starts, reader.bytesCount+int64(position))
+				reader.endsWithNewline = false
+			}
+
+			newline := bytes.IndexByte(byteBuffer[position:readBytes], '\n')
+			if newline == -1 {
+				// The last line continues in the next read
+				break
+			}
+
+			position += newline + 1
+			reader.endsWithNewline = true
+		}
+		reader.bytesCount += int64(readBytes)
+		reader.Unlock()
+
+		select {
+		case reader.MoreLinesAdded <- true:
+		default:
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			reader.Lock()
+			if reader.Err == nil {
+				reader.Err = fmt.Errorf("error reading from input stream: %w", err)
+			}
+			reader.Unlock()
+			break
+		}
+	}
+
+	if awaitingFirstByte {
+		select {
+		case reader.doneWaitingForFirstByte <- true:
+		default:
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package reader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/styles"
+	"gotest.tools/v3/assert"
+
+	"github.com/walles/moor/v2/internal/linemetadata"
+)
+
+// Index all files, not only large ones
+func indexAllFiles(t *testing.T) {
+	t.Helper()
+
+	original := indexedFileMinSize
+	indexedFileMinSize = 0
+	t.Cleanup(func() { indexedFileMinSize = original })
+}
+
+func allPlainLines(reader *ReaderImpl) []string {
+	plain := []string{}
+	for _, line := range reader.GetLines(linemetadata.Index{}, reader.GetLineCount()).Lines {
+		plain = append(plain, line.Plain())
+	}
+	return plain
+}
+
+func TestIndexedLines(t *testing.T) {
+	indexAllFiles(t)
+
+	expected := []string{}
+	for i := range 1234 {
+		expected = append(expected, fmt.Sprint("Line ", i))
+	}
+	expected = append(expected, "Windows", "No newline")
+
+	fileName := filepath.Join(t.TempDir(), "lines.txt")
+	contents := strings.Join(expected[:len(expected)-2], "\n") + "\nWindows\r\nNo newline"
+	assert.NilError(t, os.WriteFile(fileName, []byte(contents), 0o600))
+
+	reader := startTailing(t, fileName)
+	assert.Assert(t, reader.indexed != nil)
+	assert.Assert(t, reader.lines == nil)
+
+	assert.DeepEqual(t, allPlainLines(reader), expected)
+	assert.Equal(t, reader.GetLine(linemetadata.IndexFromZeroBased(600)).Plain(), "Line 600")
+	assert.Equal(t, reader.GetLine(linemetadata.IndexFromZeroBased(len(expected))), (*NumberedLine)(nil))
+}
+
+func TestIndexedCacheEviction(t *testing.T) {
+	indexAllFiles(t)
+
+	lineCount := (indexedCacheChunks + 10) * lazyHighlightChunkLines
+	fileName := filepath.Join(t.TempDir(), "many.txt")
+	assert.NilError(t, os.WriteFile(fileName, []byte(strings.Repeat("x\n", lineCount)), 0o600))
+
+	reader := startTailing(t, fileName)
+	assert.Equal(t, reader.GetLineCount(), lineCount)
+
+	assert.Equal(t, len(allPlainLines(reader)), lineCount)
+	assert.Equal(t, len(reader.indexed.cache), indexedCacheChunks)
+	_, found := reader.indexed.cache[0]
+	assert.Assert(t, !found, "The first chunk should have been evicted")
+
+	// Evicted lines should be read again
+	assert.Equal(t, reader.GetLine(linemetadata.Index{}).Plain(), "x")
+}
+
+// With --max-lines we read into memory instead, since the index would never
+// stop growing when following the file
+func TestIndexedMaxLines(t *testing.T) {
+	indexAllFiles(t)
+
+	fileName := filepath.Join(t.TempDir(), "lines.txt")
+	assert.NilError(t, os.WriteFile(fileName, []byte(strings.Repeat("x\n", 100)), 0o600))
+
+	reader, err := NewFromFilename(fileName, formatters.TTY16m, ReaderOptions{Style: styles.Get("native"), MaxLines: 10})
+	assert.NilError(t, err)
+	assert.NilError(t, reader.Wait())
+
+	assert.Assert(t, reader.indexed == nil)
+	assert.Equal(t, reader.GetLineCount(), 100)
+	assert.Equal(t, reader.DroppedLineCount(), 90)
+}
+
+func TestIndexedTail(t *testing.T) {
+	indexAllFiles(t)
+
+	fileName := filepath.Join(t.TempDir(), "growing.log")
+	assert.NilError(t, os.WriteFile(fileName, []byte("first\nsec"), 0o600))
+
+	reader := startTailing(t, fileName)
+	assert.DeepEqual(t, allPlainLines(reader), []string{"first", "sec"})
+
+	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY, 0o600)
+	assert.NilError(t, err)
+	_, err = file.WriteString("ond\nthird\n")
+	assert.NilError(t, err)
+	assert.NilError(t, file.Close())
+
+	assert.DeepEqual(t, waitForLines(t, reader, 3), []string{"first", "second", "third"})
+
+	// Truncated files are indexed again from the start
+	assert.NilError(t, os.WriteFile(fileName, []byte("new\n"), 0o600))
+	for range 30 {
+		if reader.GetLineCount() == 1 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.DeepEqual(t, allPlainLines(reader), []string{"new"})
+}
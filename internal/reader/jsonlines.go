@@ -0,0 +1,346 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/search"
+	"github.com/walles/moor/v2/internal/textstyles"
+	"github.com/walles/moor/v2/twin"
+)
+
+// JSONLinesMode says how to show lines that are JSON objects, as in JSON
+// Lines input. Other lines are always shown as they are.
+type JSONLinesMode int
+
+const (
+	JSONLinesRaw JSONLinesMode = iota
+	JSONLinesPretty
+	JSONLinesCompact
+	JSONLinesFields
+)
+
+type JSONLinesView struct {
+	Mode JSONLinesMode
+
+	// Fields to show in JSONLinesFields mode. Nested fields are separated by
+	// dots, like "http.status".
+	Fields []string
+}
+
+// JSONStyles are used for coloring reformatted JSON Lines
+type JSONStyles struct {
+	Key         twin.Style
+	String      twin.Style
+	Number      twin.Style
+	Constant    twin.Style // true, false and null
+	Punctuation twin.Style
+}
+
+func (m JSONLinesMode) String() string {
+	switch m {
+	case JSONLinesPretty:
+		return "pretty printed"
+	case JSONLinesCompact:
+		return "as key=value"
+	case JSONLinesFields:
+		return "as selected fields"
+	}
+	return "as is"
+}
+
+// A parsed JSON value, with object keys kept in input order
+type jsonValue struct {
+	// '{', '[' or '"' for objects, arrays and strings, 0 for other values
+	kind byte
+
+	members []jsonMember
+	items   []jsonValue
+
+	// Decoded for strings, JSON for numbers, booleans and null
+	text string
+}
+
+type jsonMember struct {
+	key   string
+	value jsonValue
+}
+
+// Returns nil if the line isn't exactly one JSON object
+func parseJSONObject(line string) *jsonValue {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		// Quick rejection of most non-JSON lines
+		return nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(trimmed))
+	decoder.UseNumber()
+	value, err := parseJSONValue(decoder)
+	if err != nil {
+		return nil
+	}
+
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		// Trailing garbage
+		return nil
+	}
+
+	return &value
+}
+
+func parseJSONValue(decoder *json.Decoder) (jsonValue, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return jsonValue{}, err
+	}
+
+	switch token := token.(type) {
+	case json.Delim:
+		value := jsonValue{kind: byte(token)}
+		for decoder.More() {
+			if token == '{' {
+				key, err := decoder.Token()
+				if err != nil {
+					return jsonValue{}, err
+				}
+				member, err := parseJSONValue(decoder)
+				if err != nil {
+					return jsonValue{}, err
+				}
+				value.members = append(value.members, jsonMember{key: key.(string), value: member})
+			} else {
+				item, err := parseJSONValue(decoder)
+				if err != nil {
+					return jsonValue{}, err
+				}
+				value.items = append(value.items, item)
+			}
+		}
+
+		// Consume the closing delimiter
+		if _, err := decoder.Token(); err != nil {
+			return jsonValue{}, err
+		}
+		return value, nil
+
+	case string:
+		return jsonValue{kind: '"', text: token}, nil
+	case json.Number:
+		return jsonValue{text: token.String()}, nil
+	case bool:
+		return jsonValue{text: fmt.Sprint(token)}, nil
+	case nil:
+		return jsonValue{text: "null"}, nil
+	}
+
+	return jsonValue{}, fmt.Errorf("unexpected JSON token: %v", token)
+}
+
+// Look up a dot separated field path. Returns nil if not found.
+func (v *jsonValue) lookup(path string) *jsonValue {
+	current := v
+	for _, key := range strings.Split(path, ".") {
+		var next *jsonValue
+		for i := range current.members {
+			if current.members[i].key == key {
+				next = &current.members[i].value
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		current = next
+	}
+	return current
+}
+
+// Quote a string the way JSON does, but without HTML escaping
+func jsonQuote(s string) string {
+	buffer := bytes.Buffer{}
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+type jsonRenderer struct {
+	styles JSONStyles
+	cells  []textstyles.CellWithMetadata
+}
+
+func (r *jsonRenderer) add(s string, style twin.Style) {
+	for _, char := range s {
+		r.cells = append(r.cells, textstyles.CellWithMetadata{Rune: char, Style: style})
+	}
+}
+
+func (r *jsonRenderer) scalar(v jsonValue) {
+	switch {
+	case v.kind == '"':
+		r.add(jsonQuote(v.text), r.styles.String)
+	case v.text == "true" || v.text == "false" || v.text == "null":
+		r.add(v.text, r.styles.Constant)
+	default:
+		r.add(v.text, r.styles.Number)
+	}
+}
+
+// Like json.MarshalIndent() with two spaces, with newlines between the lines
+func (r *jsonRenderer) pretty(v jsonValue, indent string) {
+	switch v.kind {
+	case '{':
+		if len(v.members) == 0 {
+			r.add("{}", r.styles.Punctuation)
+			return
+		}
+		r.add("{", r.styles.Punctuation)
+		for i, member := range v.members {
+			r.add("\n"+indent+"  ", r.styles.Punctuation)
+			r.add(jsonQuote(member.key), r.styles.Key)
+			r.add(": ", r.styles.Punctuation)
+			r.pretty(member.value, indent+"  ")
+			if i < len(v.members)-1 {
+				r.add(",", r.styles.Punctuation)
+			}
+		}
+		r.add("\n"+indent+"}", r.styles.Punctuation)
+
+	case '[':
+		if len(v.items) == 0 {
+			r.add("[]", r.styles.Punctuation)
+			return
+		}
+		r.add("[", r.styles.Punctuation)
+		for i, item := range v.items {
+			r.add("\n"+indent+"  ", r.styles.Punctuation)
+			r.pretty(item, indent+"  ")
+			if i < len(v.items)-1 {
+				r.add(",", r.styles.Punctuation)
+			}
+		}
+		r.add("\n"+indent+"]", r.styles.Punctuation)
+
+	default:
+		r.scalar(v)
+	}
+}
+
+// Like json.Marshal()
+func (r *jsonRenderer) compactJSON(v jsonValue) {
+	switch v.kind {
+	case '{':
+		r.add("{", r.styles.Punctuation)
+		for i, member := range v.members {
+			if i > 0 {
+				r.add(",", r.styles.Punctuation)
+			}
+			r.add(jsonQuote(member.key), r.styles.Key)
+			r.add(":", r.styles.Punctuation)
+			r.compactJSON(member.value)
+		}
+		r.add("}", r.styles.Punctuation)
+
+	case '[':
+		r.add("[", r.styles.Punctuation)
+		for i, item := range v.items {
+			if i > 0 {
+				r.add(",", r.styles.Punctuation)
+			}
+			r.compactJSON(item)
+		}
+		r.add("]", r.styles.Punctuation)
+
+	default:
+		r.scalar(v)
+	}
+}
+
+// Like logfmt: key=value key2="value with spaces"
+func (r *jsonRenderer) keyValues(v jsonValue) {
+	for i, member := range v.members {
+		if i > 0 {
+			r.add(" ", r.styles.Punctuation)
+		}
+		r.add(member.key, r.styles.Key)
+		r.add("=", r.styles.Punctuation)
+
+		value := member.value
+		if value.kind == '"' && value.text != "" && !strings.ContainsAny(value.text, " \t\n\"=") {
+			r.add(value.text, r.styles.String)
+		} else {
+			r.compactJSON(value)
+		}
+	}
+}
+
+// Like jq -r, but with all fields on one line
+func (r *jsonRenderer) fields(v jsonValue, fields []string) {
+	for i, field := range fields {
+		if i > 0 {
+			r.add(" ", r.styles.Punctuation)
+		}
+
+		value := v.lookup(field)
+		switch {
+		case value == nil:
+			r.add("null", r.styles.Constant)
+		case value.kind == '"':
+			r.add(value.text, r.styles.String)
+		default:
+			r.compactJSON(*value)
+		}
+	}
+}
+
+// JSONHighlightedTokens is like HighlightedTokens(), but renders the line
+// according to the view. Pretty printed lines contain newlines.
+//
+// Returns false if the line isn't a JSON object or the view is JSONLinesRaw,
+// use HighlightedTokens() instead in that case.
+func (line *Line) JSONHighlightedTokens(
+	view JSONLinesView,
+	styles JSONStyles,
+	searchHitStyle twin.Style,
+	search search.Search,
+	pinned []PinnedHighlight,
+	lineIndex linemetadata.Index,
+) (textstyles.StyledRunesWithTrailer, bool) {
+	if view.Mode == JSONLinesRaw {
+		return textstyles.StyledRunesWithTrailer{}, false
+	}
+
+	value := parseJSONObject(line.Plain(lineIndex))
+	if value == nil {
+		return textstyles.StyledRunesWithTrailer{}, false
+	}
+
+	renderer := jsonRenderer{styles: styles}
+	switch view.Mode {
+	case JSONLinesPretty:
+		renderer.pretty(*value, "")
+	case JSONLinesCompact:
+		renderer.keyValues(*value)
+	case JSONLinesFields:
+		renderer.fields(*value, view.Fields)
+	}
+
+	plain := strings.Builder{}
+	for _, cell := range renderer.cells {
+		plain.WriteRune(cell.Rune)
+	}
+
+	return highlightMatches(
+		textstyles.StyledRunesWithTrailer{StyledRunes: renderer.cells},
+		plain.String(),
+		searchHitStyle,
+		search,
+		pinned,
+	), true
+}
@@ -0,0 +1,86 @@
+package reader
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/search"
+	"github.com/walles/moor/v2/internal/textstyles"
+	"github.com/walles/moor/v2/twin"
+	"gotest.tools/v3/assert"
+)
+
+const jsonLine = `{"ts":"12:34:56","level":"info","msg":"Hello world","http":{"status":200},"tags":["a","b"],"ok":true}`
+
+func jsonRendering(t *testing.T, line string, view JSONLinesView) string {
+	t.Helper()
+
+	rendered, ok := (&Line{raw: []byte(line)}).JSONHighlightedTokens(view, JSONStyles{}, twin.StyleDefault, search.Search{}, nil, linemetadata.Index{})
+	assert.Assert(t, ok)
+
+	text := strings.Builder{}
+	for _, cell := range rendered.StyledRunes {
+		text.WriteRune(cell.Rune)
+	}
+	return text.String()
+}
+
+func TestJSONLinesPretty(t *testing.T) {
+	assert.Equal(t, jsonRendering(t, jsonLine, JSONLinesView{Mode: JSONLinesPretty}), strings.Join([]string{
+		`{`,
+		`  "ts": "12:34:56",`,
+		`  "level": "info",`,
+		`  "msg": "Hello world",`,
+		`  "http": {`,
+		`    "status": 200`,
+		`  },`,
+		`  "tags": [`,
+		`    "a",`,
+		`    "b"`,
+		`  ],`,
+		`  "ok": true`,
+		`}`,
+	}, "\n"))
+}
+
+func TestJSONLinesCompact(t *testing.T) {
+	assert.Equal(t,
+		jsonRendering(t, jsonLine, JSONLinesView{Mode: JSONLinesCompact}),
+		`ts=12:34:56 level=info msg="Hello world" http={"status":200} tags=["a","b"] ok=true`)
+}
+
+func TestJSONLinesFields(t *testing.T) {
+	assert.Equal(t,
+		jsonRendering(t, jsonLine, JSONLinesView{Mode: JSONLinesFields, Fields: []string{"ts", "http.status", "msg", "missing"}}),
+		`12:34:56 200 Hello world null`)
+}
+
+func TestJSONLinesNotJSON(t *testing.T) {
+	for _, line := range []string{"", "hello", `{"a": 1`, `{"a": 1} trailing`, `[1, 2]`} {
+		_, ok := (&Line{raw: []byte(line)}).JSONHighlightedTokens(JSONLinesView{Mode: JSONLinesPretty}, JSONStyles{}, twin.StyleDefault, search.Search{}, nil, linemetadata.Index{})
+		assert.Assert(t, !ok, line)
+	}
+
+	// Raw mode is for the caller to handle
+	_, ok := (&Line{raw: []byte(jsonLine)}).JSONHighlightedTokens(JSONLinesView{}, JSONStyles{}, twin.StyleDefault, search.Search{}, nil, linemetadata.Index{})
+	assert.Assert(t, !ok)
+}
+
+func TestJSONLinesSearchHighlight(t *testing.T) {
+	hitStyle := twin.StyleDefault.WithAttr(twin.AttrReverse)
+	rendered, ok := (&Line{raw: []byte(jsonLine)}).JSONHighlightedTokens(
+		JSONLinesView{Mode: JSONLinesCompact}, JSONStyles{}, hitStyle, search.For("level=info"), nil, linemetadata.Index{})
+	assert.Assert(t, ok)
+	assert.Assert(t, rendered.ContainsSearchHit)
+
+	hits := ""
+	for _, cell := range rendered.StyledRunes {
+		if cell.IsSearchHit {
+			assert.Equal(t, cell.Style, hitStyle)
+			hits += string(cell.Rune)
+		}
+	}
+	assert.Equal(t, hits, "level=info")
+	assert.Assert(t, textstyles.CellWithMetadataSlice(rendered.StyledRunes).ContainsSearchHit())
+}
@@ -0,0 +1,365 @@
+package reader
+
+import (
+	"bytes"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	log "github.com/sirupsen/logrus"
+	"github.com/walles/moor/v2/internal/linemetadata"
+)
+
+// Input too large for highlighting all at once is highlighted in chunks of
+// this many lines, as the chunks come into view
+const lazyHighlightChunkLines = 500
+
+// When scrolling fast, we only care about the most recently shown chunks. The
+// user has already scrolled past the others.
+const lazyHighlightMaxPending = 4
+
+// To find out which JSON brackets are open at the start of a chunk, we read all
+// chunks before it. After jumping far into a large file, that would mean
+// reading most of it. Beyond this many chunks, we highlight without knowing.
+const jsonNestingMaxChunks = 20
+
+// Look at this much of the input when deciding how to highlight it
+const lazyHighlightSampleBytes = 64 * 1024
+
+// Highlights parts of the input as they are shown, so that highlighting works
+// for inputs of any size.
+//
+// Lexers start out in their initial state at the beginning of each chunk. For
+// most languages this is good enough. For JSON, which the lexer can't make
+// sense of mid-document, we tell the lexer which brackets are open at the
+// start of the chunk.
+type lazyHighlighter struct {
+	reader    *ReaderImpl
+	style     chroma.Style
+	formatter chroma.Formatter
+
+	// If this is nil, we highlight log lines in logFormat instead
+	lexer     chroma.Lexer
+	logFormat LogFormat
+
+	lock sync.Mutex
+
+	// How many lines of each chunk have been highlighted. Lines added at the
+	// end while tailing makes the last chunk need highlighting again.
+	highlighted map[int]int
+
+	// Chunks waiting to be highlighted, the most recently requested last
+	pending []int
+
+	wakeup chan bool
+
+	// JSON brackets open at the start of each chunk, computed on demand
+	jsonNesting map[int][]byte
+}
+
+// If we know how to highlight this input, start highlighting it lazily. Lines
+// are highlighted when requested through HighlightLazily().
+func startLazyHighlighting(reader *ReaderImpl, formatter chroma.Formatter, options ReaderOptions) {
+	if options.Style == nil {
+		log.Debug("No style set, not highlighting lazily")
+		return
+	}
+
+	if formatter == nil {
+		log.Debug("No formatter set, not highlighting lazily")
+		return
+	}
+
+	highlighter := &lazyHighlighter{
+		reader:      reader,
+		style:       *options.Style,
+		formatter:   formatter,
+		lexer:       options.Lexer,
+		highlighted: map[int]int{},
+		wakeup:      make(chan bool, 1),
+		jsonNesting: map[int][]byte{0: {}},
+	}
+
+	sample := []string{}
+	sampleBytes := 0
+	hasFormatting := false
+	reader.RLock()
+	for i := reader.droppedLines; i < reader.lineCountLocked(); i++ {
+		if sampleBytes > lazyHighlightSampleBytes {
+			break
+		}
+		line := reader.lineLocked(i)
+		sample = append(sample, line.Plain(linemetadata.IndexFromZeroBased(i)))
+		sampleBytes += len(line.raw)
+		hasFormatting = hasFormatting || bytes.IndexByte(line.raw, '\x1b') >= 0
+	}
+	reader.RUnlock()
+
+	if hasFormatting {
+		// We highlight the plain text, and would lose the formatting
+		log.Debug("Input already has formatting, not highlighting lazily")
+		return
+	}
+
+	sampleText := strings.Join(sample, "\n")
+
+	trimmed := []byte(strings.TrimSpace(sampleText))
+	if highlighter.lexer == nil && isJSONDocumentPrefix(trimmed) {
+		log.Info("Input looks like JSON, highlighting lazily as JSON")
+		highlighter.lexer = lexers.Get("json")
+	} else if highlighter.lexer == nil && strings.HasPrefix(string(trimmed), "<") && isXMLPrefix(trimmed) {
+		log.Info("Input looks like XML, highlighting lazily as XML")
+		highlighter.lexer = lexers.Get("xml")
+	} else if highlighter.lexer == nil {
+		highlighter.logFormat = DetectLogFormat(sample)
+		if highlighter.logFormat == LogFormatNone {
+			log.Debug("No lexer set, not highlighting lazily")
+			return
+		}
+		log.Info("Input looks like a log in ", highlighter.logFormat, " format, highlighting lazily")
+	}
+
+	if highlighter.lexer != nil && highlighter.lexer.Config().Name == "plaintext" {
+		// Nothing to highlight
+		return
+	}
+
+	reader.Lock()
+	reader.lazyHighlighter = highlighter
+	if reader.indexed != nil {
+		reader.indexed.onEvict = highlighter.forget
+	}
+	reader.Unlock()
+
+	go func() {
+		defer func() {
+			PanicHandler("startLazyHighlighting()", recover(), debug.Stack())
+		}()
+
+		highlighter.run()
+	}()
+}
+
+// HighlightLazily highlights the given lines in the background, if the input
+// was too large to be highlighted up front. Do this for the lines on screen,
+// but not for bulk reads like when searching. Those would just push the lines
+// the user is looking at out of the queue.
+//
+// MoreLinesAdded is signalled when highlighted lines are available.
+func (reader *ReaderImpl) HighlightLazily(first linemetadata.Number, last linemetadata.Number) {
+	reader.RLock()
+	highlighter := reader.lazyHighlighter
+	reader.RUnlock()
+
+	if highlighter == nil {
+		return
+	}
+
+	for chunk := first.AsZeroBased() / lazyHighlightChunkLines; chunk <= last.AsZeroBased()/lazyHighlightChunkLines; chunk++ {
+		highlighter.request(chunk)
+	}
+}
+
+func (h *lazyHighlighter) request(chunk int) {
+	h.lock.Lock()
+	h.pending = slices.DeleteFunc(h.pending, func(c int) bool { return c == chunk })
+	h.pending = append(h.pending, chunk)
+	if len(h.pending) > lazyHighlightMaxPending {
+		h.pending = h.pending[len(h.pending)-lazyHighlightMaxPending:]
+	}
+	h.lock.Unlock()
+
+	select {
+	case h.wakeup <- true:
+	default:
+	}
+}
+
+// Highlight the chunk again when it's requested next time. Used when the
+// highlighted lines have been dropped.
+func (h *lazyHighlighter) forget(chunk int) {
+	h.lock.Lock()
+	delete(h.highlighted, chunk)
+	h.lock.Unlock()
+}
+
+// Returns false if there's nothing to do
+func (h *lazyHighlighter) next() (int, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if len(h.pending) == 0 {
+		return 0, false
+	}
+
+	chunk := h.pending[len(h.pending)-1]
+	h.pending = h.pending[:len(h.pending)-1]
+	return chunk, true
+}
+
+func (h *lazyHighlighter) run() {
+	for range h.wakeup {
+		for {
+			chunk, ok := h.next()
+			if !ok {
+				break
+			}
+			h.highlightChunk(chunk)
+		}
+	}
+}
+
+func (h *lazyHighlighter) highlightChunk(chunk int) {
+	first := chunk * lazyHighlightChunkLines
+
+	h.reader.RLock()
+	last := min(first+lazyHighlightChunkLines, h.reader.lineCountLocked())
+
+	// Dropped lines can't be highlighted
+	first = max(first, h.reader.droppedLines)
+	if first >= last {
+		h.reader.RUnlock()
+		return
+	}
+	originals := slices.Clone(h.reader.linesLocked(first, last))
+	h.reader.RUnlock()
+
+	h.lock.Lock()
+	alreadyDone := h.highlighted[chunk] >= len(originals)
+	h.lock.Unlock()
+	if alreadyDone {
+		return
+	}
+
+	plain := make([]string, len(originals))
+	for i, line := range originals {
+		plain[i] = line.Plain(linemetadata.IndexFromZeroBased(first + i))
+	}
+
+	highlighted, err := h.highlightLines(chunk, plain)
+	if err != nil {
+		log.Warn("Lazy highlighting failed: ", err)
+	} else if highlighted != nil && len(highlighted) != len(plain) {
+		log.Debugf("Lazy highlighting chunk %d gave %d lines rather than %d, never mind", chunk, len(highlighted), len(plain))
+		highlighted = nil
+	}
+
+	h.lock.Lock()
+	h.highlighted[chunk] = len(originals)
+	h.lock.Unlock()
+
+	if highlighted == nil {
+		return
+	}
+
+	h.reader.Lock()
+	for i, original := range originals {
+		// If replaced while we were highlighting, leave it alone
+		_ = h.reader.replaceLineLocked(first+i, original, &Line{raw: []byte(highlighted[i])})
+	}
+	h.reader.Unlock()
+
+	select {
+	case h.reader.MoreLinesAdded <- true:
+	default:
+	}
+}
+
+// Returns one highlighted line per input line, or nil if no highlighting would
+// be done
+func (h *lazyHighlighter) highlightLines(chunk int, lines []string) ([]string, error) {
+	if h.lexer == nil {
+		highlighted, err := HighlightLog(lines, h.logFormat, h.style, h.formatter)
+		if highlighted == nil || err != nil {
+			return nil, err
+		}
+		return strings.Split(strings.TrimSuffix(*highlighted, "\n"), "\n"), nil
+	}
+
+	prefix := ""
+	if h.lexer.Config().Name == "JSON" {
+		prefix = jsonContextPrefix(h.jsonNestingAt(chunk))
+	}
+
+	highlighted, err := Highlight(prefix+"\n"+strings.Join(lines, "\n")+"\n", h.style, h.formatter, h.lexer)
+	if highlighted == nil || err != nil {
+		return nil, err
+	}
+
+	// Drop the prefix line
+	highlightedLines := strings.Split(strings.TrimSuffix(*highlighted, "\n"), "\n")
+	return highlightedLines[1:], nil
+}
+
+// A line of JSON putting the lexer in the right state for the given open
+// brackets. For "[{[" this is `[{"":[`.
+func jsonContextPrefix(nesting []byte) string {
+	prefix := strings.Builder{}
+	for i, bracket := range nesting {
+		if i > 0 && nesting[i-1] == '{' {
+			prefix.WriteString(`"":`)
+		}
+		prefix.WriteByte(bracket)
+	}
+	return prefix.String()
+}
+
+// Which JSON brackets are open at the start of a chunk. Nil if finding out
+// would mean reading more than jsonNestingMaxChunks chunks.
+func (h *lazyHighlighter) jsonNestingAt(chunk int) []byte {
+	h.lock.Lock()
+	known := chunk
+	for {
+		if _, ok := h.jsonNesting[known]; ok {
+			break
+		}
+		known--
+	}
+	nesting := slices.Clone(h.jsonNesting[known])
+	h.lock.Unlock()
+
+	if chunk-known > jsonNestingMaxChunks {
+		return nil
+	}
+
+	inString := false
+	escaped := false
+	for known < chunk {
+		first := known * lazyHighlightChunkLines
+
+		h.reader.RLock()
+		last := min(first+lazyHighlightChunkLines, h.reader.lineCountLocked())
+		first = min(max(first, h.reader.droppedLines), last)
+		lines := slices.Clone(h.reader.linesLocked(first, last))
+		h.reader.RUnlock()
+
+		for i, line := range lines {
+			for _, char := range []byte(line.Plain(linemetadata.IndexFromZeroBased(first + i))) {
+				switch {
+				case inString && escaped:
+					escaped = false
+				case inString && char == '\\':
+					escaped = true
+				case char == '"':
+					inString = !inString
+				case inString:
+					// Brackets in strings don't count
+				case char == '{' || char == '[':
+					nesting = append(nesting, char)
+				case (char == '}' || char == ']') && len(nesting) > 0:
+					nesting = nesting[:len(nesting)-1]
+				}
+			}
+		}
+
+		known++
+		h.lock.Lock()
+		h.jsonNesting[known] = slices.Clone(nesting)
+		h.lock.Unlock()
+	}
+
+	return nesting
+}
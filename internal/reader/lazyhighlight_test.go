@@ -0,0 +1,117 @@
+package reader
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"gotest.tools/v3/assert"
+)
+
+func TestJSONContextPrefix(t *testing.T) {
+	assert.Equal(t, jsonContextPrefix([]byte{}), "")
+	assert.Equal(t, jsonContextPrefix([]byte("[")), "[")
+	assert.Equal(t, jsonContextPrefix([]byte("[{[")), `[{"":[`)
+	assert.Equal(t, jsonContextPrefix([]byte("{{")), `{"":{`)
+}
+
+// Highlighting a chunk in the middle of a JSON document should look the same
+// as when highlighting the whole document
+func TestLazyHighlightJSON(t *testing.T) {
+	records := []map[string]any{}
+	for i := range 1000 {
+		records = append(records, map[string]any{
+			"index": i,
+			"tags":  []string{"a]", "{b"},
+			"ok":    i%2 == 0,
+			"child": map[string]any{"name": "x"},
+		})
+	}
+	jsonBytes, err := json.MarshalIndent(map[string]any{"records": records}, "", "  ")
+	assert.NilError(t, err)
+	jsonText := string(jsonBytes)
+
+	style := styles.Get("native")
+	fullyHighlighted, err := Highlight(jsonText, *style, formatters.TTY16m, lexers.Get("json"))
+	assert.NilError(t, err)
+	expectedLines := strings.Split(*fullyHighlighted, "\n")
+
+	testMe := NewFromTextForTesting("", jsonText)
+	startLazyHighlighting(testMe, formatters.TTY16m, ReaderOptions{Style: style})
+	assert.Assert(t, testMe.lazyHighlighter != nil)
+
+	// Highlight the third chunk only
+	testMe.lazyHighlighter.highlightChunk(2)
+	for i := range testMe.GetLineCount() {
+		line := testMe.GetLine(linemetadata.IndexFromZeroBased(i))
+		if i/lazyHighlightChunkLines == 2 {
+			assert.Equal(t, string(line.Line.raw), expectedLines[i], "line %d", i)
+		} else {
+			assert.Assert(t, !strings.Contains(string(line.Line.raw), "\x1b"), "line %d", i)
+		}
+	}
+}
+
+// Far into large JSON documents, we shouldn't read everything before the chunk
+// we want to highlight
+func TestLazyHighlightJSONFarAway(t *testing.T) {
+	records := []string{}
+	for i := range (jsonNestingMaxChunks + 5) * lazyHighlightChunkLines {
+		records = append(records, fmt.Sprint(`  {"index": `, i, `},`))
+	}
+	jsonText := "[\n" + strings.Join(records, "\n") + "\n]"
+
+	testMe := NewFromTextForTesting("", jsonText)
+	startLazyHighlighting(testMe, formatters.TTY16m, ReaderOptions{Style: styles.Get("native")})
+	assert.Assert(t, testMe.lazyHighlighter != nil)
+
+	lastChunk := jsonNestingMaxChunks + 4
+	assert.Assert(t, testMe.lazyHighlighter.jsonNestingAt(lastChunk) == nil)
+	assert.Equal(t, len(testMe.lazyHighlighter.jsonNesting), 1, "Nothing should have been read")
+
+	// Highlighting still works, just without the context
+	testMe.lazyHighlighter.highlightChunk(lastChunk)
+	line := testMe.GetLine(linemetadata.IndexFromZeroBased(lastChunk * lazyHighlightChunkLines))
+	assert.Assert(t, strings.Contains(string(line.Line.raw), "\x1b["))
+
+	// Close enough chunks get their context
+	assert.DeepEqual(t, testMe.lazyHighlighter.jsonNestingAt(2), []byte("["))
+}
+
+func TestLazyHighlightLog(t *testing.T) {
+	logLines := []string{}
+	byteCount := 0
+	for int64(byteCount) <= MAX_HIGHLIGHT_SIZE {
+		logLines = append(logLines, logSamples[LogFormatLogfmt]...)
+		for _, line := range logSamples[LogFormatLogfmt] {
+			byteCount += len(line)
+		}
+	}
+
+	testMe, err := NewFromStream(
+		"Large log test",
+		strings.NewReader(strings.Join(logLines, "\n")),
+		formatters.TTY16m,
+		ReaderOptions{Style: styles.Get("native")})
+	assert.NilError(t, err)
+	assert.NilError(t, testMe.Wait())
+
+	middle := linemetadata.NumberFromZeroBased(testMe.GetLineCount() / 2)
+	middleIndex := linemetadata.IndexFromZeroBased(middle.AsZeroBased())
+	assert.Assert(t, !strings.Contains(string(testMe.GetLine(middleIndex).Line.raw), "\x1b["))
+
+	testMe.HighlightLazily(middle, middle)
+	for !strings.Contains(string(testMe.GetLine(middleIndex).Line.raw), "\x1b[") {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, testMe.GetLine(middleIndex).Plain(), logLines[middle.AsZeroBased()])
+
+	// Far away lines are left alone
+	assert.Assert(t, !strings.Contains(string(testMe.GetLine(linemetadata.Index{}).Line.raw), "\x1b["))
+}
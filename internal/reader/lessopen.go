@@ -0,0 +1,273 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alecthomas/chroma/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// Run a file through the $LESSOPEN input preprocessor, like less does. Three
+// forms are supported, see the INPUT PREPROCESSOR section of "man less":
+//
+//   - "|cmd %s": The output of cmd is shown, or the original file if cmd
+//     prints nothing.
+//   - "||cmd %s": Like the above, but empty output from a successful cmd is
+//     shown as an empty file.
+//   - "cmd %s": cmd prints the name of a replacement file to show instead.
+//
+// Returns nil if the original file should be shown.
+func lessOpen(filename string) io.Reader {
+	lessopen := os.Getenv("LESSOPEN")
+	if lessopen == "" {
+		return nil
+	}
+
+	if os.Getenv("LESSSECURE") == "1" {
+		log.Info("Not preprocessing ", filename, " since LESSSECURE=1 is set in the environment")
+		return nil
+	}
+
+	template, isPipe := strings.CutPrefix(lessopen, "|")
+	template, emptyIsContents := strings.CutPrefix(template, "|")
+
+	// "|-cmd %s" means cmd can also preprocess stdin. We only preprocess
+	// files, so that makes no difference to us.
+	template = strings.TrimPrefix(template, "-")
+
+	if strings.Count(template, "%s") != 1 {
+		log.Warnf("Ignoring $LESSOPEN, it must contain exactly one %%s: %s", lessopen)
+		return nil
+	}
+	command := expandPercentS(template, filename)
+
+	if isPipe {
+		return lessOpenPipe(filename, command, emptyIsContents)
+	}
+	return lessOpenReplacement(filename, command)
+}
+
+// Show the output of the preprocessor command
+func lessOpenPipe(filename string, command string, emptyIsContents bool) io.Reader {
+	cmd := shellCommand(command)
+	stderr := bytes.Buffer{}
+	cmd.Stderr = &stderr
+
+	// Don't wait for the preprocessor's children to let go of stderr after
+	// we kill it
+	cmd.WaitDelay = time.Second
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		log.Warn("Failed to set up $LESSOPEN preprocessor output: ", err)
+		return nil
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		log.Warn("Failed to start $LESSOPEN preprocessor <", command, ">: ", err)
+		return nil
+	}
+
+	// No output at all means we should show the original file, so check
+	// whether there is some
+	output := bufio.NewReader(stdout)
+	_, peekErr := output.Peek(1)
+	if peekErr != nil {
+		waitErr := cmd.Wait()
+		if peekErr == io.EOF && waitErr == nil && emptyIsContents {
+			log.Debug("$LESSOPEN preprocessor says ", filename, " is empty")
+			lessClose(filename, "-")
+			return strings.NewReader("")
+		}
+
+		log.Debug("No $LESSOPEN preprocessor output for ", filename, ", showing it as is: ", waitErr, " ", stderr.String())
+		return nil
+	}
+
+	log.Info("Preprocessing ", filename, " using <", command, ">")
+	killed := atomic.Bool{}
+	return &endingReader{
+		reader: output,
+		onClose: func() {
+			// We won't read any more, so the preprocessor can stop
+			killed.Store(true)
+			_ = cmd.Process.Kill()
+		},
+		onEnd: func() {
+			err := cmd.Wait()
+			if err != nil && !killed.Load() {
+				log.Warn("$LESSOPEN preprocessor <", command, "> failed: ", err, " ", stderr.String())
+			}
+
+			lessClose(filename, "-")
+		},
+	}
+}
+
+// Show the replacement file the preprocessor command tells us about
+func lessOpenReplacement(filename string, command string) io.Reader {
+	output, err := shellCommand(command).Output()
+	if err != nil {
+		// Like less, we go by the output rather than the exit code
+		log.Debug("$LESSOPEN preprocessor <", command, "> failed: ", err)
+	}
+
+	firstLine, _, _ := strings.Cut(string(output), "\n")
+	replacement := strings.TrimSuffix(firstLine, "\r")
+	if replacement == "" {
+		log.Debug("No $LESSOPEN replacement file for ", filename, ", showing it as is")
+		return nil
+	}
+
+	file, err := os.Open(replacement)
+	if err != nil {
+		log.Warn("Failed to open $LESSOPEN replacement file for ", filename, ", showing it as is: ", err)
+		lessClose(filename, replacement)
+		return nil
+	}
+
+	// We have the replacement file open, so $LESSCLOSE can remove it already.
+	// That way it gets cleaned up even if we exit before reading all of it.
+	lessClose(filename, replacement)
+
+	log.Info("Showing ", replacement, " from $LESSOPEN instead of ", filename)
+	return &endingReader{
+		reader: file,
+		onEnd: func() {
+			_ = file.Close()
+		},
+	}
+}
+
+// Run the $LESSCLOSE postprocessor, if set. The replacement is what $LESSOPEN
+// gave us, or "-" for pipes.
+func lessClose(filename string, replacement string) {
+	lessclose := os.Getenv("LESSCLOSE")
+	if lessclose == "" {
+		return
+	}
+
+	if strings.Count(lessclose, "%s") > 2 {
+		log.Warnf("Ignoring $LESSCLOSE, it must contain at most two %%s: %s", lessclose)
+		return
+	}
+
+	command := expandPercentS(lessclose, filename, replacement)
+	output, err := shellCommand(command).CombinedOutput()
+	if err != nil {
+		log.Warn("$LESSCLOSE postprocessor <", command, "> failed: ", err, " ", string(output))
+	}
+}
+
+// Replace each %s in the template with the next value, shell quoted. Any %s
+// in the values are left alone.
+func expandPercentS(template string, values ...string) string {
+	parts := strings.Split(template, "%s")
+
+	expanded := strings.Builder{}
+	expanded.WriteString(parts[0])
+	for i, part := range parts[1:] {
+		value := ""
+		if i < len(values) {
+			value = shellQuote(values[i])
+		}
+		expanded.WriteString(value)
+		expanded.WriteString(part)
+	}
+
+	return expanded.String()
+}
+
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + s + `"`
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+
+	return exec.Command("sh", "-c", command)
+}
+
+// Calls onEnd once when the stream ends, either at EOF, on errors or when
+// closed. Closing before the end calls onClose first.
+type endingReader struct {
+	reader  io.Reader
+	onClose func()
+	onEnd   func()
+	ended   atomic.Bool
+	endOnce sync.Once
+}
+
+func (r *endingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if err != nil {
+		r.ended.Store(true)
+		r.endOnce.Do(r.onEnd)
+	}
+
+	return n, err
+}
+
+func (r *endingReader) Close() error {
+	if !r.ended.Load() && r.onClose != nil {
+		r.onClose()
+	}
+	r.endOnce.Do(r.onEnd)
+
+	return nil
+}
+
+// Create a reader for preprocessor output. This output is what we show for the
+// file, but since it isn't the file contents, we neither tail nor index the
+// file.
+func newPreprocessedReader(filename string, stream io.Reader, formatter chroma.Formatter, options ReaderOptions) (*ReaderImpl, error) {
+	zReader, err := ZReader(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	// No guessing the lexer from the file name, preprocessor output is
+	// generally something else than the file. Think PDFs converted to text,
+	// or archive listings.
+
+	returnMe := newReaderImpl(&filename, options)
+	returnMe.preprocessed = true
+	if closer, ok := stream.(io.Closer); ok {
+		returnMe.closeStream = closer.Close
+	}
+	returnMe.startReading(zReader, formatter, options)
+
+	if options.Lexer == nil {
+		returnMe.HighlightingDone.Store(true)
+	}
+
+	if options.Style != nil {
+		returnMe.SetStyleForHighlighting(*options.Style)
+	}
+
+	return returnMe, nil
+}
+
+// IsPreprocessed tells whether the input went through $LESSOPEN, in which
+// case what we show isn't what's in the file
+func (reader *ReaderImpl) IsPreprocessed() bool {
+	reader.RLock()
+	defer reader.RUnlock()
+
+	return reader.preprocessed
+}
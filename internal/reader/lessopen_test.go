@@ -0,0 +1,111 @@
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/styles"
+	"gotest.tools/v3/assert"
+)
+
+// Read a file the way moor would, with $LESSOPEN set to lessopen
+func readWithLessOpen(t *testing.T, lessopen string, fileName string) []string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("The preprocessors in these tests need a Unix shell")
+	}
+
+	t.Setenv("LESSOPEN", lessopen)
+
+	reader, err := NewFromFilename(fileName, formatters.TTY16m, ReaderOptions{Style: styles.Get("native"), LessOpen: true})
+	assert.NilError(t, err)
+	assert.NilError(t, reader.Wait())
+
+	return allPlainLines(reader)
+}
+
+func writeTestFile(t *testing.T, contents string) string {
+	t.Helper()
+
+	// Spaces and quotes make sure file names are quoted properly
+	fileName := filepath.Join(t.TempDir(), "it's a file.txt")
+	assert.NilError(t, os.WriteFile(fileName, []byte(contents), 0o600))
+	return fileName
+}
+
+func TestLessOpenPipe(t *testing.T) {
+	fileName := writeTestFile(t, "hello\n")
+
+	lines := readWithLessOpen(t, "|tr a-z A-Z < %s", fileName)
+	assert.DeepEqual(t, lines, []string{"HELLO"})
+
+	// No output means the original file should be shown
+	lines = readWithLessOpen(t, "|true %s", fileName)
+	assert.DeepEqual(t, lines, []string{"hello"})
+}
+
+func TestLessOpenDoublePipe(t *testing.T) {
+	fileName := writeTestFile(t, "hello\n")
+
+	// No output and success means empty contents...
+	lines := readWithLessOpen(t, "||true %s", fileName)
+	assert.DeepEqual(t, lines, []string{})
+
+	// ... but failure means the original file should be shown
+	lines = readWithLessOpen(t, "||false %s", fileName)
+	assert.DeepEqual(t, lines, []string{"hello"})
+}
+
+func TestLessOpenReplacementFile(t *testing.T) {
+	fileName := writeTestFile(t, "hello\n")
+	replacement := filepath.Join(t.TempDir(), "replacement.txt")
+
+	t.Setenv("LESSCLOSE", "touch %s.closed; rm %s")
+	lines := readWithLessOpen(t, "tr a-z A-Z < %s > "+replacement+"; echo "+replacement, fileName)
+	assert.DeepEqual(t, lines, []string{"HELLO"})
+
+	// $LESSCLOSE should have gotten both file names
+	_, err := os.Stat(fileName + ".closed")
+	assert.NilError(t, err)
+	_, err = os.Stat(replacement)
+	assert.Assert(t, os.IsNotExist(err), "Replacement file should have been removed")
+}
+
+func TestLessOpenLessSecure(t *testing.T) {
+	fileName := writeTestFile(t, "hello\n")
+
+	t.Setenv("LESSSECURE", "1")
+	lines := readWithLessOpen(t, "|tr a-z A-Z < %s", fileName)
+	assert.DeepEqual(t, lines, []string{"hello"})
+}
+
+// Stopping the reader before the preprocessor is done should stop the
+// preprocessor and run $LESSCLOSE
+func TestLessOpenPipeStop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The preprocessors in these tests need a Unix shell")
+	}
+	fileName := writeTestFile(t, "hello\n")
+
+	t.Setenv("LESSOPEN", "|echo %s; exec sleep 60")
+	t.Setenv("LESSCLOSE", "touch %s.closed")
+	reader, err := NewFromFilename(fileName, formatters.TTY16m, ReaderOptions{Style: styles.Get("native"), LessOpen: true})
+	assert.NilError(t, err)
+
+	reader.Stop()
+
+	closed := fileName + ".closed"
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err = os.Stat(closed)
+		if err == nil {
+			break
+		}
+		assert.Assert(t, time.Now().Before(deadline), "$LESSCLOSE never ran")
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -7,21 +7,46 @@ import (
 	"github.com/walles/moor/v2/twin"
 )
 
+// A pattern that stays highlighted in its own style, independent of searching
+type PinnedHighlight struct {
+	Search search.Search
+	Style  twin.Style
+}
+
 // Returns a representation of the string split into styled tokens. Any regexp
 // matches are highlighted. A nil regexp means no highlighting.
 //
+// Pinned highlights are applied in order, the first matching one wins. Search
+// hits take precedence over pinned highlights.
+//
 // minRunesCount: at least this many runes will be included in the result. If 0,
 // do all runes. For BenchmarkRenderHugeLine() performance.
 func (line *Line) HighlightedTokens(
 	plainTextStyle twin.Style,
 	searchHitStyle twin.Style,
 	search search.Search,
+	pinned []PinnedHighlight,
 	lineIndex linemetadata.Index,
 	minRunesCount int,
 ) textstyles.StyledRunesWithTrailer {
-	matchRanges := search.GetMatchRanges(line.Plain(lineIndex))
-
 	fromString := textstyles.StyledRunesFromString(plainTextStyle, string(line.raw), &lineIndex, minRunesCount)
+	return highlightMatches(fromString, line.Plain(lineIndex), searchHitStyle, search, pinned)
+}
+
+// Highlight search hits and pinned highlights in already styled cells. The
+// plain text is what the search is applied to, and must correspond to the
+// cells.
+func highlightMatches(
+	fromString textstyles.StyledRunesWithTrailer,
+	plain string,
+	searchHitStyle twin.Style,
+	search search.Search,
+	pinned []PinnedHighlight,
+) textstyles.StyledRunesWithTrailer {
+	matchRanges := search.GetMatchRanges(plain)
+
+	pinnedRanges := pinnedMatchRanges(pinned, plain)
+
 	returnRunes := make([]textstyles.CellWithMetadata, 0, len(fromString.StyledRunes))
 	lastWasSearchHit := false
 	for _, token := range fromString.StyledRunes {
@@ -30,6 +55,13 @@ func (line *Line) HighlightedTokens(
 		if searchHit {
 			// Highlight the search hit
 			style = searchHitStyle
+		} else {
+			for i, ranges := range pinnedRanges {
+				if ranges.InRange(len(returnRunes)) {
+					style = pinned[i].Style
+					break
+				}
+			}
 		}
 
 		returnRunes = append(returnRunes, textstyles.CellWithMetadata{
@@ -48,6 +80,14 @@ func (line *Line) HighlightedTokens(
 	}
 }
 
+func pinnedMatchRanges(pinned []PinnedHighlight, plain string) []*search.MatchRanges {
+	ranges := make([]*search.MatchRanges, len(pinned))
+	for i, highlight := range pinned {
+		ranges[i] = highlight.Search.GetMatchRanges(plain)
+	}
+	return ranges
+}
+
 func (line *Line) HasManPageFormatting() bool {
 	return textstyles.HasManPageFormatting(string(line.raw))
 }
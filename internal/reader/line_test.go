@@ -42,7 +42,7 @@ func TestSearchHitSpanningWrapBoundary(t *testing.T) {
 	searchHitStyle := twin.StyleDefault.WithForeground(twin.NewColor16(3))
 
 	// Match runs from indices 3..8 inclusive ("345678")
-	highlighted := line.HighlightedTokens(twin.StyleDefault, searchHitStyle, search.For("345678"), linemetadata.Index{}, 0)
+	highlighted := line.HighlightedTokens(twin.StyleDefault, searchHitStyle, search.For("345678"), nil, linemetadata.Index{}, 0)
 
 	// Sanity: overall line reports having a search hit
 	assert.Assert(t, highlighted.ContainsSearchHit, "Expected overall line to contain search hit")
@@ -66,3 +66,31 @@ func TestSearchHitSpanningWrapBoundary(t *testing.T) {
 		}
 	}
 }
+
+func TestHighlightedTokensWithPinnedHighlights(t *testing.T) {
+	line := NewFromTextForTesting("TestHighlightedTokensWithPinnedHighlights", "abc def abc").GetLine(linemetadata.Index{}).Line
+	searchHitStyle := twin.StyleDefault.WithForeground(twin.NewColor16(3))
+	redStyle := twin.StyleDefault.WithBackground(twin.NewColor16(1))
+	blueStyle := twin.StyleDefault.WithBackground(twin.NewColor16(4))
+
+	pinned := []PinnedHighlight{
+		{Search: search.For("abc"), Style: redStyle},
+		{Search: search.For("c d"), Style: blueStyle},
+	}
+	highlighted := line.HighlightedTokens(twin.StyleDefault, searchHitStyle, search.For("def"), pinned, linemetadata.Index{}, 0)
+
+	expectedStyles := []twin.Style{
+		redStyle, redStyle, redStyle, // "abc", the first pin wins over the second
+		blueStyle,                                      // " "
+		searchHitStyle, searchHitStyle, searchHitStyle, // "def", the search wins over pins
+		twin.StyleDefault,
+		redStyle, redStyle, redStyle, // "abc"
+	}
+	assert.Equal(t, len(highlighted.StyledRunes), len(expectedStyles))
+	for i, cell := range highlighted.StyledRunes {
+		assert.Assert(t, cell.Style.Equal(expectedStyles[i]), "Unexpected style at index %d: %v", i, cell.Style)
+	}
+
+	// Pinned highlights are not search hits
+	assert.Assert(t, !highlighted.StyledRunes[0].IsSearchHit)
+}
@@ -0,0 +1,383 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+)
+
+// LogFormat is a log file line format we know how to find timestamps and
+// severity levels in
+type LogFormat int
+
+const (
+	LogFormatNone LogFormat = iota
+	LogFormatLogfmt
+	LogFormatJSON
+	LogFormatSyslog
+	LogFormatKlog
+	LogFormatAccessLog
+)
+
+// Formats in detection order, more specific formats first
+var logFormats = []LogFormat{
+	LogFormatJSON,
+	LogFormatKlog,
+	LogFormatAccessLog,
+	LogFormatSyslog,
+	LogFormatLogfmt,
+}
+
+// LogLevel is a log line severity. Higher is more severe.
+type LogLevel int
+
+const (
+	LogLevelUnknown LogLevel = iota
+	LogLevelTrace
+	LogLevelDebug
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+	LogLevelFatal
+)
+
+// Look at this many non-empty lines when detecting the log format
+const logFormatDetectionLines = 20
+
+var (
+	// "I1018 12:34:56.789012   12345 main.go:42] Hello"
+	klogRegex = regexp.MustCompile(`^([IWEF])(\d{4} \d\d:\d\d:\d\d\.\d+)\s+\d+ [^\]]+\] `)
+
+	// `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.0" 200 2326`
+	accessLogRegex = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "[^"]*" (\d{3}) `)
+
+	// "<34>Oct 11 22:14:15 mymachine su[123]: 'su root' failed", the priority
+	// is optional, and the timestamp may also be in ISO 8601 format
+	syslogRegex = regexp.MustCompile(`^(?:<(\d{1,3})>(?:1 )?)?([A-Z][a-z]{2} [ \d]\d \d\d:\d\d:\d\d|\d{4}-\d\d-\d\dT\S+) \S+ [^\s:]+: `)
+
+	// Syslog lines have no level field, but the message often has one
+	syslogLevelRegex = regexp.MustCompile(`\b(TRACE|DEBUG|INFO|NOTICE|WARN|WARNING|ERROR|CRIT|CRITICAL|FATAL|PANIC)\b`)
+
+	// `time=2025-10-18T12:34:56Z level=info msg="Hello"`
+	logfmtRegex          = regexp.MustCompile(`^\s*[\w.-]+=("[^"]*"|\S*)(\s+[\w.-]+=("[^"]*"|\S*))+\s*$`)
+	logfmtLevelRegex     = regexp.MustCompile(`(?:^|\s)(?:level|lvl|severity)="?(\w+)`)
+	logfmtTimestampRegex = regexp.MustCompile(`(?:^|\s)(?:time|ts|timestamp)=("[^"]*"|\S+)`)
+
+	// `{"time":"2025-10-18T12:34:56Z","level":"info","msg":"Hello"}`
+	jsonLevelRegex     = regexp.MustCompile(`"(?:level|lvl|severity|log\.level)"\s*:\s*(?:"(\w+)"|(\d+))`)
+	jsonTimestampRegex = regexp.MustCompile(`"(?:time|ts|timestamp|@timestamp)"\s*:\s*("[^"]*"|[\d.]+)`)
+)
+
+// The parts of a log line we know how to color. Ranges are byte offsets into
+// the line, and are empty if not found.
+type logLine struct {
+	level LogLevel
+
+	levelStart int
+	levelEnd   int
+
+	timestampStart int
+	timestampEnd   int
+}
+
+func (f LogFormat) String() string {
+	switch f {
+	case LogFormatLogfmt:
+		return "logfmt"
+	case LogFormatJSON:
+		return "JSON lines"
+	case LogFormatSyslog:
+		return "syslog"
+	case LogFormatKlog:
+		return "klog"
+	case LogFormatAccessLog:
+		return "access log"
+	}
+	return "none"
+}
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelTrace:
+		return "TRACE"
+	case LogLevelDebug:
+		return "DEBUG"
+	case LogLevelInfo:
+		return "INFO"
+	case LogLevelWarn:
+		return "WARN"
+	case LogLevelError:
+		return "ERROR"
+	case LogLevelFatal:
+		return "FATAL"
+	}
+	return "UNKNOWN"
+}
+
+// DetectLogFormat looks at the first lines of some input, and returns the log
+// format most of them are in. Returns LogFormatNone if the input doesn't look
+// like a log.
+func DetectLogFormat(lines []string) LogFormat {
+	sample := []string{}
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		sample = append(sample, line)
+		if len(sample) >= logFormatDetectionLines {
+			break
+		}
+	}
+	if len(sample) == 0 {
+		return LogFormatNone
+	}
+
+	best := LogFormatNone
+	bestCount := 0
+	for _, format := range logFormats {
+		count := 0
+		for _, line := range sample {
+			if format.matches(line) {
+				count++
+			}
+		}
+
+		if count > bestCount {
+			best = format
+			bestCount = count
+		}
+	}
+
+	// Allow for stack traces and other multi line messages, but most lines
+	// should be in the format
+	if bestCount*2 < len(sample) {
+		return LogFormatNone
+	}
+	return best
+}
+
+func (f LogFormat) matches(line string) bool {
+	switch f {
+	case LogFormatLogfmt:
+		return logfmtRegex.MatchString(line) &&
+			(logfmtLevelRegex.MatchString(line) || logfmtTimestampRegex.MatchString(line))
+	case LogFormatJSON:
+		trimmed := strings.TrimSpace(line)
+		return strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed))
+	case LogFormatSyslog:
+		return syslogRegex.MatchString(line)
+	case LogFormatKlog:
+		return klogRegex.MatchString(line)
+	case LogFormatAccessLog:
+		return accessLogRegex.MatchString(line)
+	}
+	return false
+}
+
+// Level returns the severity of a log line, or LogLevelUnknown if the line has
+// none. Continuation lines, like in stack traces, usually have none.
+func (f LogFormat) Level(line string) LogLevel {
+	return f.parse(line).level
+}
+
+func (f LogFormat) parse(line string) logLine {
+	result := logLine{}
+
+	setLevel := func(match []int, group int, level LogLevel) {
+		result.level = level
+		result.levelStart = match[2*group]
+		result.levelEnd = match[2*group+1]
+	}
+	setTimestamp := func(match []int, group int) {
+		result.timestampStart = match[2*group]
+		result.timestampEnd = match[2*group+1]
+	}
+
+	switch f {
+	case LogFormatKlog:
+		if match := klogRegex.FindStringSubmatchIndex(line); match != nil {
+			setLevel(match, 1, parseLogLevel(line[match[2]:match[3]]))
+			setTimestamp(match, 2)
+		}
+
+	case LogFormatAccessLog:
+		if match := accessLogRegex.FindStringSubmatchIndex(line); match != nil {
+			setTimestamp(match, 1)
+			status, _ := strconv.Atoi(line[match[4]:match[5]])
+			switch {
+			case status >= 500:
+				setLevel(match, 2, LogLevelError)
+			case status >= 400:
+				setLevel(match, 2, LogLevelWarn)
+			default:
+				setLevel(match, 2, LogLevelInfo)
+			}
+		}
+
+	case LogFormatSyslog:
+		match := syslogRegex.FindStringSubmatchIndex(line)
+		if match == nil {
+			break
+		}
+		setTimestamp(match, 2)
+		if levelMatch := syslogLevelRegex.FindStringSubmatchIndex(line[match[1]:]); levelMatch != nil {
+			setLevel(levelMatch, 1, parseLogLevel(line[match[1]+levelMatch[2]:match[1]+levelMatch[3]]))
+			result.levelStart += match[1]
+			result.levelEnd += match[1]
+		} else if match[2] >= 0 {
+			// Fall back on the priority, where severity 0 is the most severe
+			priority, _ := strconv.Atoi(line[match[2]:match[3]])
+			setLevel(match, 1, syslogSeverity(priority%8))
+		} else {
+			// A log line after all, don't mistake it for a continuation line
+			result.level = LogLevelInfo
+		}
+
+	case LogFormatLogfmt:
+		if match := logfmtLevelRegex.FindStringSubmatchIndex(line); match != nil {
+			setLevel(match, 1, parseLogLevel(line[match[2]:match[3]]))
+		}
+		if match := logfmtTimestampRegex.FindStringSubmatchIndex(line); match != nil {
+			setTimestamp(match, 1)
+		}
+
+	case LogFormatJSON:
+		if match := jsonLevelRegex.FindStringSubmatchIndex(line); match != nil {
+			if match[2] >= 0 {
+				setLevel(match, 1, parseLogLevel(line[match[2]:match[3]]))
+			} else {
+				// Numeric levels, as logged by pino and bunyan
+				number, _ := strconv.Atoi(line[match[4]:match[5]])
+				setLevel(match, 2, numericLogLevel(number))
+			}
+		}
+		if match := jsonTimestampRegex.FindStringSubmatchIndex(line); match != nil {
+			setTimestamp(match, 1)
+		}
+	}
+
+	return result
+}
+
+func parseLogLevel(s string) LogLevel {
+	switch strings.ToLower(s) {
+	case "t", "trace", "trc":
+		return LogLevelTrace
+	case "d", "debug", "dbg":
+		return LogLevelDebug
+	case "i", "info", "inf", "information", "notice":
+		return LogLevelInfo
+	case "w", "warn", "wrn", "warning":
+		return LogLevelWarn
+	case "e", "error", "err", "eror":
+		return LogLevelError
+	case "f", "fatal", "ftl", "crit", "critical", "panic", "alert", "emerg", "emergency":
+		return LogLevelFatal
+	}
+	return LogLevelUnknown
+}
+
+// Syslog severities go from 0 (emergency) to 7 (debug)
+func syslogSeverity(severity int) LogLevel {
+	switch {
+	case severity <= 2:
+		return LogLevelFatal
+	case severity == 3:
+		return LogLevelError
+	case severity == 4:
+		return LogLevelWarn
+	case severity <= 6:
+		return LogLevelInfo
+	}
+	return LogLevelDebug
+}
+
+// Numeric levels go from 10 (trace) to 60 (fatal)
+func numericLogLevel(number int) LogLevel {
+	switch {
+	case number >= 60:
+		return LogLevelFatal
+	case number >= 50:
+		return LogLevelError
+	case number >= 40:
+		return LogLevelWarn
+	case number >= 30:
+		return LogLevelInfo
+	case number >= 20:
+		return LogLevelDebug
+	case number >= 10:
+		return LogLevelTrace
+	}
+	return LogLevelUnknown
+}
+
+func (l LogLevel) tokenType() chroma.TokenType {
+	switch l {
+	case LogLevelTrace, LogLevelDebug:
+		return chroma.Comment
+	case LogLevelInfo:
+		return chroma.GenericInserted
+	case LogLevelWarn:
+		return chroma.NameDecorator
+	case LogLevelError:
+		return chroma.GenericError
+	case LogLevelFatal:
+		return chroma.CommentSpecial
+	}
+	return chroma.Text
+}
+
+// HighlightLog colors timestamps and severity levels in log lines. Returns nil
+// with no error if there is nothing to highlight.
+func HighlightLog(lines []string, format LogFormat, style chroma.Style, formatter chroma.Formatter) (*string, error) {
+	if format == LogFormatNone {
+		return nil, nil
+	}
+
+	tokens := []chroma.Token{}
+	for _, line := range lines {
+		parsed := format.parse(line)
+
+		type span struct {
+			start     int
+			end       int
+			tokenType chroma.TokenType
+		}
+		spans := []span{}
+		if parsed.timestampEnd > parsed.timestampStart {
+			spans = append(spans, span{parsed.timestampStart, parsed.timestampEnd, chroma.LiteralNumber})
+		}
+		if parsed.levelEnd > parsed.levelStart {
+			spans = append(spans, span{parsed.levelStart, parsed.levelEnd, parsed.level.tokenType()})
+		}
+		slices.SortFunc(spans, func(a, b span) int {
+			return a.start - b.start
+		})
+
+		position := 0
+		for _, s := range spans {
+			if s.start < position {
+				// Overlapping, never mind
+				continue
+			}
+			tokens = append(tokens, chroma.Token{Type: chroma.Text, Value: line[position:s.start]})
+			tokens = append(tokens, chroma.Token{Type: s.tokenType, Value: line[s.start:s.end]})
+			position = s.end
+		}
+		tokens = append(tokens, chroma.Token{Type: chroma.Text, Value: line[position:] + "\n"})
+	}
+
+	var buffer bytes.Buffer
+	err := formatter.Format(&buffer, &style, chroma.Literator(tokens...))
+	if err != nil {
+		return nil, err
+	}
+
+	highlighted := strings.TrimSuffix(buffer.String(), "\x1b[0m")
+	return &highlighted, nil
+}
@@ -0,0 +1,98 @@
+package reader
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/textstyles"
+	"gotest.tools/v3/assert"
+)
+
+var logSamples = map[LogFormat][]string{
+	LogFormatLogfmt: {
+		`time=2025-10-18T12:34:56Z level=info msg="Starting up" port=8080`,
+		`time=2025-10-18T12:34:57Z level=warn msg="Slow request" duration=2s`,
+		`time=2025-10-18T12:34:58Z level=error msg="Request failed"`,
+	},
+	LogFormatJSON: {
+		`{"time":"2025-10-18T12:34:56Z","level":"info","msg":"Starting up"}`,
+		`{"time":"2025-10-18T12:34:57Z","level":"warn","msg":"Slow request"}`,
+		`{"time":1760790898,"level":50,"msg":"Request failed"}`,
+	},
+	LogFormatSyslog: {
+		`Oct 18 12:34:56 myhost sshd[123]: Server listening on 0.0.0.0 port 22.`,
+		`Oct 18 12:34:57 myhost myapp: WARNING disk almost full`,
+		`<11>Oct 18 12:34:58 myhost myapp[456]: Something broke`,
+	},
+	LogFormatKlog: {
+		`I1018 12:34:56.789012   12345 main.go:42] Starting up`,
+		`W1018 12:34:57.789012   12345 main.go:43] Slow request`,
+		`E1018 12:34:58.789012   12345 main.go:44] Request failed`,
+	},
+	LogFormatAccessLog: {
+		`127.0.0.1 - frank [18/Oct/2025:12:34:56 +0200] "GET / HTTP/1.1" 200 2326`,
+		`127.0.0.1 - - [18/Oct/2025:12:34:57 +0200] "GET /nope HTTP/1.1" 404 12`,
+		`127.0.0.1 - - [18/Oct/2025:12:34:58 +0200] "POST /api HTTP/1.1" 503 0`,
+	},
+}
+
+func TestDetectLogFormat(t *testing.T) {
+	for format, lines := range logSamples {
+		assert.Equal(t, DetectLogFormat(lines), format)
+
+		// A stack trace shouldn't throw detection off
+		withStackTrace := append([]string{}, lines...)
+		withStackTrace = append(withStackTrace, "  at main.go:45", "  at main.go:46")
+		assert.Equal(t, DetectLogFormat(withStackTrace), format)
+	}
+
+	assert.Equal(t, DetectLogFormat(nil), LogFormatNone)
+	assert.Equal(t, DetectLogFormat([]string{"Hello", "world", "a=b"}), LogFormatNone)
+	assert.Equal(t, DetectLogFormat([]string{`{"json": "object"`, `}`}), LogFormatNone)
+}
+
+func TestLogLevels(t *testing.T) {
+	expected := map[LogFormat][]LogLevel{
+		LogFormatLogfmt:    {LogLevelInfo, LogLevelWarn, LogLevelError},
+		LogFormatJSON:      {LogLevelInfo, LogLevelWarn, LogLevelError},
+		LogFormatSyslog:    {LogLevelInfo, LogLevelWarn, LogLevelError},
+		LogFormatKlog:      {LogLevelInfo, LogLevelWarn, LogLevelError},
+		LogFormatAccessLog: {LogLevelInfo, LogLevelWarn, LogLevelError},
+	}
+
+	for format, lines := range logSamples {
+		for i, line := range lines {
+			assert.Equal(t, format.Level(line), expected[format][i], "%s: %s", format, line)
+		}
+
+		assert.Equal(t, format.Level("  at main.go:45"), LogLevelUnknown)
+	}
+}
+
+func TestHighlightLog(t *testing.T) {
+	lines := logSamples[LogFormatKlog]
+	highlighted, err := HighlightLog(lines, LogFormatKlog, *styles.Get("native"), formatters.TTY16m)
+	assert.NilError(t, err)
+
+	highlightedLines := strings.Split(strings.TrimSuffix(*highlighted, "\n"), "\n")
+	assert.Equal(t, len(highlightedLines), len(lines))
+	for i, line := range highlightedLines {
+		assert.Assert(t, line != lines[i], "Line should have been colored: %s", line)
+		assert.Equal(t, textstyles.StripFormatting(line, linemetadata.Index{}), lines[i])
+	}
+}
+
+func TestHighlightLogFromMemory(t *testing.T) {
+	text := strings.Join(logSamples[LogFormatLogfmt], "\n") + "\n"
+	reader, err := NewFromStream("", strings.NewReader(text), formatters.TTY16m, ReaderOptions{Style: styles.Get("native")})
+	assert.NilError(t, err)
+	assert.NilError(t, reader.Wait())
+
+	assert.Equal(t, reader.GetLineCount(), 3)
+	line := reader.GetLine(linemetadata.Index{})
+	assert.Assert(t, strings.Contains(string(line.Line.raw), "\x1b["))
+	assert.Equal(t, line.Plain(), logSamples[LogFormatLogfmt][0])
+}
@@ -0,0 +1,52 @@
+package reader
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/styles"
+	"gotest.tools/v3/assert"
+
+	"github.com/walles/moor/v2/internal/linemetadata"
+)
+
+func TestMaxLines(t *testing.T) {
+	lines := []string{}
+	for i := range 100 {
+		lines = append(lines, fmt.Sprint("Line ", i+1))
+	}
+
+	reader, err := NewFromStream(
+		"",
+		strings.NewReader(strings.Join(lines, "\n")+"\n"),
+		formatters.TTY16m,
+		ReaderOptions{Style: styles.Get("native"), MaxLines: 10})
+	assert.NilError(t, err)
+	assert.NilError(t, reader.Wait())
+
+	// Dropped lines still count
+	assert.Equal(t, reader.GetLineCount(), 100)
+	assert.Equal(t, reader.DroppedLineCount(), 90)
+	assert.Equal(t, len(reader.lines), 10)
+
+	assert.Assert(t, reader.GetLine(linemetadata.Index{}) == nil)
+	assert.Assert(t, reader.GetLine(linemetadata.IndexFromZeroBased(89)) == nil)
+
+	first := reader.GetLine(linemetadata.IndexFromZeroBased(90))
+	assert.Equal(t, first.Plain(), "Line 91")
+	assert.Equal(t, first.Number, linemetadata.NumberFromOneBased(91))
+
+	// Asking for dropped lines gets us the first ones we still have
+	fromStart := reader.GetLines(linemetadata.Index{}, 5)
+	assert.Equal(t, len(fromStart.Lines), 5)
+	assert.Equal(t, fromStart.Lines[0].Index, linemetadata.IndexFromZeroBased(90))
+	assert.Equal(t, fromStart.Lines[0].Plain(), "Line 91")
+
+	// Asking for more than we have gets us the last ones
+	all := reader.GetLines(linemetadata.IndexFromZeroBased(95), 20)
+	assert.Equal(t, len(all.Lines), 10)
+	assert.Equal(t, all.Lines[9].Plain(), "Line 100")
+	assert.Equal(t, all.StatusText, "100 lines, first 90 dropped  100%")
+}
@@ -12,6 +12,16 @@ type NumberedLine struct {
 	Index  linemetadata.Index
 	Number linemetadata.Number
 	Line   *Line
+
+	// Set when filtering for lines shown only as context around matches
+	IsContext bool
+
+	// Set when filtering with context if lines were hidden right before this
+	// one, so that the groups can be told apart
+	FollowsGap bool
+
+	// Set while the line number is an estimate, see NewFromFileEnd()
+	NumberIsEstimate bool
 }
 
 func (nl *NumberedLine) Plain() string {
@@ -20,8 +30,12 @@ func (nl *NumberedLine) Plain() string {
 
 // minRunesCount: at least this many runes will be included in the result. If 0,
 // do all runes. For BenchmarkRenderHugeLine() performance.
-func (nl *NumberedLine) HighlightedTokens(plainTextStyle twin.Style, searchHitStyle twin.Style, search search.Search, minRunesCount int) textstyles.StyledRunesWithTrailer {
-	return nl.Line.HighlightedTokens(plainTextStyle, searchHitStyle, search, nl.Index, minRunesCount)
+func (nl *NumberedLine) HighlightedTokens(plainTextStyle twin.Style, searchHitStyle twin.Style, search search.Search, pinned []PinnedHighlight, minRunesCount int) textstyles.StyledRunesWithTrailer {
+	return nl.Line.HighlightedTokens(plainTextStyle, searchHitStyle, search, pinned, nl.Index, minRunesCount)
+}
+
+func (nl *NumberedLine) JSONHighlightedTokens(view JSONLinesView, styles JSONStyles, searchHitStyle twin.Style, search search.Search, pinned []PinnedHighlight) (textstyles.StyledRunesWithTrailer, bool) {
+	return nl.Line.JSONHighlightedTokens(view, styles, searchHitStyle, search, pinned, nl.Index)
 }
 
 func (nl *NumberedLine) DisplayWidth() int {
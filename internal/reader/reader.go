@@ -10,6 +10,7 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime/debug"
 	"slices"
 	"strings"
@@ -40,7 +41,7 @@ const DEFAULT_PAUSE_AFTER_LINES = 50_000
 var DisablePlainCachingForBenchmarking = false
 
 type ReaderOptions struct {
-	// Format JSON input
+	// Format JSON and XML input
 	ShouldFormat bool
 
 	// Pause after reading this many lines, unless told otherwise.
@@ -55,6 +56,14 @@ type ReaderOptions struct {
 
 	// If this is set, it will be used as the lexer for highlighting
 	Lexer chroma.Lexer
+
+	// Keep at most this many lines, dropping the oldest ones as new ones come
+	// in. 0 means no limit.
+	MaxLines int
+
+	// Run files through $LESSOPEN / $LESSCLOSE preprocessors if set, like
+	// less does. Only used by NewFromFilename().
+	LessOpen bool
 }
 
 type Reader interface {
@@ -97,6 +106,11 @@ type ReaderImpl struct {
 
 	lines []*Line
 
+	// How many of the first lines have been dropped to stay within maxLines.
+	// Line indices still count these, so lines[0] has this index.
+	droppedLines int
+	maxLines     int
+
 	// Display name for the buffer. If not set, no buffer name will be shown.
 	//
 	// For files, this will be the basename of the file. For our help text, this
@@ -111,6 +125,14 @@ type ReaderImpl struct {
 	// How many bytes have we read so far?
 	bytesCount int64
 
+	// The input was reformatted while reading, so our lines don't match the
+	// file contents
+	reformatted bool
+
+	// The input went through a $LESSOPEN preprocessor, so our lines don't
+	// match the file contents
+	preprocessed bool
+
 	endsWithNewline bool
 
 	Err error
@@ -139,8 +161,24 @@ type ReaderImpl struct {
 	pauseAfterLines        int
 	pauseAfterLinesUpdated chan bool
 
+	// Closed by Stop()
+	stopped  chan struct{}
+	stopOnce sync.Once
+
+	// Called by Stop(), for cleaning up after $LESSOPEN preprocessors
+	closeStream func() error
+
 	// PauseStatus is true if the reader is paused, false if it is not
 	PauseStatus *atomic.Bool
+
+	// Set for inputs too large to be highlighted all at once
+	lazyHighlighter *lazyHighlighter
+
+	// Set for files too large to keep in memory. Then lines is unused.
+	indexed *indexedLines
+
+	// Set for readers of only the end of a file
+	fileEnd *fileEnd
 }
 
 // InputLines contains a number of lines from the reader, plus metadata
@@ -155,6 +193,19 @@ type InputLines struct {
 // This is the reader's main function. It will be run in a goroutine. First it
 // reads the stream until the end, then starts tailing.
 func (reader *ReaderImpl) readStream(stream io.Reader, formatter chroma.Formatter, options ReaderOptions) {
+	if options.ShouldFormat {
+		var reformatting bool
+		stream, reformatting = reformatStream(stream, options.Lexer)
+		if reformatting {
+			reader.Lock()
+			reader.reformatted = true
+			reader.Unlock()
+
+			// Already taken care of
+			options.ShouldFormat = false
+		}
+	}
+
 	reader.consumeLinesFromStream(stream)
 
 	reader.ReadingDone.Store(true)
@@ -187,7 +238,7 @@ func (reader *ReaderImpl) readStream(stream io.Reader, formatter chroma.Formatte
 // pauseAfterLinesUpdated to be signalled in SetPauseAfterLines().
 func (reader *ReaderImpl) assumeLockAndMaybePause() {
 	for {
-		shouldPause := len(reader.lines) >= reader.pauseAfterLines
+		shouldPause := reader.lineCountLocked() >= reader.pauseAfterLines
 
 		if !shouldPause {
 			// Not there yet, no pause
@@ -197,9 +248,41 @@ func (reader *ReaderImpl) assumeLockAndMaybePause() {
 		// Release lock while pausing
 		reader.Unlock()
 		reader.setPauseStatus(true)
-		<-reader.pauseAfterLinesUpdated
+		select {
+		case <-reader.pauseAfterLinesUpdated:
+		case <-reader.stopped:
+		}
 		reader.setPauseStatus(false)
 		reader.Lock()
+
+		if reader.isStopped() {
+			// No more lines wanted
+			return
+		}
+	}
+}
+
+// Stop reading and tailing the input. Call this when the reader won't be shown
+// any more, so that it lets go of its file.
+func (reader *ReaderImpl) Stop() {
+	reader.stopOnce.Do(func() {
+		close(reader.stopped)
+
+		if reader.closeStream != nil {
+			err := reader.closeStream()
+			if err != nil {
+				log.Debug("Failed to close stopped input stream: ", err)
+			}
+		}
+	})
+}
+
+func (reader *ReaderImpl) isStopped() bool {
+	select {
+	case <-reader.stopped:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -219,6 +302,7 @@ func (reader *ReaderImpl) assumeLockAndAddLine(line []byte, considerAppending bo
 	if !considerAppending {
 		newLine := linePool.create(line)
 		reader.lines = append(reader.lines, newLine)
+		reader.assumeLockAndDropOldLines()
 
 		// New line added, time for a break?
 		t0 := time.Now()
@@ -242,12 +326,32 @@ func (reader *ReaderImpl) assumeLockAndAddLine(line []byte, considerAppending bo
 	return 0
 }
 
+// Assume write lock held. Drop the oldest lines if we have more than maxLines.
+func (reader *ReaderImpl) assumeLockAndDropOldLines() {
+	if reader.maxLines <= 0 || len(reader.lines) <= reader.maxLines {
+		return
+	}
+
+	dropCount := len(reader.lines) - reader.maxLines
+
+	// Let the dropped lines be garbage collected
+	clear(reader.lines[:dropCount])
+
+	reader.lines = reader.lines[dropCount:]
+	reader.droppedLines += dropCount
+}
+
 // This function will update the Reader struct. It is expected to run in a
 // goroutine.
 //
 // It is used both during the initial read of the stream until it ends, and
 // while tailing files for changes.
 func (reader *ReaderImpl) consumeLinesFromStream(stream io.Reader) {
+	if reader.indexed != nil {
+		reader.indexLinesFromStream(stream)
+		return
+	}
+
 	// This value affects BenchmarkReadLargeFile() performance. Validate changes
 	// like this:
 	//
@@ -259,7 +363,11 @@ func (reader *ReaderImpl) consumeLinesFromStream(stream io.Reader) {
 	// Preallocating the line pool and the lines slice improves large file
 	// reading performance by 10%.
 	linePool := linePool{}
-	if reader.FileName != nil && reader.GetLineCount() == 0 {
+	reader.RLock()
+	reformatted := reader.reformatted || reader.preprocessed
+	fromStart := reader.bytesCount == 0
+	reader.RUnlock()
+	if reader.FileName != nil && reader.GetLineCount() == 0 && !reformatted && fromStart && reader.maxLines == 0 {
 		lineCount, err := countLines(*reader.FileName)
 		if err != nil {
 			log.Warn("Failed to count lines in file: ", err)
@@ -323,7 +431,11 @@ func (reader *ReaderImpl) consumeLinesFromStream(stream io.Reader) {
 			t0 = t0.Add(pauseDuration)
 		}
 
-		reader.endsWithNewline = inspectionReader.endedWithNewline
+		if readBytes > 0 {
+			// Keep what we had for empty reads, like from files truncated to
+			// nothing after a marker line
+			reader.endsWithNewline = inspectionReader.endedWithNewline
+		}
 
 		reader.Unlock()
 
@@ -340,6 +452,11 @@ func (reader *ReaderImpl) consumeLinesFromStream(stream io.Reader) {
 			break
 		}
 
+		if reader.isStopped() {
+			log.Debug("Reader stopped, not reading any further")
+			break
+		}
+
 		if err != nil {
 			reader.Lock()
 			if reader.Err == nil {
@@ -353,7 +470,13 @@ func (reader *ReaderImpl) consumeLinesFromStream(stream io.Reader) {
 
 	if reader.FileName != nil {
 		reader.Lock()
-		reader.bytesCount += inspectionReader.bytesCount
+		if reader.reformatted || reader.preprocessed {
+			// Our byte count is for the reformatted or preprocessed input,
+			// which doesn't help when tailing the file
+			reader.bytesCount = -1
+		} else {
+			reader.bytesCount += inspectionReader.bytesCount
+		}
 		reader.Unlock()
 	}
 
@@ -370,82 +493,6 @@ func (reader *ReaderImpl) consumeLinesFromStream(stream io.Reader) {
 	log.Info("Stream read in ", time.Since(t0), ", have ", reader.GetLineCount(), " lines")
 }
 
-func (reader *ReaderImpl) tailFile() error {
-	reader.RLock()
-	fileName := reader.FileName
-	reader.RUnlock()
-	if fileName == nil {
-		return nil
-	}
-
-	log.Debugf("Tailing file %s", *fileName)
-
-	for {
-		// NOTE: We could use something like
-		// https://github.com/fsnotify/fsnotify instead of sleeping and polling
-		// here.
-		time.Sleep(1 * time.Second)
-
-		fileStats, err := os.Stat(*fileName)
-		if err != nil {
-			log.Debugf("Failed to stat file %s while tailing, giving up: %s", *fileName, err.Error())
-			return nil
-		}
-
-		reader.RLock()
-		bytesCount := reader.bytesCount
-		reader.RUnlock()
-
-		if bytesCount == -1 {
-			log.Debugf("Bytes count unknown for %s, stop tailing", *fileName)
-			return nil
-		}
-
-		if fileStats.Size() == bytesCount {
-			log.Tracef("File %s unchanged at %d bytes, continue tailing", *fileName, fileStats.Size())
-			continue
-		}
-
-		if fileStats.Size() < bytesCount {
-			log.Debugf("File %s shrunk from %d to %d bytes, stop tailing",
-				*fileName, bytesCount, fileStats.Size())
-			return nil
-		}
-
-		// File grew, read the new lines
-		stream, _, err := ZOpen(*fileName)
-		if err != nil {
-			log.Debugf("Failed to open file %s for re-reading while tailing: %s", *fileName, err.Error())
-			return nil
-		}
-
-		seekable, ok := stream.(io.ReadSeekCloser)
-		if !ok {
-			err = stream.Close()
-			if err != nil {
-				log.Debugf("Giving up on tailing, failed to close non-seekable stream from %s: %s", *fileName, err.Error())
-				return nil
-			}
-			log.Debugf("Giving up on tailing, file %s is not seekable", *fileName)
-			return nil
-		}
-		_, err = seekable.Seek(bytesCount, io.SeekStart)
-		if err != nil {
-			log.Debugf("Failed to seek in file %s while tailing: %s", *fileName, err.Error())
-			return nil
-		}
-
-		log.Tracef("File %s up from %d bytes to %d bytes, reading more lines...", *fileName, bytesCount, fileStats.Size())
-
-		reader.consumeLinesFromStream(seekable)
-		err = seekable.Close()
-		if err != nil {
-			// This can lead to file handle leaks
-			return fmt.Errorf("failed to close file %s after tailing: %w", *fileName, err)
-		}
-	}
-}
-
 // NewFromStream creates a new stream reader
 //
 // The display name can be an empty string ("").
@@ -490,6 +537,25 @@ func NewFromStream(displayName string, reader io.Reader, formatter chroma.Format
 // Note that you must call reader.SetStyleForHighlighting() after this to get
 // highlighting.
 func newReaderFromStream(reader io.Reader, originalFileName *string, formatter chroma.Formatter, options ReaderOptions) *ReaderImpl {
+	returnMe := newReaderImpl(originalFileName, options)
+
+	if originalFileName != nil && shouldIndex(reader, options) {
+		indexed, err := newIndexedLines(*originalFileName)
+		if err != nil {
+			log.Warn("Failed to index ", *originalFileName, ", reading it into memory: ", err)
+		} else {
+			log.Info("Large file, indexing rather than reading into memory: ", *originalFileName)
+			returnMe.indexed = indexed
+		}
+	}
+
+	returnMe.startReading(reader, formatter, options)
+
+	return returnMe
+}
+
+// Create a reader that hasn't started reading yet, see startReading()
+func newReaderImpl(originalFileName *string, options ReaderOptions) *ReaderImpl {
 	readingDone := atomic.Bool{}
 	readingDone.Store(false)
 	highlightingDone := atomic.Bool{}
@@ -511,6 +577,9 @@ func newReaderFromStream(reader io.Reader, originalFileName *string, formatter c
 
 		pauseAfterLines:        pauseAfterLines,
 		pauseAfterLinesUpdated: make(chan bool, 1),
+		stopped:                make(chan struct{}),
+
+		maxLines: options.MaxLines,
 
 		PauseStatus: &pauseStatus,
 
@@ -522,15 +591,17 @@ func newReaderFromStream(reader io.Reader, originalFileName *string, formatter c
 		ReadingDone:             &readingDone,
 	}
 
+	return &returnMe
+}
+
+func (reader *ReaderImpl) startReading(stream io.Reader, formatter chroma.Formatter, options ReaderOptions) {
 	go func() {
 		defer func() {
 			PanicHandler("newReaderFromStream()/readStream()", recover(), debug.Stack())
 		}()
 
-		returnMe.readStream(reader, formatter, options)
+		reader.readStream(stream, formatter, options)
 	}()
-
-	return &returnMe
 }
 
 // Testing only!! May or may not hang if run in real world scenarios.
@@ -560,6 +631,7 @@ func NewFromTextForTesting(name string, text string) *ReaderImpl {
 		ReadingDone:             &readingDone,
 		HighlightingDone:        &highlightingDone,
 		doneWaitingForFirstByte: make(chan bool, 1),
+		stopped:                 make(chan struct{}),
 	}
 	if name != "" {
 		returnMe.DisplayName = &name
@@ -596,9 +668,6 @@ func TryOpen(filename string) error {
 
 // From: https://stackoverflow.com/a/52153000/473672
 func countLines(filename string) (uint64, error) {
-	const lineBreak = '\n'
-	sliceWithSingleLineBreak := []byte{lineBreak}
-
 	reader, _, err := ZOpen(filename)
 	if err != nil {
 		return 0, err
@@ -610,8 +679,26 @@ func countLines(filename string) (uint64, error) {
 		}
 	}()
 
-	var count uint64
 	t0 := time.Now()
+	count, err := countLinesInStream(reader)
+	if err != nil {
+		return 0, err
+	}
+
+	t1 := time.Now()
+	if count == 0 {
+		log.Debug("Counted ", count, " lines in ", t1.Sub(t0))
+	} else {
+		log.Debug("Counted ", count, " lines in ", t1.Sub(t0), " at ", t1.Sub(t0)/time.Duration(count), "/line")
+	}
+	return count, nil
+}
+
+func countLinesInStream(reader io.Reader) (uint64, error) {
+	const lineBreak = '\n'
+	sliceWithSingleLineBreak := []byte{lineBreak}
+
+	var count uint64
 	buf := make([]byte, bufio.MaxScanTokenSize)
 	lastReadEndsInNewline := true
 	for {
@@ -635,12 +722,6 @@ func countLines(filename string) (uint64, error) {
 		count++
 	}
 
-	t1 := time.Now()
-	if count == 0 {
-		log.Debug("Counted ", count, " lines in ", t1.Sub(t0))
-	} else {
-		log.Debug("Counted ", count, " lines in ", t1.Sub(t0), " at ", t1.Sub(t0)/time.Duration(count), "/line")
-	}
 	return count, nil
 }
 
@@ -655,6 +736,14 @@ func countLines(filename string) (uint64, error) {
 // apply highlighting to the file using Chroma:
 // https://github.com/alecthomas/chroma
 func NewFromFilename(filename string, formatter chroma.Formatter, options ReaderOptions) (*ReaderImpl, error) {
+	if options.LessOpen {
+		// Before checking the file, since preprocessors can handle things
+		// like directories
+		if preprocessed := lessOpen(filename); preprocessed != nil {
+			return newPreprocessedReader(filename, preprocessed, formatter, options)
+		}
+	}
+
 	fileError := TryOpen(filename)
 	if fileError != nil {
 		return nil, fileError
@@ -700,37 +789,17 @@ func (reader *ReaderImpl) Wait() error {
 	return reader.Err
 }
 
-func textAsString(reader *ReaderImpl, shouldFormat bool) string {
+func textAsString(reader *ReaderImpl) string {
 	reader.RLock()
+	defer reader.RUnlock()
 
 	text := []byte{}
 	for _, line := range reader.lines {
 		text = append(text, line.raw...)
 		text = append(text, '\n')
 	}
-	reader.RUnlock()
-
-	var jsonData any
-	err := json.Unmarshal(text, &jsonData)
-	if err != nil {
-		// Not JSON, return the text as-is
-		return string(text)
-	}
 
-	if !shouldFormat {
-		log.Info("Try the --reformat flag for automatic JSON reformatting")
-		return string(text)
-	}
-
-	// Pretty print the JSON
-	prettyJSON, err := json.MarshalIndent(jsonData, "", "  ")
-	if err != nil {
-		log.Debug("Failed to pretty print JSON: ", err)
-		return string(text)
-	}
-
-	log.Debug("Got the --reformat flag, reformatted JSON input")
-	return string(prettyJSON)
+	return string(text)
 }
 
 func isXml(text string) bool {
@@ -738,23 +807,42 @@ func isXml(text string) bool {
 	return err == nil
 }
 
+var htmlStartRegex = regexp.MustCompile(`(?i)^\s*(<!--.*?-->\s*)*<(!doctype html|html)[\s>]`)
+
+func isHtml(text string) bool {
+	return htmlStartRegex.MatchString(text)
+}
+
+func isReformatted(reader *ReaderImpl) bool {
+	reader.RLock()
+	defer reader.RUnlock()
+	return reader.reformatted
+}
+
 // We expect this to be executed in a goroutine
 func highlightFromMemory(reader *ReaderImpl, formatter chroma.Formatter, options ReaderOptions) {
 	// Is the buffer small enough?
+	if reader.indexed != nil {
+		// Too large for memory, and thus for highlighting all at once
+		startLazyHighlighting(reader, formatter, options)
+		return
+	}
+
 	var byteCount int64
 	reader.RLock()
 	for _, line := range reader.lines {
 		byteCount += int64(len(line.raw))
 
 		if byteCount > MAX_HIGHLIGHT_SIZE {
-			log.Info("File too large for highlighting: ", byteCount)
+			log.Info("File too large for highlighting all at once, highlighting lazily: ", byteCount)
 			reader.RUnlock()
+			startLazyHighlighting(reader, formatter, options)
 			return
 		}
 	}
 	reader.RUnlock()
 
-	text := textAsString(reader, options.ShouldFormat)
+	text := textAsString(reader)
 
 	if len(text) == 0 {
 		log.Debug("Buffer is empty, not highlighting")
@@ -764,14 +852,31 @@ func highlightFromMemory(reader *ReaderImpl, formatter chroma.Formatter, options
 	if options.Lexer == nil && json.Valid([]byte(text)) {
 		log.Info("Buffer is valid JSON, highlighting as JSON")
 		options.Lexer = lexers.Get("json")
+	} else if options.Lexer == nil && isHtml(text) {
+		log.Info("Buffer looks like HTML, highlighting as HTML")
+		options.Lexer = lexers.Get("html")
 	} else if options.Lexer == nil && isXml(text) {
 		log.Info("Buffer is valid XML, highlighting as XML")
 		options.Lexer = lexers.Get("xml")
 	}
 
-	if options.Lexer == nil {
-		log.Debug("No lexer set, not highlighting")
-		return
+	if options.ShouldFormat {
+		reformatted := reformatText(text, options.Lexer)
+		if reformatted != nil && *reformatted != text {
+			text = *reformatted
+
+			reader.Lock()
+			reader.reformatted = true
+
+			// Tailing would add unformatted lines after the reformatted ones
+			reader.bytesCount = -1
+			reader.Unlock()
+
+			// Show the reformatted text even if we don't get to highlight it
+			reader.setText(text)
+		}
+	} else if canReformat(options.Lexer) && !isReformatted(reader) {
+		log.Info("Try the --reformat flag for automatic ", options.Lexer.Config().Name, " reformatting")
 	}
 
 	if options.Style == nil {
@@ -784,7 +889,24 @@ func highlightFromMemory(reader *ReaderImpl, formatter chroma.Formatter, options
 		return
 	}
 
-	highlighted, err := Highlight(text, *options.Style, formatter, options.Lexer)
+	var highlighted *string
+	var err error
+	if options.Lexer != nil {
+		highlighted, err = Highlight(text, *options.Style, formatter, options.Lexer)
+	} else if strings.Contains(text, "\x1b") {
+		log.Debug("No lexer set and input already has formatting, not highlighting")
+		return
+	} else {
+		lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+		logFormat := DetectLogFormat(lines)
+		if logFormat == LogFormatNone {
+			log.Debug("No lexer set, not highlighting")
+			return
+		}
+
+		log.Info("Input looks like a log in ", logFormat, " format, highlighting timestamps and levels")
+		highlighted, err = HighlightLog(lines, logFormat, *options.Style, formatter)
+	}
 	if err != nil {
 		log.Warn("Highlighting failed: ", err)
 		return
@@ -805,7 +927,8 @@ func (reader *ReaderImpl) createStatusUnlocked(lastLine linemetadata.Index) (str
 		displayName = *reader.DisplayName
 	}
 
-	if len(reader.lines) == 0 {
+	lineCount := reader.lineCountLocked()
+	if lineCount == 0 {
 		empty := "<empty>"
 		if len(displayName) > 0 {
 			return displayName, ": " + empty
@@ -813,15 +936,26 @@ func (reader *ReaderImpl) createStatusUnlocked(lastLine linemetadata.Index) (str
 		return "", empty
 	}
 
+	// Readers of file ends have lines before their first one
+	linesBefore, exact := reader.linesBeforeLocked()
+	totalCount := linesBefore + lineCount
+	approximately := ""
+	if !exact {
+		approximately = "~"
+	}
+
 	linesCount := ""
 	percent := ""
-	if len(reader.lines) == 1 {
+	if totalCount == 1 {
 		linesCount = "1 line"
 		percent = "100%"
 	} else {
 		// More than one line
-		linesCount = util.FormatInt(len(reader.lines)) + " lines"
-		percent = fmt.Sprintf("%.0f%%", math.Floor(100*float64(lastLine.Index()+1)/float64(len(reader.lines))))
+		linesCount = approximately + util.FormatInt(totalCount) + " lines"
+		percent = fmt.Sprintf("%.0f%%", math.Floor(100*float64(linesBefore+lastLine.Index()+1)/float64(totalCount)))
+	}
+	if reader.droppedLines > 0 {
+		linesCount += ", first " + util.FormatInt(reader.droppedLines) + " dropped"
 	}
 
 	if !reader.ShouldShowLineCount() {
@@ -864,7 +998,69 @@ func (reader *ReaderImpl) GetLineCount() int {
 	reader.RLock()
 	defer reader.RUnlock()
 
-	return len(reader.lines)
+	return reader.lineCountLocked()
+}
+
+// GetError returns the error that stopped reading, if any
+func (reader *ReaderImpl) GetError() error {
+	reader.RLock()
+	defer reader.RUnlock()
+
+	return reader.Err
+}
+
+// DroppedLineCount returns how many of the first lines have been dropped
+// because of ReaderOptions.MaxLines. These lines can't be gotten any more, but
+// their indices are still in use.
+func (reader *ReaderImpl) DroppedLineCount() int {
+	reader.RLock()
+	defer reader.RUnlock()
+
+	return reader.droppedLines
+}
+
+// Assume lock held. Includes any dropped lines.
+func (reader *ReaderImpl) lineCountLocked() int {
+	if reader.indexed != nil {
+		return len(reader.indexed.starts)
+	}
+
+	return reader.droppedLines + len(reader.lines)
+}
+
+// Assume lock held. The index must be within bounds, and not be dropped.
+func (reader *ReaderImpl) lineLocked(index int) *Line {
+	if reader.indexed != nil {
+		return reader.indexed.line(index, reader.bytesCount)
+	}
+
+	return reader.lines[index-reader.droppedLines]
+}
+
+// Assume lock held. Returns lines first to last, last exclusive. None of them
+// can have been dropped.
+func (reader *ReaderImpl) linesLocked(first int, last int) []*Line {
+	if reader.indexed != nil {
+		return reader.indexed.lines(first, last, reader.bytesCount)
+	}
+
+	return reader.lines[first-reader.droppedLines : last-reader.droppedLines]
+}
+
+// Assume write lock held. Replace a line, unless it has already been replaced
+// by something else. Returns false if it had.
+func (reader *ReaderImpl) replaceLineLocked(index int, original *Line, replacement *Line) bool {
+	if reader.indexed != nil {
+		return reader.indexed.replace(index, original, replacement)
+	}
+
+	index -= reader.droppedLines
+	if index < 0 || index >= len(reader.lines) || reader.lines[index] != original {
+		return false
+	}
+
+	reader.lines[index] = replacement
+	return true
 }
 
 func (reader *ReaderImpl) ShouldShowLineCount() bool {
@@ -929,18 +1125,20 @@ func (reader *ReaderImpl) GetLine(index linemetadata.Index) *NumberedLine {
 		reader.RLock()
 	}
 
-	if !index.IsWithinLength(len(reader.lines)) {
+	if !index.IsWithinLength(reader.lineCountLocked()) || index.Index() < reader.droppedLines {
 		reader.RUnlock()
 		return nil
 	}
 
-	returnLine := reader.lines[index.Index()]
+	returnLine := reader.lineLocked(index.Index())
+	linesBefore, exact := reader.linesBeforeLocked()
 	reader.RUnlock()
 
 	return &NumberedLine{
-		Index:  index,
-		Number: linemetadata.NumberFromZeroBased(index.Index()),
-		Line:   returnLine,
+		Index:            index,
+		Number:           linemetadata.NumberFromZeroBased(linesBefore + index.Index()),
+		Line:             returnLine,
+		NumberIsEstimate: !exact,
 	}
 }
 
@@ -983,7 +1181,7 @@ func clipRangeToLength(start linemetadata.Index, wantedCount int, maxIndex int)
 // GetLines gets the indicated lines from the input
 func (reader *ReaderImpl) GetLines(firstLine linemetadata.Index, wantedLineCount int) InputLines {
 	reader.RLock()
-	lineCount := len(reader.lines)
+	lineCount := reader.lineCountLocked()
 	if lineCount == 0 || wantedLineCount == 0 {
 		filenameText, statusText := reader.createStatusUnlocked(firstLine)
 		reader.RUnlock()
@@ -1019,23 +1217,32 @@ func (reader *ReaderImpl) GetLinesPreallocated(firstLine linemetadata.Index, res
 
 	reader.RLock()
 
-	if len(reader.lines) == 0 || cap(*resultLines) == 0 {
+	if reader.lineCountLocked() == 0 || cap(*resultLines) == 0 {
 		filenameText, statusText := reader.createStatusUnlocked(firstLine)
 		reader.RUnlock()
 
 		return filenameText, statusText
 	}
 
-	// Prevent reading past the end of the available lines
-	firstLineIndex, lastLineIndex := clipRangeToLength(firstLine, cap(*resultLines), len(reader.lines)-1)
+	// Prevent reading past the end of the available lines, or before the
+	// first one we still have
+	dropped := reader.droppedLines
+	firstLineIndex, lastLineIndex := clipRangeToLength(
+		linemetadata.IndexFromZeroBased(max(firstLine.Index()-dropped, 0)),
+		cap(*resultLines),
+		reader.lineCountLocked()-dropped-1)
+	firstLineIndex += dropped
+	lastLineIndex += dropped
 
 	filenameText, statusText := reader.createStatusUnlocked(linemetadata.IndexFromZeroBased(lastLineIndex))
 
-	for loopIndex, returnLine := range reader.lines[firstLineIndex : lastLineIndex+1] {
+	linesBefore, exact := reader.linesBeforeLocked()
+	for loopIndex, returnLine := range reader.linesLocked(firstLineIndex, lastLineIndex+1) {
 		*resultLines = append(*resultLines, NumberedLine{
-			Index:  linemetadata.IndexFromZeroBased(firstLineIndex + loopIndex),
-			Number: linemetadata.NumberFromZeroBased(firstLineIndex + loopIndex),
-			Line:   returnLine,
+			Index:            linemetadata.IndexFromZeroBased(firstLineIndex + loopIndex),
+			Number:           linemetadata.NumberFromZeroBased(linesBefore + firstLineIndex + loopIndex),
+			Line:             returnLine,
+			NumberIsEstimate: !exact,
 		})
 	}
 
@@ -0,0 +1,394 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"runtime/debug"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// Reformatting reads the input in chunks of this size, and decides whether to
+// reformat by looking at the first chunk
+const reformatChunkSize = 64 * 1024
+
+// If the input looks like a JSON or XML document, return a reader that pretty prints it
+// while it streams through, and true. Otherwise return a reader with the
+// unchanged input, and false. Either way, read from the returned reader, since
+// we may have read from the original one while sniffing.
+//
+// If we have a lexer, it has to be for JSON or XML. Other formats are
+// reformatted in memory after reading, by reformatText().
+//
+// Since nothing is held in memory, this works for inputs of any size.
+func reformatStream(stream io.Reader, lexer chroma.Lexer) (io.Reader, bool) {
+	buffered := bufio.NewReaderSize(stream, reformatChunkSize)
+
+	// Peek at whatever the first read gives us, without waiting for more. For
+	// slow streams, waiting for a full chunk could take forever.
+	_, _ = buffered.Peek(1)
+	sniff, _ := buffered.Peek(buffered.Buffered())
+
+	lexerName := ""
+	if lexer != nil {
+		lexerName = lexer.Config().Name
+	}
+
+	var indent func(source io.Reader, destination io.Writer) error
+	trimmed := bytes.TrimLeft(sniff, " \t\r\n")
+	if (lexerName == "" || lexerName == "JSON") && bytes.IndexAny(trimmed, "{[") == 0 && isJSONDocumentPrefix(trimmed) {
+		log.Info("Input looks like JSON, reformatting while reading")
+		indent = indentJSON
+	} else if (lexerName == "" || lexerName == "XML") && bytes.HasPrefix(trimmed, []byte("<")) && !isHtml(string(trimmed)) && isXMLPrefix(trimmed) {
+		log.Info("Input looks like XML, reformatting while reading")
+		indent = indentXML
+	}
+
+	if indent == nil {
+		return buffered, false
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		defer func() {
+			PanicHandler("reformatStream()", recover(), debug.Stack())
+		}()
+
+		err := indent(buffered, pipeWriter)
+		if err != nil {
+			log.Info("Reformatting stopped: ", err)
+		}
+
+		// Never mind the reformatting error, show the user what we have
+		_ = pipeWriter.Close()
+	}()
+
+	return pipeReader, true
+}
+
+// Flushes the output whenever the input buffer runs dry, so that everything we
+// have is visible before we wait for more input.
+//
+// Being an io.ByteReader, this also stops xml.Decoder from doing its own
+// buffering. That way we know exactly how far it got if it fails.
+type flushingReader struct {
+	source *bufio.Reader
+	output *bufio.Writer
+}
+
+func (r flushingReader) Read(p []byte) (int, error) {
+	if r.source.Buffered() == 0 {
+		if err := r.output.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	return r.source.Read(p)
+}
+
+func (r flushingReader) ReadByte() (byte, error) {
+	if r.source.Buffered() == 0 {
+		if err := r.output.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	return r.source.ReadByte()
+}
+
+// True if the text is the beginning of a single JSON object or array. It
+// doesn't have to be complete.
+//
+// JSON Lines input, with one value per line, is not a document. Those lines are
+// better off staying the way they are.
+func isJSONDocumentPrefix(text []byte) bool {
+	decoder := json.NewDecoder(bytes.NewReader(text))
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+		}
+
+		if depth == 0 && token != json.Delim('{') && token != json.Delim('[') {
+			// A second top level value, or one that isn't an object or array
+			return false
+		}
+
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+			if depth == 0 {
+				// The document is done, anything but whitespace after it means
+				// this is something else
+				return !decoder.More()
+			}
+		}
+	}
+}
+
+// True if the text is the beginning of an XML document. It doesn't have to be
+// complete.
+func isXMLPrefix(text []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(text))
+	sawElement := false
+	for {
+		token, err := decoder.RawToken()
+		if err == nil {
+			if _, isStart := token.(xml.StartElement); isStart {
+				sawElement = true
+			}
+			continue
+		}
+
+		if errors.Is(err, io.EOF) {
+			return sawElement
+		}
+
+		var syntaxError *xml.SyntaxError
+		if errors.As(err, &syntaxError) && syntaxError.Msg == "unexpected EOF" {
+			// Truncated, fine
+			return true
+		}
+
+		return false
+	}
+}
+
+// Like json.Indent() with two spaces, but streaming and keeping the key order.
+// Top level values end up on separate lines, so JSON Lines input gets each
+// record pretty printed.
+//
+// Strings and numbers are written exactly as they were in the input. If the
+// input turns out not to be JSON after all, the rest of it is passed through
+// unchanged.
+func indentJSON(source io.Reader, destination io.Writer) error {
+	output := bufio.NewWriter(destination)
+	defer func() {
+		_ = output.Flush()
+	}()
+
+	bufferedSource, ok := source.(*bufio.Reader)
+	if !ok {
+		bufferedSource = bufio.NewReader(source)
+	}
+	recorder := &recordingReader{source: flushingReader{source: bufferedSource, output: output}}
+	decoder := json.NewDecoder(recorder)
+
+	// For each object or array we're in, how many values it has so far. For
+	// objects, the keys are counted.
+	counts := []int{}
+	objects := []bool{}
+	afterKey := false
+
+	newline := func() {
+		_ = output.WriteByte('\n')
+		for range counts {
+			_, _ = output.WriteString("  ")
+		}
+	}
+
+	written := int64(0)
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			_ = output.WriteByte('\n')
+			_, _ = output.Write(recorder.take(written, recorder.end()))
+			_, _ = io.Copy(output, bufferedSource)
+			return err
+		}
+
+		offset := decoder.InputOffset()
+		raw := bytes.TrimLeft(recorder.take(written, offset), " \t\r\n,:")
+		written = offset
+
+		if token == json.Delim('}') || token == json.Delim(']') {
+			count := counts[len(counts)-1]
+			counts = counts[:len(counts)-1]
+			objects = objects[:len(objects)-1]
+			if count > 0 {
+				newline()
+			}
+			_, _ = output.Write(raw)
+			if len(counts) == 0 {
+				_ = output.WriteByte('\n')
+			}
+			continue
+		}
+
+		isKey := false
+		if afterKey {
+			_, _ = output.WriteString(": ")
+		} else if len(counts) > 0 {
+			// A new value in an array, or a new key in an object
+			if counts[len(counts)-1] > 0 {
+				_ = output.WriteByte(',')
+			}
+			counts[len(counts)-1]++
+			newline()
+			isKey = objects[len(objects)-1]
+		}
+		afterKey = isKey
+
+		_, _ = output.Write(raw)
+		if isKey {
+			continue
+		}
+
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			counts = append(counts, 0)
+			objects = append(objects, token == json.Delim('{'))
+			continue
+		}
+
+		if len(counts) == 0 {
+			// Top level scalar
+			_ = output.WriteByte('\n')
+		}
+	}
+}
+
+// Remembers what has been read through it, so that we can get the raw input
+// for each JSON token
+type recordingReader struct {
+	source io.Reader
+
+	recorded []byte
+
+	// Input offset of the first recorded byte
+	start int64
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	count, err := r.source.Read(p)
+	r.recorded = append(r.recorded, p[:count]...)
+	return count, err
+}
+
+// Input offset of the end of what has been read so far
+func (r *recordingReader) end() int64 {
+	return r.start + int64(len(r.recorded))
+}
+
+// Returns the input from one offset to another, and forgets everything before
+// the second one. The returned bytes are valid until the next Read().
+func (r *recordingReader) take(from int64, to int64) []byte {
+	taken := r.recorded[from-r.start : to-r.start]
+	r.recorded = r.recorded[to-r.start:]
+	r.start = to
+	return taken
+}
+
+// Pretty print XML with two space indentation. Elements containing only text
+// stay on one line.
+//
+// If the input turns out not to be XML after all, the rest of it is passed
+// through unchanged.
+func indentXML(source io.Reader, destination io.Writer) error {
+	output := bufio.NewWriter(destination)
+	defer func() {
+		_ = output.Flush()
+	}()
+
+	bufferedSource, ok := source.(*bufio.Reader)
+	if !ok {
+		bufferedSource = bufio.NewReader(source)
+	}
+	decoder := xml.NewDecoder(flushingReader{source: bufferedSource, output: output})
+	decoder.Strict = false
+
+	depth := 0
+	lastWasStart := false
+	lastWasText := false
+	startOfOutput := true
+	newline := func() {
+		if !startOfOutput {
+			_ = output.WriteByte('\n')
+		}
+		startOfOutput = false
+		for range depth {
+			_, _ = output.WriteString("  ")
+		}
+	}
+
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			_ = output.WriteByte('\n')
+			return nil
+		}
+		if err != nil {
+			_ = output.WriteByte('\n')
+			_, _ = io.Copy(output, bufferedSource)
+			return err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			newline()
+			_, _ = output.WriteString("<" + xmlName(token.Name))
+			for _, attr := range token.Attr {
+				_, _ = output.WriteString(" " + xmlName(attr.Name) + `="`)
+				_ = xml.EscapeText(output, []byte(attr.Value))
+				_ = output.WriteByte('"')
+			}
+			_ = output.WriteByte('>')
+			depth++
+			lastWasStart = true
+			lastWasText = false
+			continue
+
+		case xml.EndElement:
+			depth = max(depth-1, 0)
+			if !lastWasStart && !lastWasText {
+				newline()
+			}
+			_, _ = output.WriteString("</" + xmlName(token.Name) + ">")
+
+		case xml.CharData:
+			text := strings.TrimSpace(string(token))
+			if text == "" {
+				continue
+			}
+			if !lastWasStart {
+				newline()
+			}
+			_ = xml.EscapeText(output, []byte(text))
+			lastWasStart = false
+			lastWasText = true
+			continue
+
+		case xml.Comment:
+			newline()
+			_, _ = output.WriteString("<!--" + string(token) + "-->")
+
+		case xml.ProcInst:
+			newline()
+			_, _ = output.WriteString("<?" + strings.TrimSpace(token.Target+" "+string(token.Inst)) + "?>")
+
+		case xml.Directive:
+			newline()
+			_, _ = output.WriteString("<!" + string(token) + ">")
+		}
+
+		lastWasStart = false
+		lastWasText = false
+	}
+}
+
+func xmlName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
@@ -0,0 +1,128 @@
+package reader
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"gotest.tools/v3/assert"
+)
+
+func reformatForTesting(t *testing.T, input string) (string, bool) {
+	reformatted, didReformat := reformatStream(strings.NewReader(input), nil)
+	output, err := io.ReadAll(reformatted)
+	assert.NilError(t, err)
+	return string(output), didReformat
+}
+
+func TestReformatJSON(t *testing.T) {
+	output, didReformat := reformatForTesting(t, `{"b" : 1,"a":[ ],"c":{"d":[1,"2"]},"e":"x, {y} \"z\""}`)
+	assert.Assert(t, didReformat)
+	assert.Equal(t, output, strings.Join([]string{
+		`{`,
+		`  "b": 1,`,
+		`  "a": [],`,
+		`  "c": {`,
+		`    "d": [`,
+		`      1,`,
+		`      "2"`,
+		`    ]`,
+		`  },`,
+		`  "e": "x, {y} \"z\""`,
+		`}`,
+		``,
+	}, "\n"))
+}
+
+func TestReformatXML(t *testing.T) {
+	output, didReformat := reformatForTesting(t, `<?xml version="1.0"?><a x="1&amp;2"><b> text </b><c/><!-- note --></a>`)
+	assert.Assert(t, didReformat)
+	assert.Equal(t, output, strings.Join([]string{
+		`<?xml version="1.0"?>`,
+		`<a x="1&amp;2">`,
+		`  <b>text</b>`,
+		`  <c></c>`,
+		`  <!-- note -->`,
+		`</a>`,
+		``,
+	}, "\n"))
+}
+
+func TestReformatJSONNotJSONAfterAll(t *testing.T) {
+	// Too far in for the sniffing to notice
+	output := strings.Builder{}
+	err := indentJSON(strings.NewReader("{\"a\": [1]}\n{\"b\": not JSON }\nmore text\n"), &output)
+	assert.Assert(t, err != nil)
+
+	// Everything after the problem should still be there, unchanged
+	assert.Equal(t, output.String(), "{\n  \"a\": [\n    1\n  ]\n}\n{\n  \"b\"\n: not JSON }\nmore text\n")
+}
+
+func TestReformatXMLNotXMLAfterAll(t *testing.T) {
+	// Too far in for the sniffing to notice
+	output := strings.Builder{}
+	err := indentXML(strings.NewReader("<a>\n<b>hello</b>\n</a>\n<<< not XML >>>\nmore text\n"), &output)
+	assert.Assert(t, err != nil)
+
+	// Everything after the problem should still be there
+	assert.Assert(t, strings.HasPrefix(output.String(), "<a>\n  <b>hello</b>\n</a>\n"), output.String())
+	assert.Assert(t, strings.HasSuffix(output.String(), " not XML >>>\nmore text\n"), output.String())
+}
+
+func TestReformatOnlyDocuments(t *testing.T) {
+	for _, input := range []string{
+		"[INFO] This is a log line\n[INFO] And another one\n",
+		`{"msg": "JSON Lines"}` + "\n" + `{"msg": "stay as they are"}` + "\n",
+		"5\n",
+		"<- not XML\n",
+		"",
+	} {
+		output, didReformat := reformatForTesting(t, input)
+		assert.Assert(t, !didReformat, input)
+		assert.Equal(t, output, input)
+	}
+}
+
+func TestReformatLargeJSON(t *testing.T) {
+	// Large enough to not be highlighted all at once
+	items := []string{}
+	for i := range 100_000 {
+		items = append(items, `{"index":`+strconv.Itoa(i)+`,"value":"some text to make this take some space"}`)
+	}
+	jsonText := "[" + strings.Join(items, ",") + "]"
+	assert.Assert(t, int64(len(jsonText)) > MAX_HIGHLIGHT_SIZE)
+
+	testMe, err := NewFromStream(
+		"Large JSON test",
+		strings.NewReader(jsonText),
+		formatters.TTY16m,
+		ReaderOptions{
+			Style:        styles.Get("native"),
+			ShouldFormat: true,
+		})
+	assert.NilError(t, err)
+	assert.NilError(t, testMe.Wait())
+
+	// Four lines per item, plus the brackets around the array
+	assert.Equal(t, testMe.GetLineCount(), 100_000*4+2)
+	assert.Equal(t, testMe.GetLine(linemetadata.IndexFromZeroBased(1)).Plain(), "  {")
+	assert.Equal(t, testMe.GetLine(linemetadata.IndexFromZeroBased(2)).Plain(), `    "index": 0,`)
+
+	// Nothing highlighted until the lines are shown
+	lastNumber := linemetadata.NumberFromZeroBased(testMe.GetLineCount() - 3)
+	lastIndex := linemetadata.IndexFromZeroBased(lastNumber.AsZeroBased())
+	lastLine := testMe.GetLine(lastIndex)
+	assert.Equal(t, lastLine.Plain(), `    "value": "some text to make this take some space"`)
+	assert.Assert(t, !strings.Contains(string(lastLine.Line.raw), "\x1b["))
+
+	testMe.HighlightLazily(lastNumber, lastNumber)
+	for !strings.Contains(string(testMe.GetLine(lastIndex).Line.raw), "\x1b[") {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, testMe.GetLine(lastIndex).Plain(), lastLine.Plain())
+}
@@ -0,0 +1,626 @@
+package reader
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/rivo/uniseg"
+	log "github.com/sirupsen/logrus"
+)
+
+// Don't pad CSV columns wider than this, or one long value would push all
+// other columns far to the right
+const csvMaxColumnWidth = 40
+
+// Reformatters by lexer name
+var reformatters = map[string]func(text string) *string{
+	"JSON": reformatJSON,
+	"XML":  reformatXML,
+	"HTML": reformatHTML,
+	"YAML": reformatYAML,
+	"TOML": reformatTOML,
+	"CSV":  reformatCSV,
+}
+
+func canReformat(lexer chroma.Lexer) bool {
+	return lexer != nil && reformatters[lexer.Config().Name] != nil
+}
+
+// Reformat text using a formatter for the lexer's language. Returns nil if we
+// don't know how to reformat that language, or if the text doesn't parse.
+func reformatText(text string, lexer chroma.Lexer) *string {
+	if !canReformat(lexer) {
+		return nil
+	}
+
+	name := lexer.Config().Name
+	reformatted := reformatters[name](text)
+	if reformatted == nil {
+		log.Debug("Failed to reformat input as ", name)
+	} else {
+		log.Debug("Got the --reformat flag, reformatted ", name, " input")
+	}
+	return reformatted
+}
+
+// Only single JSON values are reformatted. JSON Lines input stays the way it
+// is, so that each line is still one record.
+func reformatJSON(text string) *string {
+	if !json.Valid([]byte(text)) {
+		return nil
+	}
+
+	var output strings.Builder
+	if indentJSON(strings.NewReader(text), &output) != nil {
+		return nil
+	}
+	return ptr(output.String())
+}
+
+func reformatXML(text string) *string {
+	var output strings.Builder
+	if indentXML(strings.NewReader(text), &output) != nil {
+		return nil
+	}
+	return ptr(output.String())
+}
+
+var (
+	// Quoted attribute values, like title="a>b", may contain '>'
+	htmlTokenRegex = regexp.MustCompile(`(?s)<!--.*?-->|<![^>]*>|<\?[^>]*>|</?[a-zA-Z](?:=\s*"[^"]*"|=\s*'[^']*'|[^>])*>`)
+	htmlTagRegex   = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9-]*)`)
+
+	// Text up to an end tag, like "hello</b>"
+	htmlTextOnlyRegex = regexp.MustCompile(`^([^<]*)</([a-zA-Z][a-zA-Z0-9-]*)\s*>`)
+)
+
+// Elements that never have any end tag
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// Elements with contents we must not touch
+var htmlRawTextElements = map[string]bool{
+	"pre": true, "script": true, "style": true, "textarea": true,
+}
+
+// Elements that are implicitly closed by the next sibling of the same kind
+var htmlSelfClosingSiblings = map[string]bool{
+	"dd": true, "dt": true, "li": true, "option": true, "p": true,
+	"td": true, "th": true, "tr": true,
+}
+
+// Indent HTML with two spaces per level. Unlike XML, HTML has elements without
+// end tags, and elements like <script> that can't be parsed as markup, so we
+// can't use encoding/xml for this.
+func reformatHTML(text string) *string {
+	output := strings.Builder{}
+	stack := []string{}
+	newline := func() {
+		if output.Len() > 0 {
+			output.WriteByte('\n')
+		}
+		output.WriteString(strings.Repeat("  ", len(stack)))
+	}
+	writeText := func(text string) {
+		text = strings.Join(strings.Fields(text), " ")
+		if text != "" {
+			newline()
+			output.WriteString(text)
+		}
+	}
+
+	position := 0
+	for position < len(text) {
+		match := htmlTokenRegex.FindStringIndex(text[position:])
+		if match == nil {
+			writeText(text[position:])
+			break
+		}
+		writeText(text[position : position+match[0]])
+		token := text[position+match[0] : position+match[1]]
+		position += match[1]
+
+		tag := htmlTagRegex.FindStringSubmatch(token)
+		if tag == nil {
+			// Comment, doctype or processing instruction
+			newline()
+			output.WriteString(token)
+			continue
+		}
+
+		isEndTag := tag[1] == "/"
+		name := strings.ToLower(tag[2])
+		if isEndTag {
+			// Pop until we find the start tag, ignore stray end tags
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i] == name {
+					stack = stack[:i]
+					break
+				}
+			}
+			newline()
+			output.WriteString(token)
+			continue
+		}
+
+		if htmlSelfClosingSiblings[name] && len(stack) > 0 && stack[len(stack)-1] == name {
+			stack = stack[:len(stack)-1]
+		}
+
+		newline()
+		output.WriteString(token)
+		if htmlVoidElements[name] || strings.HasSuffix(token, "/>") {
+			continue
+		}
+
+		if htmlRawTextElements[name] {
+			// Copy everything up to the end tag as-is
+			end := strings.Index(strings.ToLower(text[position:]), "</"+name)
+			if end < 0 {
+				end = len(text) - position
+			}
+			output.WriteString(text[position : position+end])
+			position += end
+
+			endOfEndTag := strings.IndexByte(text[position:], '>')
+			if endOfEndTag < 0 {
+				output.WriteString(text[position:])
+				break
+			}
+			output.WriteString(text[position : position+endOfEndTag+1])
+			position += endOfEndTag + 1
+			continue
+		}
+
+		if textOnly := htmlTextOnlyRegex.FindStringSubmatch(text[position:]); textOnly != nil && strings.EqualFold(textOnly[2], name) {
+			// Keep short elements like <b>hello</b> on one line
+			output.WriteString(strings.Join(strings.Fields(textOnly[1]), " "))
+			output.WriteString("</" + textOnly[2] + ">")
+			position += len(textOnly[0])
+			continue
+		}
+
+		stack = append(stack, name)
+	}
+
+	output.WriteByte('\n')
+	return ptr(output.String())
+}
+
+// Normalize YAML by expanding flow style collections like "{a: 1, b: [2, 3]}"
+// into block style. Everything else is kept as it is.
+func reformatYAML(text string) *string {
+	output := strings.Builder{}
+
+	// Inside of a "key: |" block scalar, this is the indentation of the key
+	blockScalarIndent := -1
+
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if blockScalarIndent >= 0 && (line == "" || indent > blockScalarIndent) {
+			// Block scalar contents, not ours to touch
+			output.WriteString(line)
+			output.WriteByte('\n')
+			continue
+		}
+		blockScalarIndent = -1
+		if yamlBlockScalarRegex.MatchString(line) {
+			blockScalarIndent = indent
+		}
+
+		for _, expanded := range expandYAMLFlowLine(line) {
+			output.WriteString(expanded)
+			output.WriteByte('\n')
+		}
+	}
+	return ptr(output.String())
+}
+
+// A value parsed from YAML flow style. Scalars are kept exactly as written.
+type yamlFlowValue struct {
+	// '{', '[' or 0 for scalars
+	kind byte
+
+	keys   []string // Only for mappings
+	values []yamlFlowValue
+
+	scalar string
+}
+
+var yamlBlockScalarRegex = regexp.MustCompile(`(:|^\s*-)\s+[|>][-+0-9]*\s*(#.*)?$`)
+
+var yamlFlowLineRegex = regexp.MustCompile(`^(\s*)((?:- )*)([^\s#'"{\[][^:#]*:\s+|'[^']*':\s+|"[^"]*":\s+)?([{\[].*)$`)
+
+// Expand a line containing a flow collection, like "key: {a: 1, b: 2}" or
+// "- [1, 2]", into block style lines. Lines we can't expand are returned as
+// they are.
+func expandYAMLFlowLine(line string) []string {
+	match := yamlFlowLineRegex.FindStringSubmatch(line)
+	if match == nil {
+		return []string{line}
+	}
+	indent, dashes, key, flow := match[1], match[2], strings.TrimSpace(match[3]), match[4]
+
+	value, rest, ok := parseYAMLFlowValue(flow)
+	if !ok || strings.TrimSpace(rest) != "" || len(value.values) == 0 {
+		// Not a flow collection we understand, or something after it, like a
+		// comment. Or empty, those are fine the way they are.
+		return []string{line}
+	}
+
+	// The prefix for the first line, and the indentation for the rest
+	first := indent + dashes
+	depth := len(indent) + len(dashes)
+	if key != "" {
+		// "key:" on a line of its own, the value below
+		return append([]string{first + key}, value.block(depth+2)...)
+	}
+
+	lines := value.block(depth)
+	if len(lines) > 0 {
+		lines[0] = first + strings.TrimLeft(lines[0], " ")
+	}
+	return lines
+}
+
+// Returns the value, whatever comes after it and whether parsing worked
+func parseYAMLFlowValue(text string) (yamlFlowValue, string, bool) {
+	text = strings.TrimLeft(text, " ")
+	if text == "" {
+		return yamlFlowValue{}, text, false
+	}
+
+	switch text[0] {
+	case '{', '[':
+		value := yamlFlowValue{kind: text[0]}
+		closing := byte('}')
+		if value.kind == '[' {
+			closing = ']'
+		}
+
+		rest := strings.TrimLeft(text[1:], " ")
+		for {
+			if rest == "" {
+				return yamlFlowValue{}, rest, false
+			}
+			if rest[0] == closing {
+				return value, rest[1:], true
+			}
+
+			if value.kind == '{' {
+				var key yamlFlowValue
+				var ok bool
+				key, rest, ok = parseYAMLFlowScalar(rest, true)
+				if !ok || !strings.HasPrefix(rest, ":") {
+					return yamlFlowValue{}, rest, false
+				}
+				value.keys = append(value.keys, key.scalar)
+				rest = rest[1:]
+			}
+
+			item, afterItem, ok := parseYAMLFlowValue(rest)
+			if !ok {
+				return yamlFlowValue{}, rest, false
+			}
+			value.values = append(value.values, item)
+
+			rest = strings.TrimLeft(afterItem, " ")
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimLeft(rest[1:], " ")
+			}
+		}
+
+	case '}', ']', ',':
+		return yamlFlowValue{}, text, false
+	}
+
+	return parseYAMLFlowScalar(text, false)
+}
+
+// Quoted scalars are kept with their quotes. Plain scalars end at the next
+// flow indicator, or at a colon if this is a key.
+func parseYAMLFlowScalar(text string, isKey bool) (yamlFlowValue, string, bool) {
+	text = strings.TrimLeft(text, " ")
+	if strings.HasPrefix(text, "'") || strings.HasPrefix(text, `"`) {
+		quote := text[0]
+		for i := 1; i < len(text); i++ {
+			if quote == '"' && text[i] == '\\' {
+				i++
+				continue
+			}
+			if text[i] != quote {
+				continue
+			}
+			if quote == '\'' && i+1 < len(text) && text[i+1] == '\'' {
+				// Escaped single quote
+				i++
+				continue
+			}
+			return yamlFlowValue{scalar: text[:i+1]}, strings.TrimLeft(text[i+1:], " "), true
+		}
+		return yamlFlowValue{}, text, false
+	}
+
+	terminators := ",]}"
+	if isKey {
+		terminators += ":"
+	}
+	end := strings.IndexAny(text, terminators)
+	if end < 0 {
+		end = len(text)
+	}
+	scalar := strings.TrimSpace(text[:end])
+	if scalar == "" {
+		return yamlFlowValue{}, text, false
+	}
+	return yamlFlowValue{scalar: scalar}, text[end:], true
+}
+
+// Render as block style lines with the given indentation
+func (v yamlFlowValue) block(indent int) []string {
+	prefix := strings.Repeat(" ", indent)
+	lines := []string{}
+	for i, item := range v.values {
+		if v.kind == '{' {
+			key := prefix + v.keys[i] + ":"
+			if item.kind == 0 || len(item.values) == 0 {
+				lines = append(lines, key+" "+item.inline())
+			} else {
+				lines = append(lines, key)
+				lines = append(lines, item.block(indent+2)...)
+			}
+			continue
+		}
+
+		if item.kind == 0 || len(item.values) == 0 {
+			lines = append(lines, prefix+"- "+item.inline())
+			continue
+		}
+
+		itemLines := item.block(indent + 2)
+		itemLines[0] = prefix + "- " + strings.TrimLeft(itemLines[0], " ")
+		lines = append(lines, itemLines...)
+	}
+	return lines
+}
+
+// Scalars, plus empty collections
+func (v yamlFlowValue) inline() string {
+	switch v.kind {
+	case '{':
+		return "{}"
+	case '[':
+		return "[]"
+	}
+	return v.scalar
+}
+
+var (
+	tomlTableHeaderRegex = regexp.MustCompile(`^\s*(\[\[?)\s*([^\]]*?)\s*(\]\]?)\s*(#.*)?$`)
+	tomlKeyRegex         = regexp.MustCompile(`^([\w-]+|"[^"]*"|'[^']*')(\s*\.\s*([\w-]+|"[^"]*"|'[^']*'))*$`)
+)
+
+// Normalize TOML: "key = value" with single spaces, values with spaces after
+// commas, no spaces inside table headers and a blank line before each table.
+// Multi line strings are kept the way they are.
+func reformatTOML(text string) *string {
+	output := []string{}
+	var inMultiLineString string // The string delimiter, if we're in one
+
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+
+		if inMultiLineString != "" {
+			output = append(output, line)
+			if strings.Count(line, inMultiLineString)%2 == 1 {
+				inMultiLineString = ""
+			}
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			output = append(output, trimmed)
+			continue
+		}
+
+		if match := tomlTableHeaderRegex.FindStringSubmatch(line); match != nil {
+			if len(output) > 0 && output[len(output)-1] != "" && !strings.HasPrefix(output[len(output)-1], "#") {
+				output = append(output, "")
+			}
+			header := match[1] + match[2] + match[3]
+			if match[4] != "" {
+				header += " " + match[4]
+			}
+			output = append(output, header)
+			continue
+		}
+
+		key, value, ok := splitTOMLKeyValue(trimmed)
+		if !ok {
+			// Broken, or something we don't understand
+			output = append(output, line)
+			continue
+		}
+
+		for _, delimiter := range []string{`"""`, `'''`} {
+			if strings.Count(value, delimiter)%2 == 1 {
+				inMultiLineString = delimiter
+				break
+			}
+		}
+		if inMultiLineString != "" {
+			// Don't touch anything inside of the string
+			output = append(output, key+" = "+value)
+			continue
+		}
+
+		output = append(output, key+" = "+normalizeTOMLValue(value))
+	}
+
+	return ptr(strings.Join(output, "\n") + "\n")
+}
+
+// Split at the first = outside of quotes
+func splitTOMLKeyValue(line string) (string, string, bool) {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		char := line[i]
+		switch {
+		case quote != 0 && char == '\\' && quote == '"':
+			i++
+		case quote != 0 && char == quote:
+			quote = 0
+		case quote != 0:
+			// Inside of a quoted key
+		case char == '"' || char == '\'':
+			quote = char
+		case char == '=':
+			key := strings.TrimSpace(line[:i])
+			value := strings.TrimSpace(line[i+1:])
+			return key, value, tomlKeyRegex.MatchString(key) && value != ""
+		}
+	}
+	return "", "", false
+}
+
+// Spaces after commas, around = in inline tables and inside inline table
+// braces. Strings and comments are left alone.
+func normalizeTOMLValue(value string) string {
+	output := strings.Builder{}
+	var quote byte
+	pendingSpace := false
+	for i := 0; i < len(value); i++ {
+		char := value[i]
+
+		if quote != 0 {
+			output.WriteByte(char)
+			if char == '\\' && quote == '"' && i+1 < len(value) {
+				i++
+				output.WriteByte(value[i])
+			} else if char == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch char {
+		case ' ', '\t':
+			pendingSpace = output.Len() > 0
+			continue
+		case '#':
+			// A comment, keep it as is
+			output.WriteString(" " + value[i:])
+			return strings.TrimSpace(output.String())
+		case ',':
+			output.WriteByte(',')
+			pendingSpace = true
+			continue
+		case '=':
+			output.WriteString(" =")
+			pendingSpace = true
+			continue
+		case '{':
+			if pendingSpace {
+				output.WriteByte(' ')
+			}
+			output.WriteString("{")
+			pendingSpace = true
+			continue
+		case '}':
+			if strings.HasSuffix(output.String(), "{") {
+				output.WriteByte('}')
+			} else {
+				output.WriteString(" }")
+			}
+			pendingSpace = false
+			continue
+		case ']':
+			output.WriteByte(']')
+			pendingSpace = false
+			continue
+		}
+
+		if pendingSpace && !strings.HasSuffix(output.String(), "[") {
+			output.WriteByte(' ')
+		}
+		pendingSpace = false
+
+		output.WriteByte(char)
+		if char == '"' || char == '\'' {
+			quote = char
+		}
+	}
+
+	return output.String()
+}
+
+// Align the columns of CSV input. Values are quoted where needed, and padded
+// with spaces after the separating commas.
+func reformatCSV(text string) *string {
+	csvReader := csv.NewReader(strings.NewReader(text))
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+	records, err := csvReader.ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil
+	}
+
+	quoted := make([][]string, len(records))
+	widths := []int{}
+	for i, record := range records {
+		for column, field := range record {
+			if strings.ContainsAny(field, "\r\n") {
+				// Multi line values would break the alignment anyway
+				return nil
+			}
+
+			field = csvQuote(field)
+			quoted[i] = append(quoted[i], field)
+
+			if column >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[column] = max(widths[column], min(uniseg.StringWidth(field), csvMaxColumnWidth))
+		}
+	}
+
+	output := strings.Builder{}
+	for _, record := range quoted {
+		line := strings.Builder{}
+		for column, field := range record {
+			line.WriteString(field)
+			if column < len(record)-1 {
+				line.WriteByte(',')
+				line.WriteString(strings.Repeat(" ", max(widths[column]-uniseg.StringWidth(field), 0)+1))
+			}
+		}
+
+		// Padding before empty last values is just noise
+		output.WriteString(strings.TrimRight(line.String(), " "))
+		output.WriteByte('\n')
+	}
+
+	return ptr(output.String())
+}
+
+// Quote a CSV field if needed. Leading and trailing whitespace is quoted to
+// not mix it up with our padding.
+func csvQuote(field string) string {
+	if field == "" || (!strings.ContainsAny(field, `,"`) && strings.TrimSpace(field) == field) {
+		return field
+	}
+	return `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+}
+
+func ptr(s string) *string {
+	return &s
+}
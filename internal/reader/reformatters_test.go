@@ -0,0 +1,178 @@
+package reader
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"gotest.tools/v3/assert"
+)
+
+func joinLines(lines ...string) string {
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestReformatHTML(t *testing.T) {
+	reformatted := reformatText(
+		`<!DOCTYPE html><html><head><meta charset="utf-8"><title>Hello</title>`+
+			`<script>if (a < b) {  x() }</script></head>`+
+			`<body><ul><li>one<li>two</ul><p>Some <b>bold</b> text<br>more</p></body></html>`,
+		lexers.Get("html"))
+	assert.Equal(t, *reformatted, joinLines(
+		`<!DOCTYPE html>`,
+		`<html>`,
+		`  <head>`,
+		`    <meta charset="utf-8">`,
+		`    <title>Hello</title>`,
+		`    <script>if (a < b) {  x() }</script>`,
+		`  </head>`,
+		`  <body>`,
+		`    <ul>`,
+		`      <li>`,
+		`        one`,
+		`      <li>`,
+		`        two`,
+		`    </ul>`,
+		`    <p>`,
+		`      Some`,
+		`      <b>bold</b>`,
+		`      text`,
+		`      <br>`,
+		`      more`,
+		`    </p>`,
+		`  </body>`,
+		`</html>`,
+	))
+}
+
+func TestReformatHTMLQuotedAttributes(t *testing.T) {
+	reformatted := reformatText(
+		`<html><body><p><a title="a>b" href='x>y'>link</a> don't<img alt="unterminated></p></body></html>`,
+		lexers.Get("html"))
+	assert.Equal(t, *reformatted, joinLines(
+		`<html>`,
+		`  <body>`,
+		`    <p>`,
+		`      <a title="a>b" href='x>y'>link</a>`,
+		`      don't`,
+		`      <img alt="unterminated>`,
+		`    </p>`,
+		`  </body>`,
+		`</html>`,
+	))
+}
+
+func TestReformatYAML(t *testing.T) {
+	reformatted := reformatText(joinLines(
+		`name: test   `,
+		`config: {replicas: 3, ports: [80, 443], labels: {app: "a, b"}, empty: []}`,
+		`items:`,
+		`  - [one, 'two: 2']`,
+		`  - {a: 1}`,
+		`script: |`,
+		`  {not: yaml}`,
+		`comment: {a: 1} # Left alone`,
+	), lexers.Get("yaml"))
+	assert.Equal(t, *reformatted, joinLines(
+		`name: test`,
+		`config:`,
+		`  replicas: 3`,
+		`  ports:`,
+		`    - 80`,
+		`    - 443`,
+		`  labels:`,
+		`    app: "a, b"`,
+		`  empty: []`,
+		`items:`,
+		`  - - one`,
+		`    - 'two: 2'`,
+		`  - a: 1`,
+		`script: |`,
+		`  {not: yaml}`,
+		`comment: {a: 1} # Left alone`,
+	))
+
+	// A one line flow style document
+	reformatted = reformatText(`{a: 1, b: [x, {c: d}]}`, lexers.Get("yaml"))
+	assert.Equal(t, *reformatted, joinLines(
+		`a: 1`,
+		`b:`,
+		`  - x`,
+		`  - c: d`,
+	))
+}
+
+func TestReformatTOML(t *testing.T) {
+	reformatted := reformatText(joinLines(
+		`title="TOML"   `,
+		`[ owner ]`,
+		`name  =  "Tom = Preston" # A comment`,
+		`dob=1979-05-27 07:32:00-08:00`,
+		`[[ servers ]]`,
+		`ports=[8000,8001]`,
+		`point={x=1,y=2}`,
+		`empty = {}`,
+		`text = """`,
+		`a=b,c`,
+		`"""`,
+		`"quoted key"=true`,
+	), lexers.Get("toml"))
+	assert.Equal(t, *reformatted, joinLines(
+		`title = "TOML"`,
+		``,
+		`[owner]`,
+		`name = "Tom = Preston" # A comment`,
+		`dob = 1979-05-27 07:32:00-08:00`,
+		``,
+		`[[servers]]`,
+		`ports = [8000, 8001]`,
+		`point = { x = 1, y = 2 }`,
+		`empty = {}`,
+		`text = """`,
+		`a=b,c`,
+		`"""`,
+		`"quoted key" = true`,
+	))
+}
+
+func TestReformatCSV(t *testing.T) {
+	reformatted := reformatText(joinLines(
+		`id,name,comment`,
+		`1,Åsa,"Hello, world"`,
+		`22,Bob,`,
+	), lexers.Get("csv"))
+	assert.Equal(t, *reformatted, joinLines(
+		`id, name, comment`,
+		`1,  Åsa,  "Hello, world"`,
+		`22, Bob,`,
+	))
+}
+
+func TestReformatUnknownLanguage(t *testing.T) {
+	assert.Assert(t, reformatText("package main", lexers.Get("go")) == nil)
+	assert.Assert(t, reformatText("package main", nil) == nil)
+	assert.Assert(t, reformatText(`{"a": `, lexers.Get("json")) == nil)
+}
+
+func TestReformatByLexer(t *testing.T) {
+	testMe, err := NewFromStream(
+		"YAML test",
+		strings.NewReader("config: {a: 1, b: 2}\n"),
+		formatters.TTY16m,
+		ReaderOptions{
+			Style:        styles.Get("native"),
+			Lexer:        lexers.Get("yaml"),
+			ShouldFormat: true,
+		})
+	assert.NilError(t, err)
+	assert.NilError(t, testMe.Wait())
+
+	shown := []string{}
+	for _, line := range testMe.GetLines(linemetadata.Index{}, 10).Lines {
+		shown = append(shown, line.Plain())
+	}
+	assert.DeepEqual(t, shown, []string{"config:", "  a: 1", "  b: 2"})
+}
@@ -0,0 +1,172 @@
+package reader
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/search"
+	"github.com/walles/moor/v2/internal/textstyles"
+	"github.com/walles/moor/v2/twin"
+)
+
+// Look at this many lines when deciding whether some input is a table
+const TableDetectionLines = 20
+
+// Require at least this many lines with the same number of fields before
+// calling some input a table
+const tableDetectionMinLines = 5
+
+// DetectTableSeparator looks at the first lines of some input, and returns ','
+// for CSV, '\t' for TSV or 0 if the input doesn't look like a table.
+//
+// hasHeader is true if the first line looks like column names. Without that,
+// consistent separator counts can just as well be a coincidence.
+//
+// The lines should be raw, as in not having had their tabs expanded.
+func DetectTableSeparator(lines []string) (separator rune, hasHeader bool) {
+	sample := []string{}
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		sample = append(sample, line)
+		if len(sample) >= TableDetectionLines {
+			break
+		}
+	}
+	if len(sample) < tableDetectionMinLines {
+		return 0, false
+	}
+
+	for _, separator := range []rune{'\t', ','} {
+		header := SplitFields(sample[0], separator)
+		if len(header) < 2 {
+			continue
+		}
+
+		allSame := true
+		for _, line := range sample[1:] {
+			if len(SplitFields(line, separator)) != len(header) {
+				allSame = false
+				break
+			}
+		}
+		if allSame {
+			return separator, looksLikeHeader(header)
+		}
+	}
+
+	return 0, false
+}
+
+// Column names are neither empty nor numbers
+func looksLikeHeader(fields []string) bool {
+	for _, field := range fields {
+		field = strings.TrimSpace(textstyles.StripFormatting(field, linemetadata.Index{}))
+		if field == "" {
+			return false
+		}
+		if _, err := strconv.ParseFloat(field, 64); err == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SplitFields splits a line into table fields. With ',' as the separator,
+// fields can be quoted CSV style: "like, this". The quotes are removed, and so
+// is any padding after the commas.
+//
+// ANSI escape sequences are kept in the fields, so that highlighting survives.
+func SplitFields(raw string, separator rune) []string {
+	fields := []string{}
+	field := strings.Builder{}
+	inQuotes := false
+	atFieldStart := true
+
+	for i := 0; i < len(raw); i++ {
+		char := raw[i]
+
+		if char == '\x1b' {
+			// Copy the whole escape sequence, it doesn't affect the fields
+			end := i + 1
+			if end < len(raw) && raw[end] == '[' {
+				end++
+				for end < len(raw) && (raw[end] < 0x40 || raw[end] > 0x7e) {
+					end++
+				}
+			}
+			end = min(end+1, len(raw))
+			field.WriteString(raw[i:end])
+			i = end - 1
+			continue
+		}
+
+		if separator == ',' && char == '"' {
+			switch {
+			case atFieldStart:
+				inQuotes = true
+				atFieldStart = false
+				continue
+			case inQuotes && i+1 < len(raw) && raw[i+1] == '"':
+				// Escaped quote
+				field.WriteByte('"')
+				i++
+				continue
+			case inQuotes:
+				inQuotes = false
+				continue
+			}
+		}
+
+		if separator == ',' && char == ' ' && atFieldStart {
+			// Padding, like in "a, b" or in --reformat output
+			continue
+		}
+
+		if rune(char) == separator && !inQuotes {
+			fields = append(fields, field.String())
+			field.Reset()
+			atFieldStart = true
+			continue
+		}
+
+		if char == '\r' && i == len(raw)-1 {
+			// Windows line ending
+			continue
+		}
+
+		field.WriteByte(char)
+		atFieldStart = false
+	}
+
+	return append(fields, field.String())
+}
+
+// HighlightedFields splits the line into table fields, and highlights each one
+// like HighlightedTokens() does.
+func (line *Line) HighlightedFields(
+	separator rune,
+	plainTextStyle twin.Style,
+	searchHitStyle twin.Style,
+	search search.Search,
+	pinned []PinnedHighlight,
+	lineIndex linemetadata.Index,
+) []textstyles.StyledRunesWithTrailer {
+	fields := SplitFields(string(line.raw), separator)
+	highlighted := make([]textstyles.StyledRunesWithTrailer, 0, len(fields))
+	for _, field := range fields {
+		fromString := textstyles.StyledRunesFromString(plainTextStyle, field, &lineIndex, 0)
+		plain := textstyles.StripFormatting(field, lineIndex)
+		highlighted = append(highlighted, highlightMatches(fromString, plain, searchHitStyle, search, pinned))
+	}
+
+	return highlighted
+}
+
+// Raw returns the line as read, including any formatting
+func (line *Line) Raw() string {
+	return string(line.raw)
+}
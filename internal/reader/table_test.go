@@ -0,0 +1,72 @@
+package reader
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSplitFields(t *testing.T) {
+	assert.DeepEqual(t, SplitFields("a,b,c", ','), []string{"a", "b", "c"})
+	assert.DeepEqual(t, SplitFields("a,,c", ','), []string{"a", "", "c"})
+	assert.DeepEqual(t, SplitFields(`"Smith, Jane",25,"She said ""Hi"""`, ','),
+		[]string{"Smith, Jane", "25", `She said "Hi"`})
+	assert.DeepEqual(t, SplitFields("a,   b,  c\r", ','), []string{"a", "b", "c"})
+
+	// Tabs separate, commas and quotes don't
+	assert.DeepEqual(t, SplitFields("a, b\t\"c\"", '\t'), []string{"a, b", `"c"`})
+}
+
+func TestSplitFieldsFormatted(t *testing.T) {
+	// Formatting is kept, and commas inside of escape sequences don't split
+	assert.DeepEqual(t, SplitFields("\x1b[1;31ma\x1b[0m,b", ','),
+		[]string{"\x1b[1;31ma\x1b[0m", "b"})
+}
+
+func TestDetectTableSeparator(t *testing.T) {
+	separator, hasHeader := DetectTableSeparator([]string{
+		"Name,Age",
+		"Ann,30",
+		`"Smith, Jane",25`,
+		"",
+		"Bob,40",
+		"Eve,50",
+	})
+	assert.Equal(t, separator, ',')
+	assert.Assert(t, hasHeader)
+
+	separator, hasHeader = DetectTableSeparator([]string{
+		"Name\tAge, years",
+		"Ann\t30",
+		"Bob\t40",
+		"Eve\t50",
+		"Joe\t60",
+	})
+	assert.Equal(t, separator, '\t')
+	assert.Assert(t, hasHeader)
+
+	// Like from "cat -n", consistent but without any column names
+	separator, hasHeader = DetectTableSeparator([]string{
+		"     1\tone",
+		"     2\ttwo",
+		"     3\tthree",
+		"     4\tfour",
+		"     5\tfive",
+	})
+	assert.Equal(t, separator, '\t')
+	assert.Assert(t, !hasHeader)
+
+	// Too few lines to tell
+	separator, _ = DetectTableSeparator([]string{"a,b", "c,d"})
+	assert.Equal(t, separator, rune(0))
+
+	// Some commas, but not a table
+	separator, _ = DetectTableSeparator([]string{
+		"Hello, world",
+		"This is a line of text",
+		"And another one, with a comma",
+		"And, one, with, many",
+		"The end",
+	})
+	assert.Equal(t, separator, rune(0))
+}
@@ -0,0 +1,225 @@
+package reader
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// How often to check files for changes when we can't be notified about them
+const tailPollInterval = 1 * time.Second
+
+// Even with change notifications we check this often, since notifications
+// don't work on some file systems
+const tailSafetyInterval = 10 * time.Second
+
+// Shown where the file was truncated, like by logrotate's copytruncate
+const truncatedMarker = "\x1b[7m--- File truncated, new contents below ---\x1b[0m"
+
+// Shown where the file was replaced, like by logrotate's default rename and
+// create
+const replacedMarker = "\x1b[7m--- File replaced, new file below ---\x1b[0m"
+
+// Follow the file by name, like "tail -F" does. If the file is replaced, we
+// continue with the new file. If it's truncated, we continue from its new
+// beginning. In both cases a marker line shows where that happened.
+func (reader *ReaderImpl) tailFile() error {
+	reader.RLock()
+	fileName := reader.FileName
+	bytesCount := reader.bytesCount
+	reader.RUnlock()
+	if fileName == nil {
+		return nil
+	}
+
+	if bytesCount == -1 {
+		log.Debugf("Bytes count unknown for %s, not tailing", *fileName)
+		return nil
+	}
+
+	file, err := openForTailing(*fileName)
+	if err != nil {
+		log.Debugf("Not tailing %s: %s", *fileName, err.Error())
+		return nil
+	}
+	defer func() {
+		// Whichever file we ended up with
+		_ = file.Close()
+	}()
+
+	watcher, err := newFileWatcher(*fileName)
+	if err != nil {
+		log.Debugf("Polling %s for changes, no change notifications: %s", *fileName, err.Error())
+		watcher = nil
+	} else {
+		// Wake the watcher up if we get stopped while it's waiting
+		doneTailing := make(chan struct{})
+		interrupterDone := make(chan struct{})
+		go func() {
+			defer close(interrupterDone)
+			select {
+			case <-reader.stopped:
+				watcher.interrupt()
+			case <-doneTailing:
+			}
+		}()
+
+		defer func() {
+			close(doneTailing)
+			<-interrupterDone
+			watcher.close()
+		}()
+	}
+
+	log.Debugf("Tailing file %s", *fileName)
+
+	for {
+		if reader.isStopped() {
+			log.Debugf("Reader stopped, done tailing %s", *fileName)
+			return nil
+		}
+
+		// Check before waiting, in case the file changed before the watcher
+		// was set up
+		newFile, err := reader.readTail(*fileName, file)
+		if newFile != file && watcher != nil {
+			// Writes to the old file don't matter any more, the new one's do
+			watcher.watchFile(*fileName)
+		}
+		file = newFile
+		if err != nil {
+			return err
+		}
+
+		if watcher != nil {
+			watcher.wait(tailSafetyInterval)
+		} else {
+			select {
+			case <-reader.stopped:
+			case <-time.After(tailPollInterval):
+			}
+		}
+	}
+}
+
+// Open a file we can seek in. Compressed files can't be tailed.
+func openForTailing(fileName string) (*os.File, error) {
+	stream, _, err := ZOpen(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	file, ok := stream.(*os.File)
+	if !ok {
+		_ = stream.Close()
+		return nil, fmt.Errorf("%s is compressed", fileName)
+	}
+
+	return file, nil
+}
+
+// Read whatever has been added since last time. Returns the file to continue
+// with, which is a new one if the old one was replaced.
+func (reader *ReaderImpl) readTail(fileName string, file *os.File) (*os.File, error) {
+	err := reader.readMore(file)
+	if err != nil {
+		return file, err
+	}
+
+	openStats, err := file.Stat()
+	if err != nil {
+		return file, fmt.Errorf("failed to stat %s while tailing: %w", fileName, err)
+	}
+
+	nameStats, err := os.Stat(fileName)
+	if err != nil {
+		// Moved away and not recreated yet, keep waiting for the new one
+		log.Tracef("File %s is gone, waiting for it to come back: %s", fileName, err.Error())
+		return file, nil
+	}
+
+	if os.SameFile(openStats, nameStats) {
+		return file, nil
+	}
+
+	newFile, err := openForTailing(fileName)
+	if err != nil {
+		log.Debugf("Failed to open replaced file %s, trying again later: %s", fileName, err.Error())
+		return file, nil
+	}
+
+	log.Debugf("File %s was replaced, tailing the new one", fileName)
+	_ = file.Close()
+	reader.addMarkerLine(replacedMarker)
+
+	return newFile, reader.readMore(newFile)
+}
+
+// Read from where we stopped last time to the end of the file
+func (reader *ReaderImpl) readMore(file *os.File) error {
+	stats, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s while tailing: %w", file.Name(), err)
+	}
+
+	reader.RLock()
+	bytesCount := reader.bytesCount
+	reader.RUnlock()
+
+	if stats.Size() == bytesCount {
+		log.Tracef("File %s unchanged at %d bytes, continue tailing", file.Name(), bytesCount)
+		return nil
+	}
+
+	if stats.Size() < bytesCount {
+		log.Debugf("File %s shrunk from %d to %d bytes, reading it from the start",
+			file.Name(), bytesCount, stats.Size())
+		reader.addMarkerLine(truncatedMarker)
+		bytesCount = 0
+	}
+
+	_, err = file.Seek(bytesCount, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("failed to seek in %s while tailing: %w", file.Name(), err)
+	}
+
+	log.Tracef("File %s up from %d bytes to %d bytes, reading more lines...", file.Name(), bytesCount, stats.Size())
+	reader.consumeLinesFromStream(file)
+
+	return nil
+}
+
+// Add a line telling the user what happened to the file, and start counting
+// bytes from the start of the file again.
+//
+// Indexed files start over from scratch instead, since the lines we have
+// indexed aren't in the file any more.
+func (reader *ReaderImpl) addMarkerLine(marker string) {
+	reader.Lock()
+	if reader.indexed != nil {
+		reader.indexed.reset()
+		reader.endsWithNewline = false
+		reader.bytesCount = 0
+		reader.Unlock()
+
+		select {
+		case reader.MoreLinesAdded <- true:
+		default:
+		}
+		return
+	}
+
+	linePool := linePool{}
+	reader.assumeLockAndAddLine([]byte(marker), false, &linePool)
+	reader.endsWithNewline = true
+	reader.bytesCount = 0
+	reader.Unlock()
+
+	select {
+	case reader.MoreLinesAdded <- true:
+	default:
+	}
+}
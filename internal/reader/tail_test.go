@@ -0,0 +1,186 @@
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/styles"
+	"gotest.tools/v3/assert"
+
+	"github.com/walles/moor/v2/internal/linemetadata"
+)
+
+// Wait for the reader to have this many lines, then return all of them
+func waitForLines(t *testing.T, reader *ReaderImpl, count int) []string {
+	t.Helper()
+
+	// Polling without change notifications can take a second
+	for range 30 {
+		if reader.GetLineCount() >= count {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	plain := []string{}
+	for _, line := range reader.GetLines(linemetadata.Index{}, count+10).Lines {
+		plain = append(plain, line.Plain())
+	}
+	return plain
+}
+
+func startTailing(t *testing.T, fileName string) *ReaderImpl {
+	t.Helper()
+
+	reader, err := NewFromFilename(fileName, formatters.TTY16m, ReaderOptions{Style: styles.Get("native")})
+	assert.NilError(t, err)
+	assert.NilError(t, reader.Wait())
+
+	// Give tailFile() a moment to get going
+	time.Sleep(100 * time.Millisecond)
+
+	return reader
+}
+
+// Like logrotate's copytruncate
+func TestTailTruncatedFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "truncated.log")
+	assert.NilError(t, os.WriteFile(fileName, []byte("first\nsecond\n"), 0o600))
+
+	reader := startTailing(t, fileName)
+	assert.Equal(t, reader.GetLineCount(), 2)
+
+	assert.NilError(t, os.WriteFile(fileName, []byte("new\n"), 0o600))
+
+	assert.DeepEqual(t, waitForLines(t, reader, 4), []string{
+		"first",
+		"second",
+		"--- File truncated, new contents below ---",
+		"new",
+	})
+	assert.Equal(t, int(reader.bytesCount), len("new\n"))
+}
+
+// Truncated to nothing, and then written to
+func TestTailTruncatedToEmpty(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "emptied.log")
+	assert.NilError(t, os.WriteFile(fileName, []byte("first\n"), 0o600))
+
+	reader := startTailing(t, fileName)
+	assert.Equal(t, reader.GetLineCount(), 1)
+
+	assert.NilError(t, os.Truncate(fileName, 0))
+	assert.DeepEqual(t, waitForLines(t, reader, 2), []string{
+		"first",
+		"--- File truncated, new contents below ---",
+	})
+
+	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY, 0o600)
+	assert.NilError(t, err)
+	_, err = file.WriteString("new\n")
+	assert.NilError(t, err)
+	assert.NilError(t, file.Close())
+
+	assert.DeepEqual(t, waitForLines(t, reader, 3), []string{
+		"first",
+		"--- File truncated, new contents below ---",
+		"new",
+	})
+}
+
+// Like logrotate's default rename and create
+func TestTailReplacedFile(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "replaced.log")
+	assert.NilError(t, os.WriteFile(fileName, []byte("first\n"), 0o600))
+
+	reader := startTailing(t, fileName)
+	assert.Equal(t, reader.GetLineCount(), 1)
+
+	// Lines written to the old file after the rename should still show up
+	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY, 0o600)
+	assert.NilError(t, err)
+	assert.NilError(t, os.Rename(fileName, filepath.Join(dir, "replaced.log.1")))
+	_, err = file.WriteString("last old\n")
+	assert.NilError(t, err)
+	assert.NilError(t, file.Close())
+
+	assert.NilError(t, os.WriteFile(fileName, []byte("new\n"), 0o600))
+
+	assert.DeepEqual(t, waitForLines(t, reader, 4), []string{
+		"first",
+		"last old",
+		"--- File replaced, new file below ---",
+		"new",
+	})
+
+	// And we should be following the new file now
+	file, err = os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY, 0o600)
+	assert.NilError(t, err)
+	_, err = file.WriteString("newer\n")
+	assert.NilError(t, err)
+	assert.NilError(t, file.Close())
+
+	assert.Equal(t, waitForLines(t, reader, 5)[4], "newer")
+}
+
+// Writes to a renamed file should show up right away, not only after the next
+// safety check
+func TestTailRenamedFile(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "renamed.log")
+	assert.NilError(t, os.WriteFile(fileName, []byte("first\n"), 0o600))
+
+	reader := startTailing(t, fileName)
+	assert.Equal(t, reader.GetLineCount(), 1)
+
+	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY, 0o600)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, file.Close())
+	}()
+	assert.NilError(t, os.Rename(fileName, filepath.Join(dir, "renamed.log.1")))
+
+	// Let tailing notice the rename before writing
+	time.Sleep(200 * time.Millisecond)
+
+	_, err = file.WriteString("after rename\n")
+	assert.NilError(t, err)
+
+	// Less than tailSafetyInterval
+	assert.DeepEqual(t, waitForLines(t, reader, 2), []string{"first", "after rename"})
+}
+
+// Stopping should make tailFile() return, without waiting for the file to
+// change
+func TestTailStop(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "stopped.log")
+	assert.NilError(t, os.WriteFile(fileName, []byte("first\n"), 0o600))
+
+	reader := NewFromTextForTesting("", "first")
+	reader.FileName = &fileName
+	reader.bytesCount = int64(len("first\n"))
+	reader.endsWithNewline = true
+
+	tailingDone := make(chan error)
+	go func() {
+		tailingDone <- reader.tailFile()
+	}()
+
+	// Give tailFile() a moment to start waiting for changes
+	time.Sleep(100 * time.Millisecond)
+
+	reader.Stop()
+	select {
+	case err := <-tailingDone:
+		assert.NilError(t, err)
+	case <-time.After(tailSafetyInterval / 2):
+		t.Fatal("Still tailing after being stopped")
+	}
+
+	// Stopping twice is fine
+	reader.Stop()
+}
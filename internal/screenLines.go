@@ -2,6 +2,7 @@ package internal
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/davecgh/go-spew/spew"
 	log "github.com/sirupsen/logrus"
@@ -31,7 +32,16 @@ type renderedLine struct {
 	trailer twin.Style
 }
 
+// Shown between non-contiguous groups of lines when filtering with context
+var contextSeparator = []textstyles.CellWithMetadata{
+	{Rune: '-', Style: twin.StyleDefault.WithAttr(twin.AttrDim)},
+	{Rune: '-', Style: twin.StyleDefault.WithAttr(twin.AttrDim)},
+}
+
 type renderedScreen struct {
+	// Shown above the lines, and not scrolled along with them
+	headerLines []renderedLine
+
 	lines             []renderedLine
 	inputLines        []reader.NumberedLine
 	numberPrefixWidth int // Including padding. 0 means no line numbers.
@@ -45,10 +55,11 @@ func (p *Pager) redraw(spinner string) {
 	log.Trace("redraw called")
 	p.screen.Clear()
 	p.longestLineLength = 0
+	p.detectTable()
 
 	lastUpdatedScreenLineNumber := -1
 	renderedScreen := p.renderLines()
-	for screenLineNumber, row := range renderedScreen.lines {
+	for screenLineNumber, row := range append(renderedScreen.headerLines, renderedScreen.lines...) {
 		lastUpdatedScreenLineNumber = screenLineNumber
 		column := 0
 		for _, cell := range row.cells {
@@ -117,8 +128,36 @@ func (p *Pager) internalRenderLines(highlightSearchHitLines bool) renderedScreen
 	}
 
 	lastVisibleLineNumber := inputLines.Lines[len(inputLines.Lines)-1].Number
+	if !p.isShowingHelp {
+		// Large inputs get highlighted as they come into view
+		p.readerLock.Lock()
+		if p.fileEnd != nil {
+			p.fileEnd.HighlightLazily(inputLines.Lines[0].Number, lastVisibleLineNumber)
+		} else {
+			p.readers[p.currentReader].HighlightLazily(inputLines.Lines[0].Number, lastVisibleLineNumber)
+		}
+		p.readerLock.Unlock()
+	}
+
 	numberPrefixLength := p.getLineNumberPrefixLength(lastVisibleLineNumber)
 
+	var headerInputLines []reader.NumberedLine
+	if headerLineCount := p.headerLineCount(); headerLineCount > 0 {
+		headerInputLines = p.filteringReader.BackingReader.GetLines(linemetadata.Index{}, headerLineCount).Lines
+	}
+
+	if p.tableSeparator() != 0 {
+		// Size the columns before rendering any of them
+		p.tableView.measure(headerInputLines)
+		p.tableView.measure(inputLines.Lines)
+	}
+
+	headerLines := make([]renderedLine, 0, len(headerInputLines))
+	for _, line := range headerInputLines {
+		// Header lines aren't wrapped, each one gets exactly one screen line
+		headerLines = append(headerLines, p.renderLine(line, numberPrefixLength, false)[0])
+	}
+
 	allLines := make([]renderedLine, 0)
 	for _, line := range inputLines.Lines {
 		rendering := p.renderLine(line, numberPrefixLength, highlightSearchHitLines)
@@ -195,6 +234,7 @@ func (p *Pager) internalRenderLines(highlightSearchHitLines bool) renderedScreen
 	}
 
 	return renderedScreen{
+		headerLines:       headerLines,
 		lines:             allLines,
 		filenameText:      inputLines.FilenameText,
 		statusText:        inputLines.StatusText,
@@ -214,8 +254,29 @@ func (p *Pager) renderLine(line reader.NumberedLine, numberPrefixLength int, hig
 	width, _ := p.screen.Size()
 	var wrapped []textstyles.StyledRunesWithTrailer
 	var highlighted textstyles.StyledRunesWithTrailer
-	if p.WrapLongLines {
-		highlighted = line.HighlightedTokens(plainTextStyle, searchHitStyle, p.search, 0)
+	scrollLeftHintIndex := -1
+	if separator := p.tableSeparator(); separator != 0 {
+		// Table rows are never wrapped, scroll sideways to see more columns
+		highlighted, scrollLeftHintIndex = p.renderTableRow(line, separator)
+		wrapped = []textstyles.StyledRunesWithTrailer{highlighted}
+		if p.tableView.leftColumn == 0 {
+			scrollLeftHintIndex = -1
+		}
+	} else if jsonHighlighted, isJSON := line.JSONHighlightedTokens(p.jsonLinesView, jsonStyles, searchHitStyle, p.search, p.pinnedHighlights); isJSON {
+		// Pretty printed JSON comes with newlines
+		highlighted = jsonHighlighted
+		for _, part := range splitOnNewlines(highlighted.StyledRunes) {
+			if p.WrapLongLines {
+				wrapped = append(wrapped, wrapLine(width-numberPrefixLength, part)...)
+			} else {
+				wrapped = append(wrapped, textstyles.StyledRunesWithTrailer{
+					StyledRunes:       part,
+					ContainsSearchHit: part.ContainsSearchHit(),
+				})
+			}
+		}
+	} else if p.WrapLongLines {
+		highlighted = line.HighlightedTokens(plainTextStyle, searchHitStyle, p.search, p.pinnedHighlights, 0)
 
 		wrapped = wrapLine(width-numberPrefixLength, highlighted.StyledRunes)
 	} else {
@@ -225,7 +286,7 @@ func (p *Pager) renderLine(line reader.NumberedLine, numberPrefixLength int, hig
 		//
 		// This is a huge performance gain when dealing with files with
 		// extremeny long lines: https://github.com/walles/moor/issues/358
-		highlighted = line.HighlightedTokens(plainTextStyle, searchHitStyle, p.search, width+p.leftColumnZeroBased+1)
+		highlighted = line.HighlightedTokens(plainTextStyle, searchHitStyle, p.search, p.pinnedHighlights, width+p.leftColumnZeroBased+1)
 
 		// All on one line
 		wrapped = []textstyles.StyledRunesWithTrailer{{
@@ -249,19 +310,43 @@ func (p *Pager) renderLine(line reader.NumberedLine, numberPrefixLength int, hig
 		}
 	}
 
+	if line.IsContext {
+		// Context lines around filter matches are less important
+		for i := range wrapped {
+			for j := range wrapped[i].StyledRunes {
+				cell := &wrapped[i].StyledRunes[j]
+				if !cell.IsSearchHit {
+					cell.Style = cell.Style.WithAttr(twin.AttrDim)
+				}
+			}
+		}
+	}
+
 	rendered := make([]renderedLine, 0)
-	for wrapIndex, subLine := range wrapped {
+	if line.FollowsGap {
+		// Like grep does between context groups
+		rendered = append(rendered, renderedLine{
+			inputLineIndex: line.Index,
+			wrapIndex:      0,
+			cells:          append(createLinePrefix(nil, numberPrefixLength), contextSeparator...),
+		})
+	}
+
+	for subLineIndex, subLine := range wrapped {
 		lineNumber := line.Number
 		visibleLineNumber := &lineNumber
-		if wrapIndex > 0 {
+		if subLineIndex > 0 || line.NumberIsEstimate {
 			visibleLineNumber = nil
 		}
 
 		decorated := p.decorateLine(visibleLineNumber, numberPrefixLength, subLine.StyledRunes)
+		if scrollLeftHintIndex >= 0 {
+			decorated = p.addScrollLeftHint(decorated, numberPrefixLength+scrollLeftHintIndex)
+		}
 
 		rendered = append(rendered, renderedLine{
 			inputLineIndex:    line.Index,
-			wrapIndex:         wrapIndex,
+			wrapIndex:         len(rendered),
 			cells:             decorated,
 			containsSearchHit: subLine.ContainsSearchHit,
 			trailer:           subLine.Trailer,
@@ -279,8 +364,22 @@ func (p *Pager) renderLine(line reader.NumberedLine, numberPrefixLength int, hig
 	return rendered
 }
 
+// Split cells into lines at newline characters, dropping the newlines
+func splitOnNewlines(cells []textstyles.CellWithMetadata) []textstyles.CellWithMetadataSlice {
+	lines := []textstyles.CellWithMetadataSlice{}
+	start := 0
+	for i, cell := range cells {
+		if cell.Rune == '\n' {
+			lines = append(lines, cells[start:i])
+			start = i + 1
+		}
+	}
+	return append(lines, cells[start:])
+}
+
 // Take a rendered line and decorate as needed:
 //   - Line number, or leading whitespace for wrapped lines
+//   - Frozen columns, see frozenColumnCount()
 //   - Scroll left indicator
 //   - Scroll right indicator
 func (p *Pager) decorateLine(lineNumberToShow *linemetadata.Number, numberPrefixLength int, contents []textstyles.CellWithMetadata) []textstyles.CellWithMetadata {
@@ -288,23 +387,44 @@ func (p *Pager) decorateLine(lineNumberToShow *linemetadata.Number, numberPrefix
 	newLine := make([]textstyles.CellWithMetadata, 0, width)
 	newLine = append(newLine, createLinePrefix(lineNumberToShow, numberPrefixLength)...)
 
+	// Frozen columns stay put, and the rest of the line scrolls to the right
+	// of them
+	frozenWidth := 0
+	for len(contents) > 0 && frozenWidth+contents[0].Width() <= p.frozenColumnCount() {
+		if numberPrefixLength+frozenWidth+contents[0].Width() > width {
+			// Screen too narrow
+			break
+		}
+		newLine = append(newLine, contents[0])
+		frozenWidth += contents[0].Width()
+		contents = contents[1:]
+	}
+
+	// Where the scroll left indicator goes. With frozen columns, we want it on
+	// the scrolling part of the line.
+	scrollLeftHintIndex := 0
+	if frozenWidth > 0 {
+		scrollLeftHintIndex = len(newLine)
+	}
+
 	// Find the first and last fully visible runes.
 	var firstVisibleRuneIndex *int
 	lastVisibleRuneIndex := -1
-	screenColumn := numberPrefixLength // Zero based
+	screenColumn := numberPrefixLength + frozenWidth // Zero based
+	firstVisibleScreenColumn := p.leftColumnZeroBased + frozenWidth
 	lastVisibleScreenColumn := p.leftColumnZeroBased + width - 1
 	cutOffRuneToTheLeft := false
 	cutOffRuneToTheRight := false
 	canScrollRight := false
 	for i, char := range contents {
-		if firstVisibleRuneIndex == nil && screenColumn >= p.leftColumnZeroBased {
+		if firstVisibleRuneIndex == nil && screenColumn >= firstVisibleScreenColumn {
 			// Found the first fully visible rune. We need to point to a copy of
 			// our loop variable, not the loop variable itself. Just pointing to
 			// i, will make firstVisibleRuneIndex point to a new value for every
 			// iteration of the loop.
 			copyOfI := i
 			firstVisibleRuneIndex = &copyOfI
-			if i > 0 && screenColumn > p.leftColumnZeroBased && contents[i-1].Width() > 1 {
+			if i > 0 && screenColumn > firstVisibleScreenColumn && contents[i-1].Width() > 1 {
 				// We had to cut a rune in half at the start
 				cutOffRuneToTheLeft = true
 			}
@@ -337,7 +457,7 @@ func (p *Pager) decorateLine(lineNumberToShow *linemetadata.Number, numberPrefix
 
 	// Prepend a space if we had to cut a rune in half at the start
 	if cutOffRuneToTheLeft {
-		newLine = append([]textstyles.CellWithMetadata{{Rune: ' ', Style: p.ScrollLeftHint.Style}}, newLine...)
+		newLine = slices.Insert(newLine, scrollLeftHintIndex, textstyles.CellWithMetadata{Rune: ' ', Style: p.ScrollLeftHint.Style})
 	}
 
 	// Add the visible runes
@@ -353,24 +473,22 @@ func (p *Pager) decorateLine(lineNumberToShow *linemetadata.Number, numberPrefix
 	// Add scroll left indicator
 	canScrollLeft := p.leftColumnZeroBased > 0
 	if canScrollLeft && len(contents) > 0 {
-		if len(newLine) == 0 {
+		if len(newLine) <= scrollLeftHintIndex {
 			// Make room for the scroll left indicator
-			newLine = make([]textstyles.CellWithMetadata, 1)
+			newLine = append(newLine, textstyles.CellWithMetadata{})
 		}
 
-		if newLine[0].Width() > 1 {
+		if newLine[scrollLeftHintIndex].Width() > 1 {
 			// Replace the first rune with two spaces so we can replace the
 			// leftmost cell with a scroll left indicator. First, convert to one
 			// space...
-			newLine[0] = textstyles.CellWithMetadata{Rune: ' ', Style: p.ScrollLeftHint.Style}
+			newLine[scrollLeftHintIndex] = textstyles.CellWithMetadata{Rune: ' ', Style: p.ScrollLeftHint.Style}
 			// ...then prepend another space:
-			newLine = append([]textstyles.CellWithMetadata{{Rune: ' ', Style: p.ScrollLeftHint.Style}}, newLine...)
-
-			// Prepending ref: https://stackoverflow.com/a/53737602/473672
+			newLine = slices.Insert(newLine, scrollLeftHintIndex, textstyles.CellWithMetadata{Rune: ' ', Style: p.ScrollLeftHint.Style})
 		}
 
 		// Set can-scroll-left marker
-		newLine[0] = p.ScrollLeftHint
+		newLine[scrollLeftHintIndex] = p.ScrollLeftHint
 	}
 
 	// Add scroll right indicator
@@ -390,6 +508,22 @@ func (p *Pager) decorateLine(lineNumberToShow *linemetadata.Number, numberPrefix
 	return newLine
 }
 
+// Put a scroll left hint into the given cell, for table rows where the left
+// edge of the screen doesn't tell how far we have scrolled
+func (p *Pager) addScrollLeftHint(cells []textstyles.CellWithMetadata, index int) []textstyles.CellWithMetadata {
+	if index >= len(cells) {
+		return cells
+	}
+
+	if cells[index].Width() > 1 {
+		// Keep the rest of the line in place
+		cells = append(cells[:index+1], append([]textstyles.CellWithMetadata{{Rune: ' ', Style: p.ScrollLeftHint.Style}}, cells[index+1:]...)...)
+	}
+	cells[index] = p.ScrollLeftHint
+
+	return cells
+}
+
 // Generate a line number prefix of the given length.
 //
 // Can be empty or all-whitespace depending on parameters.
@@ -289,7 +289,7 @@ func TestShortenedInput(t *testing.T) {
 	assert.Equal(t, pager.lineIndex().Index(), 991, "This should have been the effect of calling scrollToEnd()")
 
 	pager.mode = NewPagerModeFilter(&pager)
-	pager.filter = search.For("first") // Match only the first line
+	pager.filter = newFilter("first") // Match only the first line
 
 	rendered := pager.renderLines()
 	assert.Equal(t, len(rendered.lines), 1, "Should have rendered one line")
@@ -327,7 +327,7 @@ func TestShortenedInputManyLines(t *testing.T) {
 	assert.Equal(t, pager.lineIndex().Index(), 991, "Should be at the last line before filtering")
 
 	pager.mode = NewPagerModeFilter(&pager)
-	pager.filter = search.For(`^match`)
+	pager.filter = newFilter(`^match`)
 
 	rendered := pager.renderLines()
 	assert.Equal(t, len(rendered.lines), 9, "Should have rendered 9 lines (10 minus one status bar)")
@@ -455,3 +455,93 @@ func BenchmarkRenderHugeLine(b *testing.B) {
 		pager.renderLines()
 	}
 }
+
+func TestHeaderColumns(t *testing.T) {
+	reader := reader.NewFromTextForTesting("TestHeaderColumns", "0123456789abcdefghijklmnop")
+	assert.NilError(t, reader.Wait())
+
+	pager := NewPager(reader)
+	pager.ShowLineNumbers = false
+	pager.showLineNumbers = false
+	pager.HeaderColumns = 4
+	pager.screen = twin.NewFakeScreen(10, 5)
+
+	numberedLine := reader.GetLine(linemetadata.Index{})
+	render := func() string {
+		return renderedToString(pager.renderLine(*numberedLine, 0, true)[0].cells)
+	}
+
+	assert.Equal(t, render(), "012345678>")
+
+	// The frozen columns stay, and the rest scrolls to the right of them
+	pager.leftColumnZeroBased = 6
+	assert.Equal(t, render(), "0123<bcde>")
+
+	pager.leftColumnZeroBased = 16
+	assert.Equal(t, render(), "0123<lmnop")
+}
+
+func TestHeaderColumnsSearchHit(t *testing.T) {
+	reader := reader.NewFromTextForTesting("TestHeaderColumnsSearchHit", "0123456789abcdefghijklmnop")
+	assert.NilError(t, reader.Wait())
+
+	pager := NewPager(reader)
+	pager.ShowLineNumbers = false
+	pager.showLineNumbers = false
+	pager.HeaderColumns = 4
+	pager.screen = twin.NewFakeScreen(10, 5)
+	pager.scrollPosition = newScrollPosition("TestHeaderColumnsSearchHit")
+	pager.search = search.For("12")
+
+	// Scrolled right, but the hit is in the frozen part so we can still see it
+	pager.leftColumnZeroBased = 6
+	assert.Assert(t, pager.searchHitIsVisible())
+
+	rendered := pager.renderLines()
+	assert.Assert(t, rendered.lines[0].cells[1].IsSearchHit)
+	assert.Assert(t, !rendered.lines[0].cells[3].IsSearchHit)
+}
+
+func TestHeaderLines(t *testing.T) {
+	lines := []string{"PID CMD"}
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, strconv.Itoa(i)+" cmd")
+	}
+	reader := reader.NewFromTextForTesting("TestHeaderLines", strings.Join(lines, "\n"))
+	assert.NilError(t, reader.Wait())
+
+	pager := NewPager(reader)
+	pager.ShowLineNumbers = false
+	pager.showLineNumbers = false
+	pager.HeaderLines = 1
+	pager.screen = twin.NewFakeScreen(20, 5)
+	pager.scrollPosition = newScrollPosition("TestHeaderLines")
+
+	renderedRows := func() []string {
+		rendered := pager.renderLines()
+		rows := []string{}
+		for _, line := range append(rendered.headerLines, rendered.lines...) {
+			rows = append(rows, renderedToString(line.cells))
+		}
+		return rows
+	}
+
+	assert.DeepEqual(t, renderedRows(), []string{"PID CMD", "1 cmd", "2 cmd", "3 cmd"})
+
+	pager.scrollPosition = pager.scrollPosition.NextLine(5)
+	assert.DeepEqual(t, renderedRows(), []string{"PID CMD", "6 cmd", "7 cmd", "8 cmd"})
+
+	pager.scrollToEnd()
+	assert.DeepEqual(t, renderedRows(), []string{"PID CMD", "18 cmd", "19 cmd", "20 cmd"})
+
+	// Going back up stops below the header line
+	pager.scrollPosition = pager.scrollPosition.PreviousLine(100)
+	assert.DeepEqual(t, renderedRows(), []string{"PID CMD", "1 cmd", "2 cmd", "3 cmd"})
+
+	// When filtering, the header stays and the matches scroll below it
+	pager.filter = newFilter("^1")
+	pager.filteringReader.Filter = &pager.filter
+	waitForFiltering(t, &pager.filteringReader)
+	pager.scrollPosition = newScrollPosition("TestHeaderLines")
+	assert.DeepEqual(t, renderedRows(), []string{"PID CMD", "1 cmd", "10 cmd", "11 cmd"})
+}
@@ -45,6 +45,9 @@ type scrollPositionCanonical struct {
 
 	pagerLineCount int // From pager.Reader().GetLineCount()
 
+	tableSeparator       rune               // From pager
+	firstScrollableIndex linemetadata.Index // From pager
+
 	lineIndex        *linemetadata.Index // From scrollPositionInternal
 	deltaScreenLines int                 // From scrollPositionInternal
 }
@@ -61,6 +64,9 @@ func canonicalFromPager(pager *Pager) scrollPositionCanonical {
 
 		pagerLineCount: pager.Reader().GetLineCount(),
 
+		tableSeparator:       pager.tableSeparator(),
+		firstScrollableIndex: pager.firstScrollableIndex(),
+
 		lineIndex:        pager.scrollPosition.internalDontTouch.lineIndex,
 		deltaScreenLines: pager.scrollPosition.internalDontTouch.deltaScreenLines,
 	}
@@ -126,7 +132,8 @@ func NewScrollPositionFromIndex(index linemetadata.Index, name string) scrollPos
 
 // Move towards the top until deltaScreenLines is not negative any more
 func (si *scrollPositionInternal) handleNegativeDeltaScreenLines(pager *Pager) {
-	for !si.lineIndex.IsZero() && si.deltaScreenLines < 0 {
+	firstIndex := pager.firstScrollableIndex()
+	for si.lineIndex.IsAfter(firstIndex) && si.deltaScreenLines < 0 {
 		// Render the previous line
 		previousLineIndex := si.lineIndex.NonWrappingAdd(-1)
 		previousLine := pager.Reader().GetLine(previousLineIndex)
@@ -142,7 +149,7 @@ func (si *scrollPositionInternal) handleNegativeDeltaScreenLines(pager *Pager) {
 		si.deltaScreenLines += previousSubLinesCount
 	}
 
-	if si.lineIndex.IsZero() && si.deltaScreenLines <= 0 {
+	if !si.lineIndex.IsAfter(firstIndex) && si.deltaScreenLines <= 0 {
 		// Can't go any higher
 		si.deltaScreenLines = 0
 		return
@@ -269,6 +276,11 @@ func (si *scrollPositionInternal) canonicalize(pager *Pager) {
 		si.lineIndex = &linemetadata.Index{}
 	}
 
+	if firstIndex := pager.firstScrollableIndex(); si.lineIndex.IsBefore(firstIndex) {
+		// Header lines are always visible at the top, don't scroll to them
+		si.lineIndex = &firstIndex
+	}
+
 	si.handleNegativeDeltaScreenLines(pager)
 	si.handlePositiveDeltaScreenLines(pager)
 	emptyBottomLinesCount := si.emptyBottomLinesCount(pager)
@@ -311,6 +323,8 @@ func (p *Pager) deltaScreenLines() int {
 }
 
 func (p *Pager) scrollToEnd() {
+	p.showFileEnd()
+
 	inputLineCount := p.Reader().GetLineCount()
 	if inputLineCount == 0 {
 		return
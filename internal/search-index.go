@@ -0,0 +1,347 @@
+// This file contains code for finding all search hits in the background.
+
+package internal
+
+import (
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/reader"
+	"github.com/walles/moor/v2/internal/search"
+	"github.com/walles/moor/v2/internal/util"
+)
+
+// Up to this many new lines are indexed right away, more than that and we index
+// in the background. Tests may lower this to exercise the background code path.
+var searchIndexInBackgroundThreshold = 50_000
+
+// Background indexing works in batches of this many lines, split across all
+// cores
+const searchIndexBatchSize = 1_000_000
+
+// Lists all lines with search hits, for counting them and for jumping between
+// them without rescanning the input. Lines added to the input are indexed
+// incrementally.
+type searchIndex struct {
+	// What we are indexing. If any of these change, we need a new index.
+	search        search.Search
+	reader        reader.Reader
+	backingReader reader.Reader
+	filter        Filter
+
+	// Protects hits, indexedCount and inBackground
+	lock sync.Mutex
+
+	// Sorted line indices
+	hits []linemetadata.Index
+
+	// How many lines have been indexed so far
+	indexedCount int
+
+	inBackground bool
+
+	cancelled atomic.Bool
+}
+
+// Get lines to index from a reader that is safe to use from other goroutines.
+// The FilteringReader isn't, since it looks at the pager's filter.
+type linesGetter func(firstLine int, lineCount int) []reader.NumberedLine
+
+// Make sure the search index is up to date, and return it. Returns nil if there
+// is no active search.
+func (p *Pager) updateSearchIndex() *searchIndex {
+	if p.search.Inactive() {
+		p.cancelSearchIndex()
+		return nil
+	}
+
+	index := p.searchIndex
+	if index == nil ||
+		!index.search.Equals(p.search) ||
+		index.reader != p.Reader() ||
+		index.backingReader != p.filteringReader.BackingReader ||
+		!index.filter.Equals(p.filter) {
+		p.cancelSearchIndex()
+		index = &searchIndex{
+			search:        p.search,
+			reader:        p.Reader(),
+			backingReader: p.filteringReader.BackingReader,
+			filter:        p.filter.clone(),
+		}
+		p.searchIndex = index
+	}
+
+	lineCount := p.Reader().GetLineCount()
+	dropped := 0
+	if index.reader == &p.filteringReader {
+		dropped = p.filteringReader.droppedLineCount()
+	}
+
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	if lineCount < index.indexedCount {
+		// The input shrunk, start over
+		index.hits = nil
+		index.indexedCount = 0
+	}
+
+	// Forget hits on dropped lines, see --max-lines
+	firstKept, _ := slices.BinarySearchFunc(index.hits, dropped, func(hit linemetadata.Index, target int) int {
+		return hit.Index() - target
+	})
+	index.hits = index.hits[firstKept:]
+
+	if index.inBackground || index.indexedCount >= lineCount {
+		// Nothing for us to do right now
+		return index
+	}
+
+	getLines := p.searchIndexLinesGetter()
+	if lineCount-index.indexedCount <= searchIndexInBackgroundThreshold {
+		index.hits = append(index.hits, findAllHits(getLines, index.search, index.indexedCount, lineCount)...)
+		index.indexedCount = lineCount
+		return index
+	}
+
+	index.inBackground = true
+	go index.indexInBackground(getLines, lineCount, p.searchIndexProgress)
+
+	return index
+}
+
+func (p *Pager) cancelSearchIndex() {
+	if p.searchIndex != nil {
+		p.searchIndex.cancelled.Store(true)
+	}
+	p.searchIndex = nil
+}
+
+func (p *Pager) searchIndexLinesGetter() linesGetter {
+	r := p.Reader()
+	if r == &p.filteringReader && p.filter.Active() {
+		// Accepted lines are never changed, only appended to, so we can hand
+		// out a snapshot
+		snapshot, firstIndex := p.filteringReader.getAllLines()
+		return func(firstLine int, lineCount int) []reader.NumberedLine {
+			// Instead of dropped lines we return later ones, like
+			// reader.GetLines() does
+			start := min(max(firstLine-firstIndex, 0), len(snapshot))
+			return snapshot[start:min(start+lineCount, len(snapshot))]
+		}
+	}
+
+	if r == &p.filteringReader {
+		// Not filtering, go directly to the source
+		r = p.filteringReader.BackingReader
+	}
+
+	return func(firstLine int, lineCount int) []reader.NumberedLine {
+		return r.GetLines(linemetadata.IndexFromZeroBased(firstLine), lineCount).Lines
+	}
+}
+
+// Index lines up to lineCount, in batches so that the UI can show progress
+func (index *searchIndex) indexInBackground(getLines linesGetter, lineCount int, progress chan bool) {
+	defer func() {
+		PanicHandler("searchIndex.indexInBackground()", recover(), debug.Stack())
+	}()
+
+	t0 := time.Now()
+	for !index.cancelled.Load() {
+		index.lock.Lock()
+		firstLine := index.indexedCount
+		index.lock.Unlock()
+
+		if firstLine >= lineCount {
+			break
+		}
+		lastLine := min(firstLine+searchIndexBatchSize, lineCount)
+
+		hits := findAllHits(getLines, index.search, firstLine, lastLine)
+
+		index.lock.Lock()
+		index.hits = append(index.hits, hits...)
+		index.indexedCount = lastLine
+		index.lock.Unlock()
+
+		notify(progress)
+	}
+
+	index.lock.Lock()
+	index.inBackground = false
+	hitCount := len(index.hits)
+	index.lock.Unlock()
+
+	log.Debugf("Search index for <%s> done in %s with %d hits, cancelled=%v",
+		index.search.String(), time.Since(t0), hitCount, index.cancelled.Load())
+
+	// If lines were added while we worked, the next updateSearchIndex() call
+	// will index those
+	notify(progress)
+}
+
+// Non blocking send
+func notify(channel chan bool) {
+	select {
+	case channel <- true:
+	default:
+		// Default case required for the write to be non-blocking
+	}
+}
+
+// Find all hits from firstLine up to but not including lastLine, in parallel on
+// multiple cores, like FindFirstHit() does.
+func findAllHits(getLines linesGetter, search search.Search, firstLine int, lastLine int) []linemetadata.Index {
+	linesCount := lastLine - firstLine
+	chunkCount := runtime.NumCPU()
+	if linesCount < chunkCount*1000 {
+		// Not worth the overhead
+		chunkCount = 1
+	}
+	chunkSize := (linesCount + chunkCount - 1) / chunkCount
+
+	chunkHits := make([][]linemetadata.Index, chunkCount)
+	var wg sync.WaitGroup
+	for chunk := range chunkCount {
+		chunkStart := firstLine + chunk*chunkSize
+		chunkEnd := min(chunkStart+chunkSize, lastLine)
+		if chunkStart >= chunkEnd {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				PanicHandler("findAllHits()/chunk", recover(), debug.Stack())
+			}()
+
+			for _, line := range getLines(chunkStart, chunkEnd-chunkStart) {
+				if line.Index.Index() < chunkStart || line.Index.Index() >= chunkEnd {
+					// Instead of dropped lines we get later ones, those
+					// belong to the next chunk
+					continue
+				}
+				if search.Matches(line.Plain()) {
+					chunkHits[chunk] = append(chunkHits[chunk], line.Index)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return slices.Concat(chunkHits...)
+}
+
+// Returns a copy of the hits found so far, and whether the index is complete
+func (index *searchIndex) snapshot() ([]linemetadata.Index, bool) {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	return slices.Clone(index.hits), !index.inBackground
+}
+
+// Finds the first hit at or after the given line, without copying the hits.
+// Returns which hit that is counting from zero, or nil if there is no such hit,
+// plus the number of hits found so far and whether the index is complete.
+func (index *searchIndex) firstHitFrom(from linemetadata.Index) (int, *linemetadata.Index, int, bool) {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	i, _ := slices.BinarySearchFunc(index.hits, from, func(hit linemetadata.Index, target linemetadata.Index) int {
+		return hit.Index() - target.Index()
+	})
+
+	var hit *linemetadata.Index
+	if i < len(index.hits) {
+		hitCopy := index.hits[i]
+		hit = &hitCopy
+	}
+
+	return i, hit, len(index.hits), !index.inBackground
+}
+
+// Find the first hit at or after (forward) or at or before (backward) the
+// given line. Returns nil if there is no such hit. The second return value is
+// false if the index doesn't cover that part of the input yet.
+func (index *searchIndex) find(from linemetadata.Index, direction SearchDirection) (*linemetadata.Index, bool) {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	i, found := slices.BinarySearchFunc(index.hits, from, func(hit linemetadata.Index, target linemetadata.Index) int {
+		return hit.Index() - target.Index()
+	})
+
+	if direction == SearchDirectionBackward {
+		if from.Index() >= index.indexedCount {
+			// We don't know about lines after what we indexed
+			return nil, false
+		}
+		if !found {
+			i--
+		}
+		if i < 0 {
+			return nil, true
+		}
+		hit := index.hits[i]
+		return &hit, true
+	}
+
+	if i < len(index.hits) {
+		hit := index.hits[i]
+		return &hit, true
+	}
+	return nil, !index.inBackground
+}
+
+// Like FindFirstHit(), but uses the search index when possible
+func (p *Pager) findHit(from linemetadata.Index, direction SearchDirection) *linemetadata.Index {
+	index := p.updateSearchIndex()
+	if index != nil {
+		hit, known := index.find(from, direction)
+		if known {
+			return hit
+		}
+	}
+
+	return FindFirstHit(p.Reader(), p.search, from, nil, direction)
+}
+
+// Something like "hit 17/342" if a hit is visible, otherwise "342 hits". A
+// trailing "+" means we're still counting. Empty if there is no search.
+func (p *Pager) searchHitsStatus() string {
+	index := p.updateSearchIndex()
+	if index == nil {
+		return ""
+	}
+
+	topIndex := p.lineIndex()
+	lastVisible := p.getLastVisiblePosition()
+
+	from := linemetadata.Index{}
+	if topIndex != nil {
+		from = *topIndex
+	}
+	hitNumber, hit, count, complete := index.firstHitFrom(from)
+
+	total := util.FormatInt(count)
+	if !complete {
+		total += "+"
+	}
+
+	if topIndex != nil && lastVisible != nil && hit != nil && !hit.IsAfter(*lastVisible.lineIndex(p)) {
+		return "hit " + util.FormatInt(hitNumber+1) + "/" + total
+	}
+
+	if count == 1 && complete {
+		return "1 hit"
+	}
+	return total + " hits"
+}
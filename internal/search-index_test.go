@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/reader"
+	"github.com/walles/moor/v2/twin"
+	"gotest.tools/v3/assert"
+)
+
+func newPagerForSearchIndexTest(t *testing.T, text string) *Pager {
+	reader := reader.NewFromTextForTesting(t.Name(), text)
+	pager := NewPager(reader)
+	pager.screen = twin.NewFakeScreen(20, 4)
+	assert.NilError(t, reader.Wait())
+	return pager
+}
+
+func TestSearchIndexCountsHits(t *testing.T) {
+	pager := newPagerForSearchIndexTest(t, "apa\nbepa\ncepa\nx\ny\nz\napa")
+	assert.Equal(t, pager.searchHitsStatus(), "")
+
+	pager.search.For("apa")
+	index := pager.updateSearchIndex()
+	hits, complete := index.snapshot()
+	assert.Assert(t, complete)
+	assert.DeepEqual(t, hits, []linemetadata.Index{
+		linemetadata.IndexFromZeroBased(0),
+		linemetadata.IndexFromZeroBased(6),
+	}, cmp.AllowUnexported(linemetadata.Index{}))
+
+	// Top of the screen shows the first hit
+	assert.Equal(t, pager.searchHitsStatus(), "hit 1/2")
+
+	// No hits visible on lines 2-4
+	pager.scrollPosition = NewScrollPositionFromIndex(linemetadata.IndexFromZeroBased(1), "test")
+	assert.Equal(t, pager.searchHitsStatus(), "2 hits")
+
+	// A different search should get a new index
+	pager.search.For("bepa")
+	assert.Assert(t, pager.updateSearchIndex() != index)
+	assert.Equal(t, pager.searchHitsStatus(), "hit 1/1")
+	pager.scrollPosition = NewScrollPositionFromIndex(linemetadata.IndexFromZeroBased(4), "test")
+	assert.Equal(t, pager.searchHitsStatus(), "1 hit")
+}
+
+func TestSearchIndexFind(t *testing.T) {
+	pager := newPagerForSearchIndexTest(t, "a\nhit\nb\nc\nhit\nd")
+	pager.search.For("hit")
+
+	assert.Equal(t, *pager.findHit(linemetadata.IndexFromZeroBased(2), SearchDirectionForward), linemetadata.IndexFromZeroBased(4))
+	assert.Equal(t, *pager.findHit(linemetadata.IndexFromZeroBased(4), SearchDirectionForward), linemetadata.IndexFromZeroBased(4))
+	assert.Assert(t, pager.findHit(linemetadata.IndexFromZeroBased(5), SearchDirectionForward) == nil)
+
+	assert.Equal(t, *pager.findHit(linemetadata.IndexFromZeroBased(3), SearchDirectionBackward), linemetadata.IndexFromZeroBased(1))
+	assert.Equal(t, *pager.findHit(linemetadata.IndexFromZeroBased(1), SearchDirectionBackward), linemetadata.IndexFromZeroBased(1))
+	assert.Assert(t, pager.findHit(linemetadata.IndexFromZeroBased(0), SearchDirectionBackward) == nil)
+}
+
+func TestSearchIndexFiltered(t *testing.T) {
+	pager := newPagerForSearchIndexTest(t, "apa 1\nbepa 2\napa 3\nbepa 4")
+	pager.search.For("2")
+	assert.Equal(t, pager.searchHitsStatus(), "hit 1/1")
+
+	// Indices should be in the filtered view
+	pager.filter = newFilter("bepa")
+	hit := pager.findHit(linemetadata.Index{}, SearchDirectionForward)
+	assert.Equal(t, *hit, linemetadata.IndexFromZeroBased(0))
+}
+
+func TestSearchIndexInBackground(t *testing.T) {
+	defer func(threshold int) { searchIndexInBackgroundThreshold = threshold }(searchIndexInBackgroundThreshold)
+	searchIndexInBackgroundThreshold = 10
+
+	lines := []string{}
+	for i := range 50_000 {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	pager := newPagerForSearchIndexTest(t, strings.Join(lines, "\n"))
+	pager.search.For("99$")
+
+	index := pager.updateSearchIndex()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		hits, complete := index.snapshot()
+		if complete {
+			assert.Equal(t, len(hits), 500)
+			for i, hit := range hits {
+				assert.Equal(t, hit, linemetadata.IndexFromZeroBased(i*100+99))
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Background indexing never finished")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Background indexing should have told the pager about its progress
+	select {
+	case <-pager.searchIndexProgress:
+	default:
+		t.Fatal("No progress notification")
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/alecthomas/chroma/v2"
 	log "github.com/sirupsen/logrus"
+	"github.com/walles/moor/v2/internal/reader"
 	"github.com/walles/moor/v2/internal/textstyles"
 	"github.com/walles/moor/v2/twin"
 )
@@ -28,6 +29,9 @@ var searchHitStyle = twin.StyleDefault.WithAttr(twin.AttrReverse)
 // This can be nil
 var searchHitLineBackground *twin.Color
 
+// For reformatted JSON Lines, see Pager.jsonLinesView
+var jsonStyles = reader.JSONStyles{}
+
 func setStyle(updateMe *twin.Style, envVarName string, fallback *twin.Style) {
 	envValue := os.Getenv(envVarName)
 	if envValue == "" {
@@ -92,6 +96,15 @@ func twinStyleFromChroma(terminalBackground *twin.Color, chromaStyle *chroma.Sty
 	return nil
 }
 
+// Like the JSON lexer would color this token, or plain text if not available
+func jsonStyleFromChroma(terminalBackground *twin.Color, chromaStyle *chroma.Style, chromaFormatter *chroma.Formatter, chromaToken chroma.TokenType) twin.Style {
+	style := twinStyleFromChroma(terminalBackground, chromaStyle, chromaFormatter, chromaToken, false)
+	if style == nil {
+		return plainTextStyle
+	}
+	return *style
+}
+
 // consumeLessTermcapEnvs parses LESS_TERMCAP_xx environment variables and
 // adapts the moor output accordingly.
 func consumeLessTermcapEnvs(terminalBackground *twin.Color, chromaStyle *chroma.Style, chromaFormatter *chroma.Formatter) {
@@ -145,6 +158,13 @@ func styleUI(terminalBackground *twin.Color, chromaStyle *chroma.Style, chromaFo
 	plainTextStyle = twin.StyleDefault
 	textstyles.ManPageHeading = twin.StyleDefault.WithAttr(twin.AttrBold)
 	lineNumbersStyle = twin.StyleDefault.WithAttr(twin.AttrDim)
+	jsonStyles = reader.JSONStyles{
+		Key:         twin.StyleDefault.WithAttr(twin.AttrBold),
+		String:      twin.StyleDefault,
+		Number:      twin.StyleDefault,
+		Constant:    twin.StyleDefault,
+		Punctuation: twin.StyleDefault,
+	}
 
 	if chromaStyle == nil || chromaFormatter == nil {
 		return
@@ -171,6 +191,16 @@ func styleUI(terminalBackground *twin.Color, chromaStyle *chroma.Style, chromaFo
 		plainTextStyle = *plainText
 	}
 
+	if !withTerminalFg {
+		jsonStyles = reader.JSONStyles{
+			Key:         jsonStyleFromChroma(terminalBackground, chromaStyle, chromaFormatter, chroma.NameTag),
+			String:      jsonStyleFromChroma(terminalBackground, chromaStyle, chromaFormatter, chroma.LiteralString),
+			Number:      jsonStyleFromChroma(terminalBackground, chromaStyle, chromaFormatter, chroma.LiteralNumber),
+			Constant:    jsonStyleFromChroma(terminalBackground, chromaStyle, chromaFormatter, chroma.KeywordConstant),
+			Punctuation: jsonStyleFromChroma(terminalBackground, chromaStyle, chromaFormatter, chroma.Punctuation),
+		}
+	}
+
 	if standoutStyle != nil {
 		log.Trace("Status bar style set from standout style: ", *standoutStyle)
 		statusbarStyle = *standoutStyle
@@ -0,0 +1,366 @@
+package internal
+
+import (
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/walles/moor/v2/internal/linemetadata"
+	"github.com/walles/moor/v2/internal/reader"
+	"github.com/walles/moor/v2/internal/textstyles"
+	"github.com/walles/moor/v2/twin"
+)
+
+// How CSV and TSV input is shown as a table, see Pager.toggleTableView()
+type tableView struct {
+	// True when we have decided whether the current input is a table
+	detected bool
+
+	// ',' for CSV, '\t' for TSV, 0 if the input isn't a table
+	separator rune
+
+	enabled bool
+
+	// Keep the first column on screen when scrolling sideways
+	freezeFirstColumn bool
+
+	// Display widths of the columns seen so far. These only grow, so that
+	// columns don't jump around while scrolling.
+	columnWidths []int
+
+	// How many (unfrozen) columns we have scrolled past to the right
+	leftColumn int
+}
+
+// Shown between table columns
+var tableColumnSeparator = []textstyles.CellWithMetadata{
+	{Rune: ' '},
+	{Rune: '│', Style: twin.StyleDefault.WithAttr(twin.AttrDim)},
+	{Rune: ' '},
+}
+
+// Table separator by file name extension, 0 if the name doesn't tell
+func tableSeparatorFromName(r *reader.ReaderImpl) rune {
+	for _, name := range []*string{r.FileName, r.DisplayName} {
+		if name == nil {
+			continue
+		}
+
+		switch strings.ToLower(filepath.Ext(*name)) {
+		case ".csv":
+			return ','
+		case ".tsv", ".tab":
+			return '\t'
+		}
+	}
+
+	return 0
+}
+
+// Decide whether the current input should be shown as a table. Done once per
+// input, based on the file name or, failing that, on the first lines.
+func (p *Pager) detectTable() {
+	if p.tableView.detected || p.isShowingHelp {
+		return
+	}
+
+	p.readerLock.Lock()
+	r := p.readers[p.currentReader]
+	p.readerLock.Unlock()
+
+	if separator := tableSeparatorFromName(r); separator != 0 {
+		log.Info("Table input detected by file name")
+		p.tableView = tableView{detected: true, separator: separator, enabled: true}
+		return
+	}
+
+	if r.GetLineCount() < reader.TableDetectionLines && !r.ReadingDone.Load() {
+		// Wait for more lines before deciding
+		return
+	}
+
+	lines := r.GetLines(linemetadata.Index{}, reader.TableDetectionLines)
+	raw := make([]string, 0, len(lines.Lines))
+	for _, line := range lines.Lines {
+		raw = append(raw, line.Line.Raw())
+	}
+
+	// Without a header we don't enable the table view, but the user can
+	separator, hasHeader := reader.DetectTableSeparator(raw)
+	if separator != 0 && hasHeader {
+		log.Info("Table input detected by contents")
+	}
+	p.tableView = tableView{detected: true, separator: separator, enabled: separator != 0 && hasHeader}
+}
+
+// The table separator if we're showing a table, 0 otherwise
+func (p *Pager) tableSeparator() rune {
+	if p.isShowingHelp || !p.tableView.enabled {
+		return 0
+	}
+
+	return p.tableView.separator
+}
+
+func (p *Pager) toggleTableView() {
+	if p.tableView.separator == 0 {
+		p.mode = &PagerModeInfo{Pager: p, Text: "Only CSV and TSV input can be shown as a table"}
+		return
+	}
+
+	// If we're at the top, stay there when the header line starts or stops
+	// scrolling with the rest
+	lineIndex := p.lineIndex()
+	atTop := lineIndex == nil || !lineIndex.IsAfter(p.firstScrollableIndex())
+
+	p.tableView.enabled = !p.tableView.enabled
+	p.tableView.leftColumn = 0
+	p.leftColumnZeroBased = 0
+	if atTop {
+		p.scrollPosition = newScrollPosition("Pager scroll position")
+	}
+	if p.tableView.enabled {
+		p.mode = &PagerModeInfo{Pager: p, Text: "Table view enabled"}
+	} else {
+		p.mode = &PagerModeInfo{Pager: p, Text: "Table view disabled"}
+	}
+}
+
+func (p *Pager) toggleFrozenColumn() {
+	if p.tableSeparator() == 0 {
+		p.mode = &PagerModeInfo{Pager: p, Text: "Columns can only be frozen in table view"}
+		return
+	}
+
+	p.tableView.freezeFirstColumn = !p.tableView.freezeFirstColumn
+	p.tableView.leftColumn = 0
+	if p.tableView.freezeFirstColumn {
+		p.mode = &PagerModeInfo{Pager: p, Text: "First column frozen"}
+	} else {
+		p.mode = &PagerModeInfo{Pager: p, Text: "First column scrolls with the others"}
+	}
+}
+
+// Scroll sideways one column at a time. Negative deltas move left.
+func (t *tableView) scrollColumns(delta int) {
+	lastLeftColumn := len(t.columnWidths) - 1
+	if t.freezeFirstColumn {
+		lastLeftColumn--
+	}
+
+	t.leftColumn = max(0, min(t.leftColumn+delta, lastLeftColumn))
+}
+
+// Column indices to show, from left to right
+func (t *tableView) visibleColumns(columnCount int) []int {
+	columns := []int{}
+	first := t.leftColumn
+	if t.freezeFirstColumn {
+		columns = append(columns, 0)
+		first++
+	}
+
+	for column := first; column < columnCount; column++ {
+		columns = append(columns, column)
+	}
+
+	return columns
+}
+
+// Grow the column widths to fit the fields of these lines
+func (t *tableView) measure(lines []reader.NumberedLine) {
+	for _, line := range lines {
+		for column, field := range reader.SplitFields(line.Line.Raw(), t.separator) {
+			width := 0
+			for _, cell := range textstyles.StyledRunesFromString(twin.StyleDefault, field, &line.Index, 0).StyledRunes {
+				width += cell.Width()
+			}
+
+			for len(t.columnWidths) <= column {
+				t.columnWidths = append(t.columnWidths, 0)
+			}
+			t.columnWidths[column] = max(t.columnWidths[column], width)
+		}
+	}
+}
+
+// Render one input line as a table row, with its fields padded to the column
+// widths. The scroll left hint, if needed, goes into the returned cell index.
+func (p *Pager) renderTableRow(line reader.NumberedLine, separator rune) (textstyles.StyledRunesWithTrailer, int) {
+	screenWidth, _ := p.screen.Size()
+
+	// Don't let one wide column push all others off screen
+	maxColumnWidth := max(screenWidth/2, 1)
+
+	fields := line.Line.HighlightedFields(separator, plainTextStyle, searchHitStyle, p.search, p.pinnedHighlights, line.Index)
+
+	row := textstyles.StyledRunesWithTrailer{}
+	for _, field := range fields {
+		row.ContainsSearchHit = row.ContainsSearchHit || field.ContainsSearchHit
+	}
+
+	scrollLeftHintIndex := 0
+	rowWidth := 0
+	for i, column := range p.tableView.visibleColumns(max(len(fields), len(p.tableView.columnWidths))) {
+		if rowWidth > screenWidth {
+			// The rest won't be visible anyway
+			break
+		}
+
+		if i > 0 {
+			if i == 1 && p.tableView.freezeFirstColumn {
+				// Point at the scrolled columns, not at the frozen one
+				scrollLeftHintIndex = len(row.StyledRunes) + 1
+			}
+			row.StyledRunes = append(row.StyledRunes, tableColumnSeparator...)
+			rowWidth += len(tableColumnSeparator)
+		}
+
+		width := 0
+		if column < len(p.tableView.columnWidths) {
+			width = min(p.tableView.columnWidths[column], maxColumnWidth)
+		}
+
+		var cells []textstyles.CellWithMetadata
+		if column < len(fields) {
+			cells = fields[column].StyledRunes
+		}
+
+		row.StyledRunes = append(row.StyledRunes, fitToWidth(cells, width)...)
+		rowWidth += width
+	}
+
+	if line.Number.IsZero() {
+		// This is the header row
+		for i := range row.StyledRunes {
+			row.StyledRunes[i].Style = row.StyledRunes[i].Style.WithAttr(twin.AttrBold)
+		}
+	}
+
+	return row, scrollLeftHintIndex
+}
+
+// Pad or truncate cells to exactly this display width. Truncation is marked
+// with an ellipsis.
+func fitToWidth(cells []textstyles.CellWithMetadata, width int) []textstyles.CellWithMetadata {
+	totalWidth := 0
+	for _, cell := range cells {
+		totalWidth += cell.Width()
+	}
+
+	result := make([]textstyles.CellWithMetadata, 0, width)
+	used := 0
+	if totalWidth <= width {
+		result = append(result, cells...)
+		used = totalWidth
+	} else {
+		for _, cell := range cells {
+			if used+cell.Width() > width-1 {
+				break
+			}
+			result = append(result, cell)
+			used += cell.Width()
+		}
+
+		if width > 0 {
+			result = append(result, textstyles.CellWithMetadata{Rune: '…', Style: plainTextStyle})
+			used++
+		}
+	}
+
+	for used < width {
+		result = append(result, textstyles.CellWithMetadata{Rune: ' ', Style: plainTextStyle})
+		used++
+	}
+
+	return result
+}
+
+// The first and last columns fully visible on screen, not counting any frozen
+// column
+func (p *Pager) fullyVisibleTableColumns(numberPrefixWidth int) (int, int) {
+	screenWidth, _ := p.screen.Size()
+	maxColumnWidth := max(screenWidth/2, 1)
+
+	// Minus one for the scroll right hint
+	availableWidth := screenWidth - numberPrefixWidth - 1
+
+	columns := p.tableView.visibleColumns(len(p.tableView.columnWidths))
+	first := -1
+	last := -1
+	usedWidth := 0
+	for i, column := range columns {
+		if i > 0 {
+			usedWidth += len(tableColumnSeparator)
+		}
+		usedWidth += min(p.tableView.columnWidths[column], maxColumnWidth)
+		if usedWidth > availableWidth {
+			break
+		}
+
+		if i == 0 && p.tableView.freezeFirstColumn {
+			continue
+		}
+		if first == -1 {
+			first = column
+		}
+		last = column
+	}
+
+	if first == -1 {
+		// Not even one column fits, count the leftmost one as visible
+		first = p.tableView.leftColumn
+		if p.tableView.freezeFirstColumn {
+			first++
+		}
+		last = first
+	}
+
+	return first, last
+}
+
+// Scroll sideways one or more columns to show search hits not currently
+// visible. Returns true if we found any.
+func (p *Pager) scrollColumnsToSearchHits(forward bool) bool {
+	rendered := p.renderLines()
+
+	hitColumns := map[int]bool{}
+	for _, line := range rendered.inputLines {
+		for column, field := range reader.SplitFields(line.Line.Raw(), p.tableView.separator) {
+			if p.search.Matches(textstyles.StripFormatting(field, line.Index)) {
+				hitColumns[column] = true
+			}
+		}
+	}
+
+	first, last := p.fullyVisibleTableColumns(rendered.numberPrefixWidth)
+	columnCount := len(p.tableView.columnWidths)
+	for i := range columnCount {
+		column := i
+		if !forward {
+			column = columnCount - 1 - i
+		}
+
+		if !hitColumns[column] {
+			continue
+		}
+		if column == 0 && p.tableView.freezeFirstColumn {
+			// Always visible
+			continue
+		}
+		if forward && column <= last {
+			continue
+		}
+		if !forward && column >= first {
+			continue
+		}
+
+		p.tableView.leftColumn = column
+		if p.tableView.freezeFirstColumn {
+			p.tableView.leftColumn--
+		}
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/walles/moor/v2/internal/reader"
+	"github.com/walles/moor/v2/internal/textstyles"
+	"github.com/walles/moor/v2/twin"
+	"gotest.tools/v3/assert"
+)
+
+const tableTestCSV = `Name,Age,City
+Ann,30,Oslo
+"Smith, Jane",25,Los Angeles
+Bob,40,Rome
+Eve,50,Paris
+Joe,60,Bern
+`
+
+func startPagingTable(t *testing.T, name string, text string) (*Pager, *twin.FakeScreen) {
+	r := reader.NewFromTextForTesting(name, text)
+	assert.NilError(t, r.Wait())
+
+	screen := twin.NewFakeScreen(40, 5)
+	pager := NewPager(r)
+	pager.ShowLineNumbers = false
+	pager.showLineNumbers = false
+
+	// Tell our Pager to quit immediately
+	pager.Quit()
+
+	// Except for just quitting, this also associates our FakeScreen with the Pager
+	pager.StartPaging(screen, nil, nil)
+
+	pager.redraw("")
+
+	return pager, screen
+}
+
+func screenRows(screen *twin.FakeScreen, count int) []string {
+	rows := []string{}
+	for row := range count {
+		rows = append(rows, rowToString(screen.GetRow(row)))
+	}
+	return rows
+}
+
+func TestTableView(t *testing.T) {
+	pager, screen := startPagingTable(t, "people.csv", tableTestCSV)
+
+	assert.DeepEqual(t, screenRows(screen, 4), []string{
+		"Name        │ Age │ City",
+		"Ann         │ 30  │ Oslo",
+		"Smith, Jane │ 25  │ Los Angeles",
+		"Bob         │ 40  │ Rome",
+	})
+
+	// The header row is bold
+	assert.Assert(t, screen.GetRow(0)[0].Style.HasAttr(twin.AttrBold))
+	assert.Assert(t, !screen.GetRow(1)[0].Style.HasAttr(twin.AttrBold))
+
+	// Toggling shows the raw input
+	pager.toggleTableView()
+	pager.redraw("")
+	assert.Equal(t, rowToString(screen.GetRow(0)), "Name,Age,City")
+}
+
+func TestTableViewDetectedByContents(t *testing.T) {
+	_, screen := startPagingTable(t, "people", "Name\tAge\nAnn\t30\nBob\t40\nEve\t50\nJoe\t60\n")
+
+	assert.DeepEqual(t, screenRows(screen, 2), []string{
+		"Name │ Age",
+		"Ann  │ 30",
+	})
+}
+
+func TestTableViewFrozenHeader(t *testing.T) {
+	pager, screen := startPagingTable(t, "people.csv", tableTestCSV)
+
+	pager.scrollPosition = pager.scrollPosition.NextLine(1)
+	pager.redraw("")
+	assert.DeepEqual(t, screenRows(screen, 4), []string{
+		"Name        │ Age │ City",
+		"Smith, Jane │ 25  │ Los Angeles",
+		"Bob         │ 40  │ Rome",
+		"Eve         │ 50  │ Paris",
+	})
+
+	pager.scrollToEnd()
+	pager.redraw("")
+	assert.DeepEqual(t, screenRows(screen, 4), []string{
+		"Name        │ Age │ City",
+		"Bob         │ 40  │ Rome",
+		"Eve         │ 50  │ Paris",
+		"Joe         │ 60  │ Bern",
+	})
+
+	// Scrolling up doesn't show the header line twice
+	pager.scrollPosition = pager.scrollPosition.PreviousLine(10)
+	pager.redraw("")
+	assert.Equal(t, rowToString(screen.GetRow(1)), "Ann         │ 30  │ Oslo")
+}
+
+func TestTableViewColumnScrolling(t *testing.T) {
+	pager, screen := startPagingTable(t, "people.csv", tableTestCSV)
+
+	pager.moveRight(pager.SideScrollAmount)
+	pager.redraw("")
+	assert.DeepEqual(t, screenRows(screen, 2), []string{
+		"<ge │ City",
+		"<0  │ Oslo",
+	})
+
+	pager.moveRight(-pager.SideScrollAmount)
+	pager.redraw("")
+	assert.Equal(t, rowToString(screen.GetRow(0)), "Name        │ Age │ City")
+}
+
+func TestTableViewFrozenColumn(t *testing.T) {
+	pager, screen := startPagingTable(t, "people.csv", tableTestCSV)
+
+	pager.toggleFrozenColumn()
+	pager.moveRight(pager.SideScrollAmount)
+	pager.redraw("")
+	assert.DeepEqual(t, screenRows(screen, 2), []string{
+		"Name        < City",
+		"Ann         < Oslo",
+	})
+
+	// Can't scroll the last column out of view
+	pager.moveRight(pager.SideScrollAmount)
+	pager.moveRight(pager.SideScrollAmount)
+	pager.redraw("")
+	assert.Equal(t, rowToString(screen.GetRow(1)), "Ann         < Oslo")
+}
+
+func TestFitToWidth(t *testing.T) {
+	cellsToString := func(cells []textstyles.CellWithMetadata) string {
+		s := ""
+		for _, cell := range cells {
+			s += string(cell.Rune)
+		}
+		return s
+	}
+
+	text := textstyles.StyledRunesFromString(twin.StyleDefault, "hello", nil, 0).StyledRunes
+	assert.Equal(t, cellsToString(fitToWidth(text, 7)), "hello  ")
+	assert.Equal(t, cellsToString(fitToWidth(text, 5)), "hello")
+	assert.Equal(t, cellsToString(fitToWidth(text, 4)), "hel…")
+	assert.Equal(t, cellsToString(fitToWidth(text, 0)), "")
+}
@@ -27,8 +27,8 @@ type Options struct {
 	// blank for default.
 	Title string
 
-	// The default is to auto format JSON input. Set this to true to disable
-	// auto formatting.
+	// The default is to auto format JSON, XML and some other formats. Set this
+	// to true to disable auto formatting.
 	NoAutoFormat bool
 
 	// The default is to truncate long lines, and let the user press right-arrow
@@ -44,6 +44,14 @@ type Options struct {
 	// The default is to always start the pager. If this is set to true, short
 	// input will just be printed, and no paging will happen.
 	QuitIfOneScreen bool
+
+	// Keep this many lines at the top in view while scrolling down, like the
+	// column names of a table. The default is to scroll all lines.
+	HeaderLines int
+
+	// Keep this many screen columns to the left in view while scrolling
+	// right. The default is to scroll all columns.
+	HeaderColumns int
 }
 
 // If stdout is not a terminal, the stream contents will just be printed to
@@ -155,6 +163,8 @@ func pageFromReader(reader *internalReader.ReaderImpl, options Options) error {
 	pager.WrapLongLines = options.WrapLongLines
 	pager.ShowLineNumbers = !options.NoLineNumbers
 	pager.QuitIfOneScreen = options.QuitIfOneScreen
+	pager.HeaderLines = options.HeaderLines
+	pager.HeaderColumns = options.HeaderColumns
 
 	screen, e := twin.NewScreen()
 	if e != nil {